@@ -0,0 +1,526 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Tags identifying the type of an encoded value. The values of these
+// constants are part of the binary format and must never change.
+const (
+	tagNil byte = iota
+	tagString
+	tagBytes
+	tagBool
+	tagInt
+	tagInt8
+	tagInt16
+	tagInt32
+	tagInt64
+	tagUint
+	tagUint8
+	tagUint16
+	tagUint32
+	tagUint64
+	tagFloat32
+	tagFloat64
+	tagMap
+	tagSlice
+	tagPath
+	tagPointer
+	tagKey
+	tagKeyMap
+	// tagOther identifies the type and string representation of a
+	// value of a type that can't be encoded otherwise, only produced
+	// by CanonicalBytes and never decoded.
+	tagOther
+	tagComplex64
+	tagComplex128
+)
+
+// mapBinaryVersion is the version of the binary format of a Map
+// produced by MarshalBinary. It must be incremented whenever that
+// format changes.
+const mapBinaryVersion = 1
+
+var errTruncated = errors.New("truncated input")
+
+// appendKey appends the binary encoding of k to b.
+func appendKey(b []byte, k Key) ([]byte, error) {
+	if k, ok := k.(bytesKey); ok {
+		// bytesKey.Key returns a string, so handle it here to
+		// preserve the type across an encoding round-trip.
+		return appendBytes(append(b, tagBytes), string(k)), nil
+	}
+	return appendValue(b, k.Key())
+}
+
+// appendValue appends the binary encoding of v to b. Maps are encoded
+// with their keys in sorted order, so equal values always produce the
+// same encoding.
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	var err error
+	switch v := v.(type) {
+	case nil:
+		b = append(b, tagNil)
+	case string:
+		b = appendBytes(append(b, tagString), v)
+	case []byte:
+		b = appendBytes(append(b, tagBytes), string(v))
+	case bool:
+		if v {
+			b = append(b, tagBool, 1)
+		} else {
+			b = append(b, tagBool, 0)
+		}
+	case int:
+		b = appendVarint(append(b, tagInt), int64(v))
+	case int8:
+		b = appendVarint(append(b, tagInt8), int64(v))
+	case int16:
+		b = appendVarint(append(b, tagInt16), int64(v))
+	case int32:
+		b = appendVarint(append(b, tagInt32), int64(v))
+	case int64:
+		b = appendVarint(append(b, tagInt64), v)
+	case uint:
+		b = appendUvarint(append(b, tagUint), uint64(v))
+	case uint8:
+		b = appendUvarint(append(b, tagUint8), uint64(v))
+	case uint16:
+		b = appendUvarint(append(b, tagUint16), uint64(v))
+	case uint32:
+		b = appendUvarint(append(b, tagUint32), uint64(v))
+	case uint64:
+		b = appendUvarint(append(b, tagUint64), v)
+	case float32:
+		b = appendUint32(append(b, tagFloat32), math.Float32bits(v))
+	case float64:
+		b = appendUint64(append(b, tagFloat64), math.Float64bits(v))
+	case complex64:
+		b = appendUint32(append(b, tagComplex64), math.Float32bits(real(v)))
+		b = appendUint32(b, math.Float32bits(imag(v)))
+	case complex128:
+		b = appendUint64(append(b, tagComplex128), math.Float64bits(real(v)))
+		b = appendUint64(b, math.Float64bits(imag(v)))
+	case map[string]interface{}:
+		b = appendUvarint(append(b, tagMap), uint64(len(v)))
+		for _, k := range SortedKeys(v) {
+			b = appendBytes(b, k)
+			if b, err = appendValue(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		b = appendUvarint(append(b, tagSlice), uint64(len(v)))
+		for _, element := range v {
+			if b, err = appendValue(b, element); err != nil {
+				return nil, err
+			}
+		}
+	case Path:
+		return appendPath(append(b, tagPath), v)
+	case Pointer:
+		return appendPath(append(b, tagPointer), v.Pointer())
+	case Key:
+		return appendKey(append(b, tagKey), v)
+	case *Map:
+		return v.appendBinary(append(b, tagKeyMap))
+	default:
+		return nil, fmt.Errorf("unable to encode type %T", v)
+	}
+	return b, nil
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBytes(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendPath(b []byte, p Path) ([]byte, error) {
+	b = appendUvarint(b, uint64(len(p)))
+	var err error
+	for _, element := range p {
+		if b, err = appendKey(b, element); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// decodeKey decodes a Key encoded by appendKey from the start of b,
+// and returns it along with the remainder of b.
+func decodeKey(b []byte) (Key, []byte, error) {
+	v, b, err := decodeValue(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch v.(type) {
+	case int, uint:
+		return nil, nil, fmt.Errorf("invalid type for key: %T", v)
+	}
+	k, err := NewSafe(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, b, nil
+}
+
+// decodeValue decodes a value encoded by appendValue from the start
+// of b, and returns it along with the remainder of b.
+func decodeValue(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errTruncated
+	}
+	tag, b := b[0], b[1:]
+	switch tag {
+	case tagNil:
+		return nil, b, nil
+	case tagString:
+		s, b, err := decodeBytes(b)
+		return s, b, err
+	case tagBytes:
+		s, b, err := decodeBytes(b)
+		return []byte(s), b, err
+	case tagBool:
+		if len(b) == 0 {
+			return nil, nil, errTruncated
+		}
+		return b[0] != 0, b[1:], nil
+	case tagInt, tagInt8, tagInt16, tagInt32, tagInt64:
+		i, n := binary.Varint(b)
+		if n <= 0 {
+			return nil, nil, errTruncated
+		}
+		b = b[n:]
+		switch tag {
+		case tagInt:
+			return int(i), b, nil
+		case tagInt8:
+			return int8(i), b, nil
+		case tagInt16:
+			return int16(i), b, nil
+		case tagInt32:
+			return int32(i), b, nil
+		}
+		return i, b, nil
+	case tagUint, tagUint8, tagUint16, tagUint32, tagUint64:
+		u, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, nil, errTruncated
+		}
+		b = b[n:]
+		switch tag {
+		case tagUint:
+			return uint(u), b, nil
+		case tagUint8:
+			return uint8(u), b, nil
+		case tagUint16:
+			return uint16(u), b, nil
+		case tagUint32:
+			return uint32(u), b, nil
+		}
+		return u, b, nil
+	case tagFloat32:
+		if len(b) < 4 {
+			return nil, nil, errTruncated
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), b[4:], nil
+	case tagFloat64:
+		if len(b) < 8 {
+			return nil, nil, errTruncated
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case tagComplex64:
+		if len(b) < 8 {
+			return nil, nil, errTruncated
+		}
+		re := math.Float32frombits(binary.BigEndian.Uint32(b))
+		im := math.Float32frombits(binary.BigEndian.Uint32(b[4:]))
+		return complex(re, im), b[8:], nil
+	case tagComplex128:
+		if len(b) < 16 {
+			return nil, nil, errTruncated
+		}
+		re := math.Float64frombits(binary.BigEndian.Uint64(b))
+		im := math.Float64frombits(binary.BigEndian.Uint64(b[8:]))
+		return complex(re, im), b[16:], nil
+	case tagMap:
+		n, b, err := decodeLength(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			var k string
+			if k, b, err = decodeBytes(b); err != nil {
+				return nil, nil, err
+			}
+			if m[k], b, err = decodeValue(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		return m, b, nil
+	case tagSlice:
+		n, b, err := decodeLength(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := make([]interface{}, n)
+		for i := range s {
+			if s[i], b, err = decodeValue(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		return s, b, nil
+	case tagPath:
+		return decodePath(b)
+	case tagPointer:
+		p, b, err := decodePath(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPointer(p), b, nil
+	case tagKey:
+		return decodeKey(b)
+	case tagKeyMap:
+		m := &Map{}
+		b, err := m.decodeBinary(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return m, b, nil
+	}
+	return nil, nil, fmt.Errorf("unknown type tag %d", tag)
+}
+
+// decodeLength decodes a length, making sure it is plausible given
+// the number of bytes left to decode.
+func decodeLength(b []byte) (int, []byte, error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, errTruncated
+	}
+	b = b[n:]
+	if l > uint64(len(b)) {
+		return 0, nil, errTruncated
+	}
+	return int(l), b, nil
+}
+
+func decodeBytes(b []byte) (string, []byte, error) {
+	l, b, err := decodeLength(b)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(b[:l]), b[l:], nil
+}
+
+func decodePath(b []byte) (Path, []byte, error) {
+	n, b, err := decodeLength(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := make(Path, n)
+	for i := range p {
+		if p[i], b, err = decodeKey(b); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p, b, nil
+}
+
+// MarshalBinary encodes the Map in a binary form that can be decoded
+// by UnmarshalBinary. The encoding starts with a byte holding the
+// version of the format, so that encodings produced by later versions
+// of this package that UnmarshalBinary can't decode are rejected. An
+// error is returned if a key or a value of the Map, or of a nested
+// Map, isn't of a type that can be encoded. Keys created by New and
+// Paths are supported, but not other Hashable keys.
+func (m *Map) MarshalBinary() ([]byte, error) {
+	return m.appendBinary([]byte{mapBinaryVersion})
+}
+
+// UnmarshalBinary replaces the entries of the Map with those decoded
+// from b, which must have been produced by MarshalBinary. An error is
+// returned if b was produced by an unknown version of MarshalBinary,
+// or is otherwise invalid, in which case the Map is left untouched.
+func (m *Map) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return errTruncated
+	}
+	if version := b[0]; version != mapBinaryVersion {
+		return fmt.Errorf("unsupported key.Map binary format version %d (expected %d)",
+			version, mapBinaryVersion)
+	}
+	var decoded Map
+	b, err := decoded.decodeBinary(b[1:])
+	if err != nil {
+		return err
+	}
+	if len(b) != 0 {
+		return fmt.Errorf("%d trailing bytes after key.Map", len(b))
+	}
+	m.replaceStorage(&decoded)
+	return nil
+}
+
+func (m *Map) appendBinary(b []byte) ([]byte, error) {
+	b = appendUvarint(b, uint64(m.Len()))
+	err := m.Iter(func(k, v interface{}) error {
+		var err error
+		if k, ok := k.(hashedKey); ok {
+			return fmt.Errorf("unable to encode key created by NewWithHash: %#v", k)
+		}
+		if b, err = appendValue(b, k); err != nil {
+			return err
+		}
+		b, err = appendValue(b, v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// CanonicalBytes returns a deterministic encoding of the Map, such that
+// Maps that are Equal, whatever the order in which their entries were
+// set, have identical encodings, which can be compared with
+// bytes.Equal. The encoding is that of MarshalBinary, except that the
+// entries of the Map and of its nested Maps are sorted by key with
+// Compare, keys created by NewWithHash are encoded as the keys they
+// wrap, and keys and values of types MarshalBinary can't encode are
+// encoded by their type and string representation rather than causing
+// an error. Since Equal considers a Map equal to a map[string]interface{}
+// holding the same entries, and NaN values equal to each other, values
+// of type map[string]interface{} are encoded as Maps and all NaNs are
+// encoded as the same NaN. An encoding that holds no keys or values of
+// types MarshalBinary can't encode can be decoded by UnmarshalBinary,
+// which decodes map[string]interface{} values as Maps. Sorting the
+// entries makes CanonicalBytes slower than MarshalBinary.
+func (m *Map) CanonicalBytes() []byte {
+	return m.appendCanonical([]byte{mapBinaryVersion})
+}
+
+// The bits of the NaNs CanonicalBytes encodes all NaNs as.
+var (
+	canonicalNaN32 = math.Float32bits(float32(math.NaN()))
+	canonicalNaN64 = math.Float64bits(math.NaN())
+)
+
+type canonicalEntry struct {
+	k, v interface{}
+	// encodedKey is the canonical encoding of k, which orders keys
+	// that Compare can't tell apart.
+	encodedKey []byte
+}
+
+func (m *Map) appendCanonical(b []byte) []byte {
+	entries := make([]canonicalEntry, 0, m.Len())
+	_ = m.Iter(func(k, v interface{}) error {
+		entries = append(entries, canonicalEntry{k: k, v: v, encodedKey: appendCanonical(nil, k)})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		if c := Compare(entries[i].k, entries[j].k); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(entries[i].encodedKey, entries[j].encodedKey) < 0
+	})
+	b = appendUvarint(b, uint64(len(entries)))
+	for _, e := range entries {
+		b = append(b, e.encodedKey...)
+		b = appendCanonical(b, e.v)
+	}
+	return b
+}
+
+// appendCanonical appends the canonical encoding of v to b, as used by
+// CanonicalBytes.
+func appendCanonical(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case hashedKey:
+		return appendCanonical(b, v.key)
+	case float32:
+		if math.IsNaN(float64(v)) {
+			return appendUint32(append(b, tagFloat32), canonicalNaN32)
+		}
+	case float64:
+		if math.IsNaN(v) {
+			return appendUint64(append(b, tagFloat64), canonicalNaN64)
+		}
+	case map[string]interface{}:
+		// Encoded like a Map holding the same entries, whose string keys
+		// are sorted the same way by Compare.
+		b = appendUvarint(append(b, tagKeyMap), uint64(len(v)))
+		for _, k := range SortedKeys(v) {
+			b = appendCanonical(appendCanonical(b, k), v[k])
+		}
+		return b
+	case []interface{}:
+		b = appendUvarint(append(b, tagSlice), uint64(len(v)))
+		for _, element := range v {
+			b = appendCanonical(b, element)
+		}
+		return b
+	case *Map:
+		return v.appendCanonical(append(b, tagKeyMap))
+	}
+	if encoded, err := appendValue(b, v); err == nil {
+		return encoded
+	}
+	return appendBytes(append(b, tagOther), fmt.Sprintf("%T(%v)", v, v))
+}
+
+func (m *Map) decodeBinary(b []byte) ([]byte, error) {
+	n, b, err := decodeLength(b)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		var k, v interface{}
+		if k, b, err = decodeValue(b); err != nil {
+			return nil, err
+		}
+		switch k.(type) {
+		case []byte, map[string]interface{}, []interface{}, Pointer:
+			return nil, fmt.Errorf("invalid type for key.Map key: %T", k)
+		}
+		if v, b, err = decodeValue(b); err != nil {
+			return nil, err
+		}
+		m.Set(k, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMapMarshalBinary(t *testing.T) {
+	maps := []*Map{
+		NewMap(),
+		NewMap("a", 1, "b", "c"),
+		NewMap(
+			"a", New("a"),
+			New("a"), "a",
+			int8(-1), uint64(1),
+			float32(1.5), float64(-2.5),
+			true, nil,
+			New(map[string]interface{}{"name": "Ethernet1"}), []interface{}{"a", int32(1)},
+			New([]interface{}{int16(1), "b"}), map[string]interface{}{"x": "y"},
+			New([]byte("bytes")), "bytes",
+			New(Path{New("a"), New(uint16(2))}), NewPointer(Path{New("b")}),
+			Path{New("raw"), New(int32(3))}, "path",
+		),
+		NewMap(
+			"nested", NewMap("a", NewMap(New("b"), 1)),
+			NewMap("as", "key"), 2,
+			"slice", []interface{}{NewMap("c", 3)},
+		),
+	}
+	for i, m := range maps {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d failed: %s", i, err)
+		}
+		if b[0] != mapBinaryVersion {
+			t.Errorf("Test %d failed: expected version %d, got %d", i, mapBinaryVersion, b[0])
+		}
+		decoded := NewMap("stale", "entry")
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			t.Fatalf("Test %d failed: %s", i, err)
+		}
+		if !decoded.Equal(m) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, m, decoded)
+		}
+	}
+
+	// Entries set with a Key and with the value it wraps are different
+	// entries, and must stay so.
+	var decoded Map
+	b, _ := maps[2].MarshalBinary()
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := decoded.Get("a"); !keyEqual(v, New("a")) {
+		t.Errorf("unexpected value for \"a\": %#v", v)
+	}
+	if v, _ := decoded.Get(New("a")); v != "a" {
+		t.Errorf("unexpected value for New(\"a\"): %#v", v)
+	}
+
+	for _, m := range []*Map{
+		NewMap(dumbHashable{dumb: 1}, 1),
+		NewMap(NewWithHash("a", 1), 1),
+		NewMap("a", struct{}{}),
+		NewMap("a", NewMap("b", func() {})),
+	} {
+		if b, err := m.MarshalBinary(); err == nil {
+			t.Errorf("expected error encoding %v, got %q", m, b)
+		}
+	}
+}
+
+func TestMapCanonicalBytes(t *testing.T) {
+	entries := []interface{}{
+		"a", 1,
+		"b", "c",
+		int8(-1), uint64(1),
+		float64(-2.5), NewMap("x", 1, "y", 2, "z", 3),
+		true, nil,
+		New(map[string]interface{}{"name": "Ethernet1"}), map[string]interface{}{"x": "y", "z": 1},
+		Path{New("raw"), New(int32(3))}, []interface{}{"a", NewMap(1, 2, 3, 4)},
+		dumbHashable{dumb: 1}, "custom",
+		dumbHashable{dumb: 2}, struct{}{},
+		NewWithHash("hashed", 1), "value",
+	}
+	for i := 0; i < 100; i++ {
+		entries = append(entries, fmt.Sprint("key", i), i)
+	}
+	forward := NewMap()
+	for i := 0; i < len(entries); i += 2 {
+		forward.Set(entries[i], entries[i+1])
+	}
+	backward := NewMap()
+	for i := len(entries) - 2; i >= 0; i -= 2 {
+		backward.Set(entries[i], entries[i+1])
+	}
+	if !forward.Equal(backward) {
+		t.Fatalf("expected %v to equal %v", forward, backward)
+	}
+	b := forward.CanonicalBytes()
+	if !bytes.Equal(b, backward.CanonicalBytes()) {
+		t.Errorf("expected identical encodings:\n%q\n%q", b, backward.CanonicalBytes())
+	}
+	if !bytes.Equal(b, forward.CanonicalBytes()) {
+		t.Errorf("expected identical encodings of the same Map")
+	}
+	backward.Set("a", 2)
+	if bytes.Equal(b, backward.CanonicalBytes()) {
+		t.Errorf("expected different encodings for %v and %v", forward, backward)
+	}
+
+	// Encodings of Maps that MarshalBinary can encode can be decoded.
+	m := NewMap("b", NewMap(2, 3, 1, 4), "a", []interface{}{NewMap("y", 1, "x", 2)}, New("c"), 5)
+	var decoded Map
+	if err := decoded.UnmarshalBinary(m.CanonicalBytes()); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(m) {
+		t.Errorf("expected %v, got %v", m, &decoded)
+	}
+	if err := decoded.UnmarshalBinary(b); err == nil {
+		t.Errorf("expected an error decoding an encoding of unsupported types")
+	}
+
+	// Maps that are Equal because a value is a Map in one and a
+	// map[string]interface{} in the other, or because their values are
+	// NaNs with different bits, have identical encodings.
+	nan64 := math.Float64frombits(math.Float64bits(math.NaN()) + 1)
+	nan32 := math.Float32frombits(math.Float32bits(float32(math.NaN())) + 1)
+	for i, tcase := range []struct {
+		a, b *Map
+	}{{
+		a: NewMap("a", NewMap("b", int64(1))),
+		b: NewMap("a", map[string]interface{}{"b": int64(1)}),
+	}, {
+		a: NewMap("a", NewMap("b", NewMap("c", "d"), "e", math.NaN())),
+		b: NewMap("a", map[string]interface{}{"b": NewMap("c", "d"), "e": nan64}),
+	}, {
+		a: NewMap("a", math.NaN(), "b", float32(math.NaN())),
+		b: NewMap("a", nan64, "b", nan32),
+	}} {
+		if !tcase.a.Equal(tcase.b) {
+			t.Errorf("Test %d failed: expected %v to equal %v", i, tcase.a, tcase.b)
+		}
+		if a, b := tcase.a.CanonicalBytes(), tcase.b.CanonicalBytes(); !bytes.Equal(a, b) {
+			t.Errorf("Test %d failed: expected identical encodings:\n%q\n%q", i, a, b)
+		}
+		var decoded Map
+		if err := decoded.UnmarshalBinary(tcase.b.CanonicalBytes()); err != nil {
+			t.Errorf("Test %d failed: %s", i, err)
+		} else if !decoded.Equal(tcase.b) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, tcase.b, &decoded)
+		}
+	}
+	if bytes.Equal(NewMap("a", 1.5).CanonicalBytes(),
+		NewMap("a", math.NaN()).CanonicalBytes()) {
+		t.Errorf("expected different encodings for 1.5 and NaN")
+	}
+}
+
+func TestMapUnmarshalBinaryErrors(t *testing.T) {
+	valid, err := NewMap("a", 1, New("b"), NewMap("c", 2)).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := append([]byte{mapBinaryVersion + 1}, valid[1:]...)
+	tests := []struct {
+		b   []byte
+		err string
+	}{{
+		b:   nil,
+		err: "truncated input",
+	}, {
+		b:   []byte{0},
+		err: "unsupported key.Map binary format version 0",
+	}, {
+		b:   future,
+		err: "unsupported key.Map binary format version 2",
+	}, {
+		b:   []byte{255, 0},
+		err: "unsupported key.Map binary format version 255",
+	}, {
+		b:   valid[:len(valid)-1],
+		err: "truncated input",
+	}, {
+		b:   append(append([]byte{}, valid...), 0),
+		err: "1 trailing bytes after key.Map",
+	}, {
+		b:   []byte{mapBinaryVersion, 1, tagSlice, 0, tagNil},
+		err: "invalid type for key.Map key: []interface {}",
+	}, {
+		b:   []byte{mapBinaryVersion, 1, 0xff},
+		err: "unknown type tag 255",
+	}, {
+		b:   []byte{mapBinaryVersion, 1, tagString, 1, 'a', tagKey, tagKey, tagString, 1, 'x'},
+		err: "invalid type for key: key.strKey",
+	}, {
+		b:   []byte{mapBinaryVersion, 1, tagString, 1, 'a', tagKey, tagKeyMap, 0},
+		err: "invalid type for key: *key.Map",
+	}}
+	for i, tcase := range tests {
+		m := NewMap("untouched", true)
+		err := m.UnmarshalBinary(tcase.b)
+		if err == nil || !strings.HasPrefix(err.Error(), tcase.err) {
+			t.Errorf("Test %d failed: expected error %q, got %v", i, tcase.err, err)
+		}
+		if !m.Equal(NewMap("untouched", true)) {
+			t.Errorf("Test %d failed: map modified by failed decoding: %v", i, m)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Ranks of the groups of types ordered by Compare.
+const (
+	rankNil = iota
+	rankBool
+	rankNumber
+	rankString
+	rankBytes
+	rankMap
+	rankSlice
+	rankPath
+	rankPointer
+	rankOther
+)
+
+// Compare returns an integer comparing two keys or values. The
+// result is 0 if a and b are equal, -1 if a sorts before b and +1
+// if a sorts after b. Keys are compared by the values they wrap.
+// Values of different kinds are ordered as follows: nil, booleans,
+// numbers, strings, byte slices, map[string]interface{},
+// []interface{}, Paths, Pointers and finally any other type.
+// Numbers of any type are ordered by their numerical value, and
+// numbers of different types with the same value are ordered by
+// type; NaNs sort before any other number. Strings and byte slices
+// are compared lexically, and Paths and []interface{} element by
+// element. Maps are ordered by their sorted keys then by the values
+// associated to those keys. Values of any other type are ordered by
+// type name then by their string representation, so two such values
+// that aren't equal but have the same string representation compare
+// as 0.
+func Compare(a, b interface{}) int {
+	if k, ok := a.(Key); ok {
+		a = keyValue(k)
+	}
+	if k, ok := b.(Key); ok {
+		b = keyValue(k)
+	}
+	rankA, rankB := compareRank(a), compareRank(b)
+	if rankA != rankB {
+		return compareInts(int64(rankA), int64(rankB))
+	}
+	switch rankA {
+	case rankNil:
+		return 0
+	case rankBool:
+		return compareBools(a.(bool), b.(bool))
+	case rankNumber:
+		return compareNumbers(a, b)
+	case rankString:
+		return strings.Compare(a.(string), b.(string))
+	case rankBytes:
+		return strings.Compare(string(a.([]byte)), string(b.([]byte)))
+	case rankMap:
+		return compareMaps(a.(map[string]interface{}), b.(map[string]interface{}))
+	case rankSlice:
+		return compareSlices(a.([]interface{}), b.([]interface{}))
+	case rankPath:
+		return comparePaths(a.(Path), b.(Path))
+	case rankPointer:
+		return comparePaths(a.(Pointer).Pointer(), b.(Pointer).Pointer())
+	}
+	if keyEqual(a, b) {
+		return 0
+	}
+	if c := strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b)); c != 0 {
+		return c
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// keyValue returns the value wrapped by k, preserving the type of
+// keys created from a []byte.
+func keyValue(k Key) interface{} {
+	if k, ok := k.(bytesKey); ok {
+		return []byte(k)
+	}
+	return k.Key()
+}
+
+func compareRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return rankNil
+	case bool:
+		return rankBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return rankNumber
+	case string:
+		return rankString
+	case []byte:
+		return rankBytes
+	case map[string]interface{}:
+		return rankMap
+	case []interface{}:
+		return rankSlice
+	case Path:
+		return rankPath
+	case Pointer:
+		return rankPointer
+	}
+	return rankOther
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareUints(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareBools(a, b bool) int {
+	if a == b {
+		return 0
+	} else if !a {
+		return -1
+	}
+	return 1
+}
+
+// number holds any number as either an int64, a uint64 or a float64.
+type number struct {
+	kind int // 0 for signed, 1 for unsigned, 2 for float
+	i    int64
+	u    uint64
+	f    float64
+	// typ orders numbers of different types with the same value.
+	typ int
+}
+
+func toNumber(v interface{}) number {
+	switch v := v.(type) {
+	case int:
+		return number{kind: 0, i: int64(v), typ: 0}
+	case int8:
+		return number{kind: 0, i: int64(v), typ: 1}
+	case int16:
+		return number{kind: 0, i: int64(v), typ: 2}
+	case int32:
+		return number{kind: 0, i: int64(v), typ: 3}
+	case int64:
+		return number{kind: 0, i: v, typ: 4}
+	case uint:
+		return number{kind: 1, u: uint64(v), typ: 5}
+	case uint8:
+		return number{kind: 1, u: uint64(v), typ: 6}
+	case uint16:
+		return number{kind: 1, u: uint64(v), typ: 7}
+	case uint32:
+		return number{kind: 1, u: uint64(v), typ: 8}
+	case uint64:
+		return number{kind: 1, u: v, typ: 9}
+	case float32:
+		return number{kind: 2, f: float64(v), typ: 10}
+	case float64:
+		return number{kind: 2, f: v, typ: 11}
+	}
+	panic(fmt.Sprintf("not a number: %T", v))
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case 0:
+		return float64(n.i)
+	case 1:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+func compareNumbers(a, b interface{}) int {
+	na, nb := toNumber(a), toNumber(b)
+	if c := compareNumberValues(na, nb); c != 0 {
+		return c
+	}
+	return compareInts(int64(na.typ), int64(nb.typ))
+}
+
+// compareNumberValues compares two numbers by their numerical value
+// only, whatever their types.
+func compareNumberValues(na, nb number) int {
+	switch {
+	case na.kind == 0 && nb.kind == 0:
+		return compareInts(na.i, nb.i)
+	case na.kind == 1 && nb.kind == 1:
+		return compareUints(na.u, nb.u)
+	case na.kind == 0 && nb.kind == 1:
+		if na.i < 0 {
+			return -1
+		}
+		return compareUints(uint64(na.i), nb.u)
+	case na.kind == 1 && nb.kind == 0:
+		if nb.i < 0 {
+			return 1
+		}
+		return compareUints(na.u, uint64(nb.i))
+	}
+	return compareFloats(na.float(), nb.float())
+}
+
+// CoerceEqual compares two keys like Equal, except that keys wrapping
+// numbers of different types are equal if the numbers have the same
+// numerical value, such as int64(1), uint8(1) and float64(1.0). This
+// is useful to compare keys built from values of different sources,
+// such as a JSON decoder, which decodes all numbers as float64, and Go
+// code using integers. The coercion rules are:
+//   - numbers of any type are compared by numerical value. Integers
+//     are compared exactly, whatever their signedness and size, and
+//     are converted to float64 to be compared to floats. A float32 is
+//     converted to float64, so float32(0.1) doesn't equal float64(0.1).
+//     NaNs equal no number,
+//   - booleans only equal booleans with the same value, and aren't
+//     coerced to or from numbers,
+//   - all other keys are compared with their Equal method, so no
+//     coercion happens within composite keys, such as Paths or maps.
+//
+// Either key may be nil, in which case it only equals a nil key.
+func CoerceEqual(a, b Key) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if equal, ok := numbersCoerceEqual(a.Key(), b.Key()); ok {
+		return equal
+	}
+	return a.Equal(b)
+}
+
+// numbersCoerceEqual compares a and b as CoerceEqual does if they are
+// both numbers, in which case ok is true.
+func numbersCoerceEqual(a, b interface{}) (equal, ok bool) {
+	if compareRank(a) != rankNumber || compareRank(b) != rankNumber {
+		return false, false
+	}
+	na, nb := toNumber(a), toNumber(b)
+	if na.kind == 2 && math.IsNaN(na.f) || nb.kind == 2 && math.IsNaN(nb.f) {
+		return false, true
+	}
+	return compareNumberValues(na, nb) == 0, true
+}
+
+func compareMaps(a, b map[string]interface{}) int {
+	keysA, keysB := SortedKeys(a), SortedKeys(b)
+	for i := 0; i < len(keysA) && i < len(keysB); i++ {
+		if c := strings.Compare(keysA[i], keysB[i]); c != 0 {
+			return c
+		}
+	}
+	if c := compareInts(int64(len(keysA)), int64(len(keysB))); c != 0 {
+		return c
+	}
+	for _, k := range keysA {
+		if c := Compare(a[k], b[k]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareSlices(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+func comparePaths(a, b Path) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key_test
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/aristanetworks/goarista/key"
+)
+
+func TestCompare(t *testing.T) {
+	// Each value must sort strictly before the ones that follow it.
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		math.NaN(),
+		math.Inf(-1),
+		int64(math.MinInt64),
+		int8(-1),
+		int64(-1),
+		float64(-0.5),
+		0,
+		int8(0),
+		uint64(0),
+		float32(0),
+		float64(0.5),
+		int32(1),
+		uint8(1),
+		float64(1),
+		uint64(math.MaxInt64) + 1,
+		uint64(math.MaxUint64),
+		math.Inf(1),
+		"",
+		"a",
+		"ab",
+		"b",
+		[]byte("a"),
+		map[string]interface{}{},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"a": 1, "b": 1},
+		map[string]interface{}{"a": 2, "b": 1},
+		map[string]interface{}{"b": 0},
+		[]interface{}{},
+		[]interface{}{"a"},
+		[]interface{}{"a", nil},
+		[]interface{}{"b"},
+		Path{},
+		Path{New("a")},
+		Path{New("a"), New("b")},
+		Path{New("b")},
+		NewPointer(Path{New("a")}),
+		compareMe{i: 1},
+		compareMe{i: 2},
+		customKey{i: 1},
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			var expected int
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if c := Compare(a, b); c != expected {
+				t.Errorf("Compare(%#v, %#v): expected %d, got %d", a, b, expected, c)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		a interface{}
+		b interface{}
+	}{
+		{a: New("a"), b: "a"},
+		{a: New([]byte("a")), b: []byte("a")},
+		{a: New(map[string]interface{}{"a": New("b")}), b: map[string]interface{}{"a": "b"}},
+		{a: New(Path{New("a")}), b: Path{New("a")}},
+		{a: New(customKey{i: 1}), b: customKey{i: 1}},
+	} {
+		if c := Compare(tc.a, tc.b); c != 0 {
+			t.Errorf("Compare(%#v, %#v): expected 0, got %d", tc.a, tc.b, c)
+		}
+	}
+}
+
+func TestCoerceEqual(t *testing.T) {
+	tcases := []struct {
+		a, b  Key
+		equal bool
+	}{
+		{New(int64(1)), New(float64(1)), true},
+		{New(uint8(1)), New(int32(1)), true},
+		{New(float32(1.5)), New(float64(1.5)), true},
+		{New(uint64(math.MaxUint64)), New(int64(-1)), false},
+		{New(uint64(1 << 63)), New(int64(math.MinInt64)), false},
+		{New(int64(1)), New(float64(1.5)), false},
+		{New(float32(0.1)), New(float64(0.1)), false},
+		{New(math.NaN()), New(math.NaN()), false},
+		{New(true), New(true), true},
+		{New(true), New(false), false},
+		{New(true), New(int64(1)), false},
+		{New(false), New(float64(0)), false},
+		{New("1"), New(int64(1)), false},
+		{New("foo"), New("foo"), true},
+		{New(map[string]interface{}{"a": int64(1)}),
+			New(map[string]interface{}{"a": float64(1)}), false},
+		{nil, nil, true},
+		{nil, New(nil), false},
+	}
+	for i, tcase := range tcases {
+		if CoerceEqual(tcase.a, tcase.b) != tcase.equal {
+			t.Errorf("Test %d failed: expected CoerceEqual(%#v, %#v) to be %t",
+				i, tcase.a, tcase.b, tcase.equal)
+		}
+		if CoerceEqual(tcase.b, tcase.a) != tcase.equal {
+			t.Errorf("Test %d failed: expected CoerceEqual(%#v, %#v) to be %t",
+				i, tcase.b, tcase.a, tcase.equal)
+		}
+	}
+}
@@ -4,6 +4,13 @@
 
 package key
 
+import "math"
+
+// nanHash is the hash of all float32 and float64 NaNs, which Map.Equal
+// considers equal, whereas the runtime hashes each NaN to a random
+// value.
+const nanHash uintptr = 0x7ff80001
+
 func hashInterface(v interface{}) uintptr {
 	if vv, ok := v.(Key); ok {
 		v = vv.Key()
@@ -25,8 +32,20 @@ func hashInterface(v interface{}) uintptr {
 		// as values in maps or slices (i.e
 		// not wrapped in a kay).
 		return hashSlice(pathToSlice(v))
+	case []Key:
+		return hashSlice(pathToSlice(v))
 	case Hashable:
 		return uintptr(v.Hash())
+	case float32:
+		if math.IsNaN(float64(v)) {
+			return nanHash
+		}
+		return _nilinterhash(v)
+	case float64:
+		if math.IsNaN(v) {
+			return nanHash
+		}
+		return _nilinterhash(v)
 	default:
 		return _nilinterhash(v)
 	}
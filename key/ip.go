@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// ipKey holds the canonical form of an IP address: the 4-byte form of
+// IPv4 addresses, including IPv4-mapped IPv6 addresses, and the 16-byte
+// form of any other address. An ipKey is therefore the same regardless
+// of how the address it was created from was represented.
+type ipKey string
+
+func newIPKey(ip net.IP) Key {
+	if ip == nil {
+		return ipKey("")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ipKey(ip4)
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		return ipKey(ip16)
+	}
+	panic(fmt.Sprintf("Invalid IP address for key: %#v", ip))
+}
+
+// Key interface implementation for IP addresses
+func (k ipKey) Key() interface{} {
+	if k == "" {
+		return net.IP(nil)
+	}
+	return net.IP(k)
+}
+
+func (k ipKey) String() string {
+	return net.IP(k).String()
+}
+
+func (k ipKey) GoString() string {
+	if k == "" {
+		return "key.New(net.IP(nil))"
+	}
+	return fmt.Sprintf("key.New(net.ParseIP(%q))", k.String())
+}
+
+func (k ipKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+func (k ipKey) Equal(other interface{}) bool {
+	o, ok := other.(ipKey)
+	return ok && k == o
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+//go:build go1.18
+// +build go1.18
+
+package key
+
+import (
+	"net"
+	"net/netip"
+)
+
+// newNetipKey returns the Key of a netip.Addr, which is the same as
+// the Key of the net.IP holding the same address. The zone of IPv6
+// addresses isn't part of the Key.
+func newNetipKey(intf interface{}) (Key, bool) {
+	addr, ok := intf.(netip.Addr)
+	if !ok {
+		return nil, false
+	}
+	if !addr.IsValid() {
+		return newIPKey(nil), true
+	}
+	return newIPKey(net.IP(addr.AsSlice())), true
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+//go:build go1.18
+// +build go1.18
+
+package key_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	. "github.com/aristanetworks/goarista/key"
+)
+
+func TestNetipKey(t *testing.T) {
+	tests := []struct {
+		addr netip.Addr
+		ip   net.IP
+	}{{
+		addr: netip.MustParseAddr("192.0.2.1"),
+		ip:   net.ParseIP("192.0.2.1"),
+	}, {
+		addr: netip.MustParseAddr("::ffff:192.0.2.1"),
+		ip:   net.IP{192, 0, 2, 1},
+	}, {
+		addr: netip.MustParseAddr("192.0.2.1"),
+		ip:   net.ParseIP("::ffff:192.0.2.1"),
+	}, {
+		addr: netip.MustParseAddr("2001:db8::1"),
+		ip:   net.ParseIP("2001:db8::1"),
+	}, {
+		addr: netip.MustParseAddr("fe80::1%eth0"),
+		ip:   net.ParseIP("fe80::1"),
+	}, {
+		addr: netip.Addr{},
+		ip:   nil,
+	}}
+	for i, tcase := range tests {
+		a, b := New(tcase.addr), New(tcase.ip)
+		if !a.Equal(b) || !b.Equal(a) {
+			t.Errorf("Test %d failed: expected %#v to equal %#v", i, a, b)
+		}
+		m := NewMap(b, i)
+		if v, ok := m.Get(a); !ok || v != i {
+			t.Errorf("Test %d failed: expected to find %#v in map, got %v, %t", i, a, v, ok)
+		}
+	}
+
+	if New(netip.MustParseAddr("192.0.2.1")).Equal(New(netip.MustParseAddr("::192.0.2.1"))) {
+		t.Error("expected an IPv4 address not to equal an IPv4-compatible IPv6 address")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"net"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/aristanetworks/goarista/value"
 )
@@ -42,6 +44,18 @@ type compositeKey struct {
 	sentinel uintptr
 	m        map[string]interface{}
 	s        []interface{}
+	// hash caches the hash of m, as computing it requires walking
+	// the whole map. It is nil for keys wrapping a slice.
+	hash *hashCache
+}
+
+// hashCache holds a lazily computed hash. It is shared by all the
+// copies of a compositeKey and may be filled by concurrent readers.
+type hashCache struct {
+	// h is the first field to guarantee its 64-bit alignment for
+	// atomic operations on 32-bit platforms.
+	h    uint64
+	done uint32
 }
 
 type interfaceKey struct {
@@ -64,6 +78,17 @@ type uint64Key uint64
 type float32Key float32
 type float64Key float64
 
+// complex64Key and complex128Key hold the canonical bits of the real
+// and imaginary parts of a complex number, as returned by
+// float32Bits and float64Bits.
+type complex64Key struct {
+	re, im uint32
+}
+
+type complex128Key struct {
+	re, im uint64
+}
+
 type boolKey bool
 
 type pointerKey struct {
@@ -76,6 +101,11 @@ type pathKey struct {
 
 type nilKey struct{}
 
+type hashedKey struct {
+	key  Key
+	hash uint64
+}
+
 func pathToSlice(path Path) []interface{} {
 	s := make([]interface{}, len(path))
 	for i, element := range path {
@@ -101,51 +131,113 @@ func sliceToPointer(s []interface{}) pointer {
 }
 
 // New wraps the given value in a Key.
-// This function panics if the value passed in isn't allowed in a Key or
-// doesn't implement value.Value.
+// This function panics if the value passed in isn't allowed in a Key,
+// doesn't implement value.Value and its type wasn't registered with
+// RegisterKeyType.
+// IP addresses, either as a net.IP or, with Go 1.18 or later, as a
+// netip.Addr, are wrapped in a Key holding their canonical form, such
+// that the same address yields equal Keys whatever its representation,
+// including IPv4-mapped IPv6 addresses and their IPv4 counterparts. The
+// Key method of such Keys returns a net.IP.
+// Complex numbers are wrapped in a Key holding their canonical form,
+// where NaN real or imaginary parts are replaced by a single NaN and
+// negative zeros by positive zeros, such that Keys wrapping NaN
+// components are equal, unlike the NaN floats they wrap, and Keys
+// wrapping signed zeros are equal, like the zeros they wrap. The Key
+// method of such Keys returns the canonical complex number.
+// The map[string]interface{} wrapped by a Key must not be modified
+// after the call to New, since the Key caches its hash.
 func New(intf interface{}) Key {
+	k, ok := newKey(intf)
+	if !ok {
+		panic(fmt.Sprintf("Invalid type for key: %T", intf))
+	}
+	return k
+}
+
+// NewSafe wraps the given value in a Key, like New, but returns an
+// error naming the type of the value instead of panicking if the value
+// isn't allowed in a Key. This is useful to wrap values of unknown
+// types, such as values supplied by users.
+func NewSafe(intf interface{}) (Key, error) {
+	k, ok := newKey(intf)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for key: %T", intf)
+	}
+	return k, nil
+}
+
+// newKey wraps the given value in a Key, and returns false if the
+// value isn't allowed in a Key.
+func newKey(intf interface{}) (Key, bool) {
 	switch t := intf.(type) {
 	case nil:
-		return nilKey{}
+		return nilKey{}, true
 	case map[string]interface{}:
-		return compositeKey{sentinel: sentinel, m: t}
+		return compositeKey{sentinel: sentinel, m: t, hash: &hashCache{}}, true
 	case []interface{}:
-		return compositeKey{sentinel: sentinel, s: t}
+		return compositeKey{sentinel: sentinel, s: t}, true
 	case string:
-		return strKey(t)
+		return strKey(t), true
 	case int8:
-		return int8Key(t)
+		return int8Key(t), true
 	case int16:
-		return int16Key(t)
+		return int16Key(t), true
 	case int32:
-		return int32Key(t)
+		return int32Key(t), true
 	case int64:
-		return int64Key(t)
+		return int64Key(t), true
 	case uint8:
-		return uint8Key(t)
+		return uint8Key(t), true
 	case uint16:
-		return uint16Key(t)
+		return uint16Key(t), true
 	case uint32:
-		return uint32Key(t)
+		return uint32Key(t), true
 	case uint64:
-		return uint64Key(t)
+		return uint64Key(t), true
 	case float32:
-		return float32Key(t)
+		return float32Key(t), true
 	case float64:
-		return float64Key(t)
+		return float64Key(t), true
+	case complex64:
+		return complex64Key{re: float32Bits(real(t)), im: float32Bits(imag(t))}, true
+	case complex128:
+		return complex128Key{re: float64Bits(real(t)), im: float64Bits(imag(t))}, true
 	case bool:
-		return boolKey(t)
+		return boolKey(t), true
 	case value.Value:
-		return interfaceKey{key: intf}
+		return interfaceKey{key: intf}, true
 	case Pointer:
-		return pointerKey{compositeKey{sentinel: sentinel, s: pointerToSlice(t)}}
+		return pointerKey{compositeKey{sentinel: sentinel, s: pointerToSlice(t)}}, true
 	case []byte:
-		return bytesKey(t)
+		return bytesKey(t), true
 	case Path:
-		return pathKey{compositeKey{sentinel: sentinel, s: pathToSlice(t)}}
+		return pathKey{compositeKey{sentinel: sentinel, s: pathToSlice(t)}}, true
+	case net.IP:
+		return newIPKey(t), true
 	default:
-		panic(fmt.Sprintf("Invalid type for key: %T", intf))
+		if k, ok := newNetipKey(intf); ok {
+			return k, true
+		}
+		return newRegisteredKey(intf)
+	}
+}
+
+// NewWithHash wraps the given value in a Key, like New, but the
+// returned Key is Hashable and its Hash method returns h instead of
+// computing a hash from the value. This is useful to store values in
+// a Map when a good hash of them is already known. Equality is still
+// determined by comparing the values. Supplying hashes that differ
+// for equal values breaks the invariants of Map, which then fails to
+// find entries stored with such keys. Keys created by NewWithHash are
+// stored separately from keys created by New in a Map, so a Map entry
+// set with one can't be retrieved with the other.
+func NewWithHash(intf interface{}, h uint64) Key {
+	k, ok := intf.(Key)
+	if !ok {
+		k = New(intf)
 	}
+	return hashedKey{key: k, hash: h}
 }
 
 func (k interfaceKey) Key() interface{} {
@@ -231,6 +323,11 @@ func keyEqual(a, b interface{}) bool {
 	case Path:
 		b, ok := b.(Path)
 		return ok && pathEqual(a, b)
+	case []Key:
+		// Unlike Path, []Key isn't comparable, so such values,
+		// stored as-is in Maps, are compared element by element.
+		b, ok := b.([]Key)
+		return ok && pathEqual(a, b)
 	}
 
 	return a == b
@@ -267,7 +364,16 @@ func (k compositeKey) Equal(other interface{}) bool {
 // make compositeKey a Hashable
 func (k compositeKey) Hash() uint64 {
 	if k.m != nil {
-		return uint64(hashMapString(k.m))
+		if k.hash == nil {
+			return uint64(hashMapString(k.m))
+		}
+		if atomic.LoadUint32(&k.hash.done) != 0 {
+			return atomic.LoadUint64(&k.hash.h)
+		}
+		h := uint64(hashMapString(k.m))
+		atomic.StoreUint64(&k.hash.h, h)
+		atomic.StoreUint32(&k.hash.done, 1)
+		return h
 	} else if k.s != nil {
 		return uint64(hashSlice(k.s))
 	}
@@ -537,6 +643,76 @@ func (k float64Key) Equal(other interface{}) bool {
 	return ok && k == o
 }
 
+// float32Bits returns the bits of f, with all NaNs mapped to the same
+// NaN and -0 mapped to +0.
+func float32Bits(f float32) uint32 {
+	switch {
+	case math.IsNaN(float64(f)):
+		return 0x7fc00000
+	case f == 0:
+		return 0
+	}
+	return math.Float32bits(f)
+}
+
+// float64Bits returns the bits of f, with all NaNs mapped to the same
+// NaN and -0 mapped to +0.
+func float64Bits(f float64) uint64 {
+	switch {
+	case math.IsNaN(f):
+		return 0x7ff8000000000001
+	case f == 0:
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+// Key interface implementation for complex64
+func (k complex64Key) Key() interface{} {
+	return complex(math.Float32frombits(k.re), math.Float32frombits(k.im))
+}
+
+func (k complex64Key) String() string {
+	return "c" + strconv.FormatInt(int64(k.re), 10) + "i" + strconv.FormatInt(int64(k.im), 10)
+}
+
+func (k complex64Key) GoString() string {
+	return fmt.Sprintf("key.New(complex64%v)", k.Key())
+}
+
+func (k complex64Key) MarshalJSON() ([]byte, error) {
+	// JSON has no complex numbers.
+	return []byte(strconv.Quote(fmt.Sprint(k.Key()))), nil
+}
+
+func (k complex64Key) Equal(other interface{}) bool {
+	o, ok := other.(complex64Key)
+	return ok && k == o
+}
+
+// Key interface implementation for complex128
+func (k complex128Key) Key() interface{} {
+	return complex(math.Float64frombits(k.re), math.Float64frombits(k.im))
+}
+
+func (k complex128Key) String() string {
+	return "c" + strconv.FormatInt(int64(k.re), 10) + "i" + strconv.FormatInt(int64(k.im), 10)
+}
+
+func (k complex128Key) GoString() string {
+	return fmt.Sprintf("key.New(complex128%v)", k.Key())
+}
+
+func (k complex128Key) MarshalJSON() ([]byte, error) {
+	// JSON has no complex numbers.
+	return []byte(strconv.Quote(fmt.Sprint(k.Key()))), nil
+}
+
+func (k complex128Key) Equal(other interface{}) bool {
+	o, ok := other.(complex128Key)
+	return ok && k == o
+}
+
 // Key interface implementation for bool
 func (k boolKey) Key() interface{} {
 	return bool(k)
@@ -615,6 +791,32 @@ func (k pathKey) Equal(other interface{}) bool {
 	return ok && sliceToPath(k.s).Equal(key.Key())
 }
 
+// Key interface implementation for keys with a precomputed hash
+func (k hashedKey) Key() interface{} {
+	return k.key.Key()
+}
+
+func (k hashedKey) String() string {
+	return k.key.String()
+}
+
+func (k hashedKey) GoString() string {
+	return fmt.Sprintf("key.NewWithHash(%#v, %d)", k.key.Key(), k.hash)
+}
+
+func (k hashedKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.key)
+}
+
+func (k hashedKey) Equal(other interface{}) bool {
+	o, ok := other.(hashedKey)
+	return ok && k.key.Equal(o.key)
+}
+
+func (k hashedKey) Hash() uint64 {
+	return k.hash
+}
+
 // Key interface implementation for nil
 func (k nilKey) Key() interface{} {
 	return nil
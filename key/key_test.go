@@ -7,7 +7,10 @@ package key_test
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"strconv"
+	"sync"
 	"testing"
 
 	. "github.com/aristanetworks/goarista/key"
@@ -558,6 +561,328 @@ func TestMisc(t *testing.T) {
 	}
 }
 
+func TestNewWithHash(t *testing.T) {
+	blob := map[string]interface{}{"sha": "0123abcd", "size": uint64(42)}
+	a := NewWithHash(blob, 0x0123abcd)
+	b := NewWithHash(map[string]interface{}{"sha": "0123abcd", "size": uint64(42)}, 0x0123abcd)
+	c := NewWithHash(New(map[string]interface{}{"sha": "4567ef01"}), 0x4567ef01)
+
+	if h, ok := a.(Hashable); !ok || h.Hash() != 0x0123abcd {
+		t.Errorf("expected %#v to be Hashable with the provided hash", a)
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("expected %#v to equal %#v", a, b)
+	}
+	if a.Equal(c) {
+		t.Errorf("expected %#v not to equal %#v", a, c)
+	}
+	if !Equal(a.Key(), blob) {
+		t.Errorf("expected Key() to return %#v, got %#v", blob, a.Key())
+	}
+	if expected, s := New(blob).String(), a.String(); s != expected {
+		t.Errorf("expected string %q, got %q", expected, s)
+	}
+	if js, err := json.Marshal(a); err != nil {
+		t.Errorf("JSON encoding failed: %s", err)
+	} else if expected := `{"sha":"0123abcd","size":42}`; string(js) != expected {
+		t.Errorf("Wanted JSON %q but got %q", expected, js)
+	}
+	expected := `key.NewWithHash(map[string]interface {}{"sha":"4567ef01"}, 1164439297)`
+	if s := fmt.Sprintf("%#v", c); s != expected {
+		t.Errorf("Wanted Go representation %q but got %q", expected, s)
+	}
+
+	m := NewMap(a, "a", c, "c")
+	if v, ok := m.Get(b); !ok || v != "a" {
+		t.Errorf("expected to find %#v in map with value \"a\", got %v, %t", b, v, ok)
+	}
+	if v, ok := m.Get(c); !ok || v != "c" {
+		t.Errorf("expected to find %#v in map with value \"c\", got %v, %t", c, v, ok)
+	}
+	if _, ok := m.Get(NewWithHash(blob, 0)); ok {
+		t.Errorf("expected a key with an inconsistent hash not to be found")
+	}
+
+	test.ShouldPanic(t, func() { NewWithHash(42, 42) })
+}
+
+func TestCompositeKeyHashCache(t *testing.T) {
+	m := map[string]interface{}{"a": uint32(1), "b": map[string]interface{}{"c": "d"}}
+	other := New(map[string]interface{}{"a": uint32(1), "b": map[string]interface{}{"c": "d"}})
+	expected := other.(Hashable).Hash()
+	k := New(m)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if h := k.(Hashable).Hash(); h != expected {
+				t.Errorf("expected hash %d, got %d", expected, h)
+			}
+		}()
+	}
+	wg.Wait()
+	// Mutating the map after New breaks the contract of New, and here
+	// shows that the hash computed before was cached.
+	m["a"] = uint32(2)
+	if h := k.(Hashable).Hash(); h != expected {
+		t.Errorf("expected cached hash %d, got %d", expected, h)
+	}
+	if h := New(m).(Hashable).Hash(); h == expected {
+		t.Errorf("expected a new key to have a hash different from %d", expected)
+	}
+}
+
+func TestUint64Keys(t *testing.T) {
+	// u and i share the same 64 bits.
+	u := uint64(math.MaxInt64) + 42
+	i := int64(u)
+	uk, ik := New(u), New(i)
+	if uk.Equal(ik) || ik.Equal(uk) {
+		t.Errorf("expected %#v and %#v to be distinct keys", uk, ik)
+	}
+	if v, ok := uk.Key().(uint64); !ok || v != u {
+		t.Errorf("expected %#v to wrap uint64(%d), got %T(%v)", uk, u, uk.Key(), uk.Key())
+	}
+	if Compare(uk, ik) != 1 || Compare(ik, uk) != -1 {
+		t.Errorf("expected %#v to sort after %#v", uk, ik)
+	}
+	if s := uk.String(); s != "9223372036854775849" {
+		t.Errorf("unexpected string for %#v: %s", uk, s)
+	}
+
+	m := NewMap(uk, "u", ik, "i",
+		New(map[string]interface{}{"a": u}), "mu",
+		New(map[string]interface{}{"a": i}), "mi",
+		New(Path{uk}), "pu",
+		New(Path{ik}), "pi")
+	if m.Len() != 6 {
+		t.Fatalf("expected 6 distinct keys, got %s", m)
+	}
+	for _, tcase := range []struct {
+		k        Key
+		expected string
+	}{
+		{New(u), "u"},
+		{New(i), "i"},
+		{New(map[string]interface{}{"a": u}), "mu"},
+		{New(map[string]interface{}{"a": i}), "mi"},
+		{New(Path{New(u)}), "pu"},
+		{New(Path{New(i)}), "pi"},
+	} {
+		if v, ok := m.Get(tcase.k); !ok || v != tcase.expected {
+			t.Errorf("expected %#v to map to %q, got %v (%t)", tcase.k, tcase.expected, v, ok)
+		}
+	}
+	if v, ok := m.Get(New(uint64(42))); ok {
+		t.Errorf("unexpected value %v for a key holding the low bits of %d", v, u)
+	}
+}
+
+func TestComplexKeys(t *testing.T) {
+	nan := math.NaN()
+	otherNaN := math.Float64frombits(math.Float64bits(nan) + 1)
+	negZero := math.Copysign(0, -1)
+	tcases := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{a: complex(1, 2), b: complex(1, 2), equal: true},
+		{a: complex(1, 2), b: complex(2, 1), equal: false},
+		{a: complex(nan, 1), b: complex(nan, 1), equal: true},
+		{a: complex(1, nan), b: complex(1, otherNaN), equal: true},
+		{a: complex(nan, nan), b: complex(otherNaN, -nan), equal: true},
+		{a: complex(nan, 1), b: complex(1, nan), equal: false},
+		{a: complex(negZero, 0), b: complex(0, 0), equal: true},
+		{a: complex(0, negZero), b: complex(negZero, 0), equal: true},
+		{a: complex64(complex(nan, 1)), b: complex64(complex(otherNaN, 1)), equal: true},
+		{a: complex64(complex(negZero, 1)), b: complex64(complex(0, 1)), equal: true},
+		{a: complex64(complex(1, 2)), b: complex(1, 2), equal: false},
+	}
+	for i, tcase := range tcases {
+		a, b := New(tcase.a), New(tcase.b)
+		if a.Equal(b) != tcase.equal || b.Equal(a) != tcase.equal {
+			t.Errorf("Test %d failed: expected %#v.Equal(%#v) to be %t",
+				i, a, b, tcase.equal)
+		}
+		if (a.String() == b.String()) != tcase.equal {
+			t.Errorf("Test %d failed: unexpected strings %s and %s", i, a, b)
+		}
+		m := NewMap(a, "a")
+		if _, ok := m.Get(b); ok != tcase.equal {
+			t.Errorf("Test %d failed: expected Map lookup of %#v to return %t",
+				i, b, tcase.equal)
+		}
+		if (KeyString(a) == KeyString(b)) != tcase.equal {
+			t.Errorf("Test %d failed: unexpected key strings %s and %s",
+				i, KeyString(a), KeyString(b))
+		}
+		for _, k := range []Key{a, b} {
+			if parsed, err := ParseKeyString(KeyString(k)); err != nil || !parsed.Equal(k) {
+				t.Errorf("Test %d failed: round trip of %#v via %q returned %#v (%v)",
+					i, k, KeyString(k), parsed, err)
+			}
+		}
+		m.Set("value", tcase.b)
+		encoded, err := m.MarshalBinary()
+		if err != nil {
+			t.Errorf("Test %d failed: %s", i, err)
+			continue
+		}
+		var decoded Map
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Errorf("Test %d failed: %s", i, err)
+		} else if v, ok := decoded.Get(a); !ok || v != "a" {
+			t.Errorf("Test %d failed: expected %#v to be decoded, got %v", i, a, &decoded)
+		} else if v, _ := decoded.Get("value"); !New(v).Equal(b) {
+			t.Errorf("Test %d failed: expected value %#v to be decoded, got %#v", i, b, v)
+		}
+	}
+
+	for _, tcase := range []struct {
+		k Key
+		s string
+	}{
+		{k: New(complex(1, 2)), s: "c128:1+2i"},
+		{k: New(complex64(complex(1.5, -2))), s: "c64:1.5-2i"},
+		{k: New(complex(-1e-07, 1e+21)), s: "c128:-1e-07+1e+21i"},
+		{k: New(complex(nan, math.Inf(-1))), s: "c128:NaN-Infi"},
+		{k: New(complex(math.Inf(1), nan)), s: "c128:+Inf+NaNi"},
+		{k: New(complex(negZero, negZero)), s: "c128:0+0i"},
+	} {
+		if s := KeyString(tcase.k); s != tcase.s {
+			t.Errorf("expected %q for %#v, got %q", tcase.s, tcase.k, s)
+		}
+		if k, err := ParseKeyString(tcase.s); err != nil || !k.Equal(tcase.k) {
+			t.Errorf("expected %q to be parsed as %#v, got %#v (%v)", tcase.s, tcase.k, k, err)
+		}
+	}
+	for _, s := range []string{"c128:1+2", "c128:1", "c128:i", "c128:+2i", "c64:1+1e39i",
+		"c128:a+bi"} {
+		if k, err := ParseKeyString(s); err == nil {
+			t.Errorf("expected error parsing %q, got %#v", s, k)
+		}
+	}
+
+	k := New(complex(negZero, nan))
+	c, ok := k.Key().(complex128)
+	if !ok || math.Signbit(real(c)) || !math.IsNaN(imag(c)) {
+		t.Errorf("expected %#v to wrap the canonical complex128, got %T(%v)",
+			k, k.Key(), k.Key())
+	}
+	if s := fmt.Sprintf("%#v", New(complex64(complex(1, 2)))); s != "key.New(complex64(1+2i))" {
+		t.Errorf("unexpected GoString: %s", s)
+	}
+	if b, err := New(complex(1, -2)).(json.Marshaler).MarshalJSON(); err != nil ||
+		string(b) != `"(1-2i)"` {
+		t.Errorf("unexpected JSON: %s (%v)", b, err)
+	}
+}
+
+func TestNewSafe(t *testing.T) {
+	tcases := []struct {
+		in  interface{}
+		err string
+	}{
+		{in: nil},
+		{in: "foo"},
+		{in: int8(-1)},
+		{in: uint64(1)},
+		{in: 1.5},
+		{in: complex(1, 2)},
+		{in: true},
+		{in: []byte{1, 2}},
+		{in: []interface{}{"a", uint32(1)}},
+		{in: map[string]interface{}{"a": int64(1)}},
+		{in: Path{New("a")}},
+		{in: NewPointer(Path{New("a")})},
+		{in: net.ParseIP("192.0.2.1")},
+		{in: customKey{i: 1}},
+		{in: 1, err: "invalid type for key: int"},
+		{in: uint(1), err: "invalid type for key: uint"},
+		{in: struct{}{}, err: "invalid type for key: struct {}"},
+		{in: []string{"a"}, err: "invalid type for key: []string"},
+		{in: map[int]string{}, err: "invalid type for key: map[int]string"},
+		{in: compareMe{i: 1}, err: "invalid type for key: key_test.compareMe"},
+	}
+	for i, tcase := range tcases {
+		k, err := NewSafe(tcase.in)
+		if tcase.err != "" {
+			if err == nil || err.Error() != tcase.err {
+				t.Errorf("Test %d failed: expected error %q, got %v", i, tcase.err, err)
+			}
+			if k != nil {
+				t.Errorf("Test %d failed: expected no key, got %#v", i, k)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d failed: unexpected error: %s", i, err)
+		} else if expected := New(tcase.in); !k.Equal(expected) {
+			t.Errorf("Test %d failed: expected %#v, got %#v", i, expected, k)
+		}
+	}
+}
+
+func TestIPKey(t *testing.T) {
+	v4 := []Key{
+		New(net.ParseIP("192.0.2.1")),
+		New(net.IPv4(192, 0, 2, 1)),
+		New(net.IP{192, 0, 2, 1}),
+		New(net.ParseIP("::ffff:192.0.2.1")),
+	}
+	for i, a := range v4 {
+		for j, b := range v4 {
+			if !a.Equal(b) {
+				t.Errorf("expected key %d (%#v) to equal key %d (%#v)", i, a, j, b)
+			}
+		}
+		if s := a.String(); s != "192.0.2.1" {
+			t.Errorf("expected string \"192.0.2.1\", got %q", s)
+		}
+	}
+	v6 := New(net.ParseIP("2001:db8::1"))
+	if !v6.Equal(New(net.ParseIP("2001:0db8:0:0:0:0:0:1"))) {
+		t.Errorf("expected %#v to equal the same address written differently", v6)
+	}
+	for _, k := range []Key{
+		New(net.ParseIP("192.0.2.2")),
+		New(net.ParseIP("::192.0.2.1")),
+		New(net.IP(nil)),
+		New([]byte{192, 0, 2, 1}),
+		New("192.0.2.1"),
+	} {
+		if v4[0].Equal(k) || k.Equal(v4[0]) {
+			t.Errorf("expected %#v not to equal %#v", v4[0], k)
+		}
+	}
+
+	if ip, ok := v4[0].Key().(net.IP); !ok || !ip.Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("expected Key() to return a net.IP, got %#v", v4[0].Key())
+	}
+	expected := `key.New(net.ParseIP("2001:db8::1"))`
+	if s := fmt.Sprintf("%#v", v6); s != expected {
+		t.Errorf("Wanted Go representation %q but got %q", expected, s)
+	}
+	if js, err := json.Marshal(v6); err != nil {
+		t.Errorf("JSON encoding failed: %s", err)
+	} else if expected := `"2001:db8::1"`; string(js) != expected {
+		t.Errorf("Wanted JSON %q but got %q", expected, js)
+	}
+
+	m := NewMap(v4[0], "v4", v6, "v6")
+	for _, k := range v4 {
+		if v, ok := m.Get(k); !ok || v != "v4" {
+			t.Errorf("expected to find %#v in map with value \"v4\", got %v, %t", k, v, ok)
+		}
+	}
+	if v, ok := m.Get(New(net.ParseIP("2001:db8::1"))); !ok || v != "v6" {
+		t.Errorf("expected to find %#v in map with value \"v6\", got %v, %t", v6, v, ok)
+	}
+
+	test.ShouldPanic(t, func() { New(net.IP{1, 2, 3}) })
+}
+
 func BenchmarkSetToMapWithStringKey(b *testing.B) {
 	m := NewMap(New("a"), true,
 		New("a1"), true,
@@ -670,13 +995,44 @@ func BenchmarkBigMapWithCompositeKeys(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		submap["aaaa3"] = uint32(i)
-		_, found := m.Get(k)
+		// Keys cache their hash, so the mutated map needs a new Key.
+		_, found := m.Get(New(k.Key()))
 		if found != (i < size) {
 			b.Fatalf("WTF: %#v", k)
 		}
 	}
 }
 
+func BenchmarkCompositeKeyHash(b *testing.B) {
+	m := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		m[strconv.Itoa(i)] = map[string]interface{}{"a": uint32(i), "b": "foo"}
+	}
+	keys := NewMap()
+	for i := 0; i < 100; i++ {
+		keys.Set(mkKey(i), true)
+	}
+	keys.Set(New(m), true)
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, found := keys.Get(New(m)); !found {
+				b.Fatal("key not found")
+			}
+		}
+	})
+	b.Run("Reused", func(b *testing.B) {
+		k := New(m)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, found := keys.Get(k); !found {
+				b.Fatal("key not found")
+			}
+		}
+	})
+}
+
 func BenchmarkBuiltInType(b *testing.B) {
 	benches := []struct {
 		val interface{}
@@ -0,0 +1,404 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// KeyString returns a canonical string representation of k, where
+// the value wrapped by k is prefixed by a tag identifying its type,
+// such that keys wrapping values of different types, like int64(5)
+// and "5", never have the same representation. The tags are:
+//   - "n:" for nil,
+//   - "b:" for a bool, as in "b:true",
+//   - "i:", "i8:", "i16:", "i32:" and "i64:" for signed integers,
+//     and "u:", "u8:", "u16:", "u32:" and "u64:" for unsigned
+//     integers, as in "i64:-5",
+//   - "f32:" and "f64:" for floats, as in "f64:1.5",
+//   - "c64:" and "c128:" for complex numbers, with their real and
+//     imaginary parts, as in "c128:1.5-2i",
+//   - "s:" for a string, quoted as a Go string literal, as in "s:\"foo\"",
+//   - "x:" for a []byte, in hexadecimal, as in "x:0aff",
+//   - "m:" for a map[string]interface{}, with its entries sorted by
+//     key, as in "m:{\"a\":i64:1,\"b\":s:\"c\"}",
+//   - "l:" for a []interface{}, as in "l:[i64:1,s:\"a\"]",
+//   - "p:" for a Path and "ptr:" for a Pointer, with their elements
+//     listed as in "p:[s:\"a\",u8:1]",
+//   - "ip:" for an IP address, as in "ip:192.0.2.1",
+//   - "v:" for any other value, such as a value.Value, followed by its
+//     type and its string representation, both quoted as Go string
+//     literals, as in "v:\"path.WildcardType\"\"*\"".
+//
+// The same values always yield the same representation, which can be
+// parsed back to a Key with ParseKeyString, except for values of the
+// "v:" kind, whose types can't be reconstructed. Values of the "v:"
+// kind are told apart by their types and string representations, so
+// values of the same type with the same string representation have
+// the same representation.
+func KeyString(k Key) string {
+	var b strings.Builder
+	writeKeyString(&b, keyValue(k))
+	return b.String()
+}
+
+func writeKeyString(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("n:")
+	case bool:
+		b.WriteString("b:" + strconv.FormatBool(v))
+	case int:
+		b.WriteString("i:" + strconv.FormatInt(int64(v), 10))
+	case int8:
+		b.WriteString("i8:" + strconv.FormatInt(int64(v), 10))
+	case int16:
+		b.WriteString("i16:" + strconv.FormatInt(int64(v), 10))
+	case int32:
+		b.WriteString("i32:" + strconv.FormatInt(int64(v), 10))
+	case int64:
+		b.WriteString("i64:" + strconv.FormatInt(v, 10))
+	case uint:
+		b.WriteString("u:" + strconv.FormatUint(uint64(v), 10))
+	case uint8:
+		b.WriteString("u8:" + strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		b.WriteString("u16:" + strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		b.WriteString("u32:" + strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		b.WriteString("u64:" + strconv.FormatUint(v, 10))
+	case float32:
+		b.WriteString("f32:" + strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		b.WriteString("f64:" + strconv.FormatFloat(v, 'g', -1, 64))
+	case complex64:
+		b.WriteString("c64:" + formatComplex(float64(real(v)), float64(imag(v)), 32))
+	case complex128:
+		b.WriteString("c128:" + formatComplex(real(v), imag(v), 64))
+	case string:
+		b.WriteString("s:" + strconv.Quote(v))
+	case []byte:
+		b.WriteString("x:" + hex.EncodeToString(v))
+	case map[string]interface{}:
+		b.WriteString("m:{")
+		for i, k := range SortedKeys(v) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(k))
+			b.WriteByte(':')
+			writeKeyString(b, v[k])
+		}
+		b.WriteByte('}')
+	case []interface{}:
+		b.WriteString("l:[")
+		for i, element := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeKeyString(b, element)
+		}
+		b.WriteByte(']')
+	case Path:
+		b.WriteString("p:")
+		writePathKeyString(b, v)
+	case Pointer:
+		b.WriteString("ptr:")
+		writePathKeyString(b, v.Pointer())
+	case net.IP:
+		b.WriteString("ip:" + v.String())
+	case Key:
+		writeKeyString(b, keyValue(v))
+	default:
+		b.WriteString("v:" + strconv.Quote(fmt.Sprintf("%T", v)) + strconv.Quote(fmt.Sprint(v)))
+	}
+}
+
+// formatComplex formats the complex number with real part re and
+// imaginary part im, with the given bit size, as "<re><sign><im>i".
+func formatComplex(re, im float64, bitSize int) string {
+	imag := strconv.FormatFloat(im, 'g', -1, bitSize)
+	if imag[0] != '+' && imag[0] != '-' {
+		imag = "+" + imag
+	}
+	return strconv.FormatFloat(re, 'g', -1, bitSize) + imag + "i"
+}
+
+// parseComplex parses a complex number formatted by formatComplex.
+func parseComplex(s string, bitSize int) (re, im float64, err error) {
+	if len(s) < 2 || s[len(s)-1] != 'i' {
+		return 0, 0, fmt.Errorf("invalid complex number %q", s)
+	}
+	s = s[:len(s)-1]
+	// The imaginary part starts at the last sign that doesn't follow
+	// an exponent marker, but isn't the first character.
+	i := len(s) - 1
+	for ; i > 0; i-- {
+		if (s[i] == '+' || s[i] == '-') && s[i-1] != 'e' {
+			break
+		}
+	}
+	if i == 0 {
+		return 0, 0, fmt.Errorf("invalid complex number %q", s+"i")
+	}
+	if re, err = strconv.ParseFloat(s[:i], bitSize); err != nil {
+		return 0, 0, err
+	}
+	imag := s[i:]
+	if imag == "+NaN" {
+		imag = "NaN"
+	}
+	if im, err = strconv.ParseFloat(imag, bitSize); err != nil {
+		return 0, 0, err
+	}
+	return re, im, nil
+}
+
+func writePathKeyString(b *strings.Builder, p Path) {
+	b.WriteByte('[')
+	for i, element := range p {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeKeyString(b, keyValue(element))
+	}
+	b.WriteByte(']')
+}
+
+// ParseKeyString parses the representation of a Key returned by
+// KeyString, and returns a Key equal to the original Key.
+func ParseKeyString(s string) (Key, error) {
+	p := keyStringParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(s) {
+		return nil, p.errorf("unexpected trailing characters")
+	}
+	switch v.(type) {
+	case int, uint:
+		return nil, fmt.Errorf("invalid type for key: %T", v)
+	}
+	return New(v), nil
+}
+
+type keyStringParser struct {
+	s string
+	i int
+}
+
+func (p *keyStringParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid key string %q at offset %d: %s", p.s, p.i,
+		fmt.Sprintf(format, args...))
+}
+
+// token returns the characters up to the next delimiter.
+func (p *keyStringParser) token() string {
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(",]}", rune(p.s[p.i])) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// quoted returns the unquoted value of the Go string literal starting
+// at the current position.
+func (p *keyStringParser) quoted() (string, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '"' {
+		return "", p.errorf("expected quoted string")
+	}
+	end := p.i + 1
+	for end < len(p.s) && p.s[end] != '"' {
+		if p.s[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.s) {
+		return "", p.errorf("unterminated quoted string")
+	}
+	s, err := strconv.Unquote(p.s[p.i : end+1])
+	if err != nil {
+		return "", p.errorf("%s", err)
+	}
+	p.i = end + 1
+	return s, nil
+}
+
+func (p *keyStringParser) expect(c byte) error {
+	if p.i >= len(p.s) || p.s[p.i] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.i++
+	return nil
+}
+
+// list parses a list of values delimited by open and close, calling f
+// to parse each value.
+func (p *keyStringParser) list(open, close byte, f func() error) error {
+	if err := p.expect(open); err != nil {
+		return err
+	}
+	if p.i < len(p.s) && p.s[p.i] == close {
+		p.i++
+		return nil
+	}
+	for {
+		if err := f(); err != nil {
+			return err
+		}
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+			continue
+		}
+		return p.expect(close)
+	}
+}
+
+func (p *keyStringParser) path() (Path, error) {
+	path := Path{}
+	err := p.list('[', ']', func() error {
+		v, err := p.value()
+		if err != nil {
+			return err
+		}
+		switch v.(type) {
+		case int, uint:
+			return p.errorf("invalid type for path element: %T", v)
+		}
+		path = append(path, New(v))
+		return nil
+	})
+	return path, err
+}
+
+func (p *keyStringParser) value() (interface{}, error) {
+	colon := strings.IndexByte(p.s[p.i:], ':')
+	if colon < 0 {
+		return nil, p.errorf("missing type tag")
+	}
+	tag := p.s[p.i : p.i+colon]
+	p.i += colon + 1
+	switch tag {
+	case "n":
+		return nil, nil
+	case "b":
+		return p.parse(func(s string) (interface{}, error) { return strconv.ParseBool(s) })
+	case "i", "i8", "i16", "i32", "i64":
+		bits := map[string]int{"i": 0, "i8": 8, "i16": 16, "i32": 32, "i64": 64}[tag]
+		return p.parse(func(s string) (interface{}, error) {
+			i, err := strconv.ParseInt(s, 10, bits)
+			switch tag {
+			case "i":
+				return int(i), err
+			case "i8":
+				return int8(i), err
+			case "i16":
+				return int16(i), err
+			case "i32":
+				return int32(i), err
+			}
+			return i, err
+		})
+	case "u", "u8", "u16", "u32", "u64":
+		bits := map[string]int{"u": 0, "u8": 8, "u16": 16, "u32": 32, "u64": 64}[tag]
+		return p.parse(func(s string) (interface{}, error) {
+			u, err := strconv.ParseUint(s, 10, bits)
+			switch tag {
+			case "u":
+				return uint(u), err
+			case "u8":
+				return uint8(u), err
+			case "u16":
+				return uint16(u), err
+			case "u32":
+				return uint32(u), err
+			}
+			return u, err
+		})
+	case "f32":
+		return p.parse(func(s string) (interface{}, error) {
+			f, err := strconv.ParseFloat(s, 32)
+			return float32(f), err
+		})
+	case "f64":
+		return p.parse(func(s string) (interface{}, error) {
+			return strconv.ParseFloat(s, 64)
+		})
+	case "c64":
+		return p.parse(func(s string) (interface{}, error) {
+			re, im, err := parseComplex(s, 32)
+			return complex(float32(re), float32(im)), err
+		})
+	case "c128":
+		return p.parse(func(s string) (interface{}, error) {
+			re, im, err := parseComplex(s, 64)
+			return complex(re, im), err
+		})
+	case "s":
+		return p.quoted()
+	case "x":
+		return p.parse(func(s string) (interface{}, error) { return hex.DecodeString(s) })
+	case "m":
+		m := map[string]interface{}{}
+		err := p.list('{', '}', func() error {
+			k, err := p.quoted()
+			if err != nil {
+				return err
+			}
+			if err := p.expect(':'); err != nil {
+				return err
+			}
+			m[k], err = p.value()
+			return err
+		})
+		return m, err
+	case "l":
+		s := []interface{}{}
+		err := p.list('[', ']', func() error {
+			v, err := p.value()
+			s = append(s, v)
+			return err
+		})
+		return s, err
+	case "p":
+		return p.path()
+	case "ptr":
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return NewPointer(path), nil
+	case "ip":
+		return p.parse(func(s string) (interface{}, error) {
+			ip := net.ParseIP(s)
+			if ip == nil && s != "<nil>" {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			return ip, nil
+		})
+	case "v":
+		return nil, p.errorf("values of unknown types can't be parsed")
+	}
+	return nil, p.errorf("unknown type tag %q", tag)
+}
+
+// parse calls f with the next token and returns its result, wrapping
+// any error it returns.
+func (p *keyStringParser) parse(f func(s string) (interface{}, error)) (interface{}, error) {
+	start := p.i
+	v, err := f(p.token())
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("%s", err)
+	}
+	return v, nil
+}
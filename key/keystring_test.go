@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key_test
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+
+	. "github.com/aristanetworks/goarista/key"
+	"github.com/aristanetworks/goarista/path"
+)
+
+// otherCustomKey is a value.Value with the same string representation
+// as customKey.
+type otherCustomKey struct {
+	i int
+}
+
+func (c otherCustomKey) String() string {
+	return fmt.Sprintf("customKey=%d", c.i)
+}
+
+func (c otherCustomKey) MarshalJSON() ([]byte, error) {
+	return nil, nil
+}
+
+func (c otherCustomKey) ToBuiltin() interface{} {
+	return c.i
+}
+
+func TestKeyString(t *testing.T) {
+	tests := []struct {
+		k Key
+		s string
+	}{
+		{k: New(nil), s: "n:"},
+		{k: New(true), s: "b:true"},
+		{k: New(int8(-5)), s: "i8:-5"},
+		{k: New(int16(5)), s: "i16:5"},
+		{k: New(int32(5)), s: "i32:5"},
+		{k: New(int64(5)), s: "i64:5"},
+		{k: New(uint8(5)), s: "u8:5"},
+		{k: New(uint16(5)), s: "u16:5"},
+		{k: New(uint32(5)), s: "u32:5"},
+		{k: New(uint64(math.MaxUint64)), s: "u64:18446744073709551615"},
+		{k: New(float32(1.5)), s: "f32:1.5"},
+		{k: New(float64(-0.1)), s: "f64:-0.1"},
+		{k: New(math.Inf(1)), s: "f64:+Inf"},
+		{k: New("5"), s: `s:"5"`},
+		{k: New(`a "quoted", [string]`), s: `s:"a \"quoted\", [string]"`},
+		{k: New([]byte{0x0a, 0xff}), s: "x:0aff"},
+		{k: New([]byte{}), s: "x:"},
+		{
+			k: New(map[string]interface{}{"b": "c", "a": int64(1), "d": 2}),
+			s: `m:{"a":i64:1,"b":s:"c","d":i:2}`,
+		},
+		{k: New(map[string]interface{}{}), s: "m:{}"},
+		{
+			k: New([]interface{}{int64(1), "a", []interface{}{}, map[string]interface{}{
+				"k": []interface{}{uint(1), nil}}}),
+			s: `l:[i64:1,s:"a",l:[],m:{"k":l:[u:1,n:]}]`,
+		},
+		{k: New(path.New("a", uint8(1))), s: `p:[s:"a",u8:1]`},
+		{k: New(path.New()), s: `p:[]`},
+		{
+			k: New(NewPointer(path.New("a", map[string]interface{}{"name": "x"}))),
+			s: `ptr:[s:"a",m:{"name":s:"x"}]`,
+		},
+		{k: New(net.ParseIP("192.0.2.1")), s: "ip:192.0.2.1"},
+		{k: New(net.ParseIP("2001:db8::1")), s: "ip:2001:db8::1"},
+	}
+	for i, tcase := range tests {
+		s := KeyString(tcase.k)
+		if s != tcase.s {
+			t.Errorf("Test %d failed: expected %q, got %q", i, tcase.s, s)
+		}
+		k, err := ParseKeyString(s)
+		if err != nil {
+			t.Errorf("Test %d failed: unable to parse %q: %s", i, s, err)
+		} else if !k.Equal(tcase.k) || KeyString(k) != s {
+			t.Errorf("Test %d failed: round trip of %#v via %q returned %#v", i, tcase.k, s, k)
+		}
+	}
+
+	// Keys wrapping different values must have different representations.
+	keys := []Key{New(int64(5)), New(int32(5)), New(uint64(5)), New("5"), New([]byte("5")),
+		New(float64(5)), New([]interface{}{"5"}), New(path.New("5"))}
+	seen := map[string]Key{}
+	for _, k := range keys {
+		s := KeyString(k)
+		if other, ok := seen[s]; ok {
+			t.Errorf("%#v and %#v have the same representation %q", k, other, s)
+		}
+		seen[s] = k
+	}
+
+	if s := KeyString(path.Wildcard); s != `v:"path.WildcardType""*"` {
+		t.Errorf("expected v:\"path.WildcardType\"\"*\", got %q", s)
+	}
+	// Values of other types are told apart by their types.
+	a, b := KeyString(New(customKey{i: 1})), KeyString(New(otherCustomKey{i: 1}))
+	if a == b || a != `v:"key_test.customKey""customKey=1"` {
+		t.Errorf("expected different representations, got %q and %q", a, b)
+	}
+}
+
+func TestParseKeyStringErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"5",
+		"z:5",
+		"i:5",
+		"i8:128",
+		"u8:-1",
+		"b:yes",
+		"s:foo",
+		`s:"foo`,
+		`s:"foo"bar`,
+		"x:0",
+		"m:{",
+		`m:{"a"}`,
+		`m:{a:i64:1}`,
+		`m:{"a":i64:1,}`,
+		"l:[i64:1",
+		"l:[i64:1]]",
+		"p:[i:1]",
+		"ip:not-an-ip",
+		`v:"*"`,
+	} {
+		if k, err := ParseKeyString(s); err == nil {
+			t.Errorf("expected error parsing %q, got %#v", s, k)
+		} else if !strings.Contains(err.Error(), "key") {
+			t.Errorf("unexpected error parsing %q: %s", s, err)
+		}
+	}
+}
@@ -5,17 +5,32 @@
 package key
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
 	"sort"
 	"strings"
+	"unsafe"
 )
 
 // Map allows the indexing of entries with arbitrary key types, so long as the keys are
-// either hashable natively or implement Hashable
+// either hashable natively or implement Hashable.
+// The methods of a Map that only read from it may be called on a nil *Map, which then
+// behaves like an empty Map. Methods that add entries to a Map, such as Set, panic
+// when called on a nil *Map.
 type Map struct {
 	normal map[interface{}]interface{}
 	custom map[uint64]entry
 	length int // length of the Map
+	// shared is true when the storage of the Map may be shared with
+	// a snapshot, in which case it's copied before being modified.
+	shared bool
+	hook   func(op string, k interface{})
 }
 
 // NewMap creates a new Map from a list of key-value pairs, so long as the list is of even length.
@@ -32,6 +47,122 @@ func NewMap(keysAndVals ...interface{}) *Map {
 	return &m
 }
 
+// NewMapFromJSON creates a new Map from a JSON object, as with
+// UnmarshalJSON. An error is returned if data isn't a JSON object.
+func NewMapFromJSON(data []byte) (*Map, error) {
+	m := &Map{}
+	if err := m.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UnmarshalJSON replaces the entries of the Map with the members of
+// the JSON object in data, keyed by their names. Values are decoded
+// as by encoding/json into an interface{}, except that nested objects,
+// including those within arrays, are decoded as *Map. The members are
+// decoded from a stream of JSON tokens straight into the Map and its
+// nested Maps, without building intermediate Go maps, and each Map is
+// allocated for the exact number of members of the object it holds.
+// An error is returned, and the Map left untouched, if data isn't a
+// JSON object.
+func (m *Map) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("cannot unmarshal JSON %s into key.Map, expected an object",
+			jsonKind(tok))
+	}
+	o, err := decodeJSONObject(dec)
+	if err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after top-level JSON object")
+		}
+		return err
+	}
+	m.replaceStorage(o)
+	return nil
+}
+
+// decodeJSONObject decodes the members of the JSON object whose
+// opening brace was just read from dec into a Map.
+func decodeJSONObject(dec *json.Decoder) (*Map, error) {
+	// Members are buffered in a slice, which is cheaper than a Go map,
+	// to learn the size of the Map before allocating it.
+	var members []Entry
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeJSONValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, Entry{Key: name, Value: v})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	m := &Map{normal: make(map[interface{}]interface{}, len(members))}
+	for _, member := range members {
+		m.normal[member.Key] = member.Value
+	}
+	// Duplicate names keep their last value, as with encoding/json.
+	m.length = len(m.normal)
+	return m, nil
+}
+
+// decodeJSONValue decodes the next JSON value of dec.
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		return decodeJSONObject(dec)
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+	return tok, nil
+}
+
+// jsonKind returns the kind of JSON value starting with token tok.
+func jsonKind(tok json.Token) string {
+	switch tok.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	}
+	if tok == json.Delim('[') {
+		return "array"
+	}
+	return "object"
+}
+
 // String outputs the string representation of the map
 func (m *Map) String() string {
 	if m == nil {
@@ -77,6 +208,61 @@ func (m *Map) Len() int {
 	return m.length
 }
 
+// Constants of the model used by EstimatedSize.
+const (
+	// mapHeaderSize is the approximate size of the header of a Go map.
+	mapHeaderSize = 48
+	// mapSlotOverhead is the approximate number of bytes of metadata
+	// per slot of a Go map.
+	mapSlotOverhead = 1
+	// mapLoadFactor approximates how full the slots of a Go map are
+	// on average, as a fraction of mapLoadFactorDiv.
+	mapLoadFactor    = 13
+	mapLoadFactorDiv = 16
+)
+
+// EstimatedSize returns an estimation of the number of bytes used by
+// the internal structures of the Map, excluding the memory referenced
+// by its keys and values. The estimation assumes that each entry of
+// the underlying Go maps takes a slot holding its key and value plus
+// a byte of metadata, and that slots are on average 13/16 full, on top
+// of a fixed map header. Entries of Hashable keys whose hashes collide
+// also count the size of the node chaining them. Since Go maps don't
+// shrink, the estimation doesn't account for the memory kept by a Map
+// after entries were deleted from it; Resize releases that memory.
+// EstimatedSize is O(1) for Maps without Hashable keys, and linear in
+// the number of Hashable keys otherwise.
+func (m *Map) EstimatedSize() int {
+	size := int(unsafe.Sizeof(Map{}))
+	if m == nil {
+		return size
+	}
+	if m.normal != nil {
+		size += goMapSize(len(m.normal),
+			int(unsafe.Sizeof(interface{}(nil))+unsafe.Sizeof(interface{}(nil))))
+	}
+	if m.custom != nil {
+		size += goMapSize(len(m.custom), int(unsafe.Sizeof(uint64(0))+unsafe.Sizeof(entry{})))
+		for _, ent := range m.custom {
+			for {
+				next, ok := ent.valOrNext.(*chainedEntry)
+				if !ok {
+					break
+				}
+				size += int(unsafe.Sizeof(chainedEntry{}))
+				ent = next.entry
+			}
+		}
+	}
+	return size
+}
+
+// goMapSize estimates the size of a Go map of n entries whose key and
+// value take slotSize bytes.
+func goMapSize(n, slotSize int) int {
+	return mapHeaderSize + n*(slotSize+mapSlotOverhead)*mapLoadFactorDiv/mapLoadFactor
+}
+
 // An entry represents an entry in a map whose key is not normally hashable,
 // and is therefore of type Hashable
 // (that is, a Hash method has been defined for this entry's key, and we can index it)
@@ -177,6 +363,30 @@ func entryRemove(head *entry, k Hashable) (*entry, bool) {
 	}
 }
 
+// entryFilter returns a new entry list holding the entries of ent
+// for which pred returns false, along with the number of entries for
+// which pred returned true. The returned head is nil if no entry is
+// left. ent itself is left untouched.
+func entryFilter(ent entry, pred func(k, v interface{}) bool) (*entry, int) {
+	var head, tail *entry
+	var removed int
+	_ = entryIter(ent, func(k, v interface{}) error {
+		if pred(k, v) {
+			removed++
+			return nil
+		}
+		if head == nil {
+			head = &entry{k: k.(Hashable), valOrNext: v}
+			tail = head
+			return nil
+		}
+		entryAppend(tail, k.(Hashable), v)
+		tail = &tail.valOrNext.(*chainedEntry).entry
+		return nil
+	})
+	return head, removed
+}
+
 func entryIter(ent entry, f func(k, v interface{}) error) error {
 	for {
 		if chEnt, ok := ent.valOrNext.(*chainedEntry); ok {
@@ -196,16 +406,20 @@ type Hashable interface {
 	Equal(other interface{}) bool
 }
 
-// Equal compares two Maps
+// Equal compares two Maps. A nil Map is considered equal to an empty
+// Map. Values that are both float64 NaNs, or both float32 NaNs, are
+// considered equal, so that a Map holding NaN values equals itself,
+// even though NaN != NaN. []byte values are compared by content. A
+// Map equals the read-only views of the Maps it equals.
 func (m *Map) Equal(other interface{}) bool {
-	if (m == nil) != (other == nil) {
-		return false
+	if r, ok := other.(readonlyMap); ok {
+		other = r.m
 	}
 	o, ok := other.(*Map)
 	if !ok {
 		return false
 	}
-	if m.length != o.length {
+	if m.Len() != o.Len() || !sameBucketCount(m, o) {
 		return false
 	}
 	err := m.Iter(func(k, v interface{}) error {
@@ -213,7 +427,7 @@ func (m *Map) Equal(other interface{}) bool {
 		if !ok {
 			return errors.New("notequal")
 		}
-		if !keyEqual(v, otherV) {
+		if !valueEqual(v, otherV) {
 			return errors.New("notequal")
 		}
 		return nil
@@ -221,6 +435,470 @@ func (m *Map) Equal(other interface{}) bool {
 	return err == nil
 }
 
+// equalContextInterval is the number of entries EqualContext compares
+// between two checks of its context.
+const equalContextInterval = 1024
+
+// EqualContext compares two Maps like Equal, but checks ctx before
+// starting and then every 1024 entries compared, counting the entries
+// of nested *Map values, and returns false and the error of ctx if it
+// is done before the comparison completes. Otherwise, EqualContext
+// returns the result of the comparison and a nil error.
+func (m *Map) EqualContext(ctx context.Context, other *Map) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	c := equalContext{ctx: ctx}
+	equal := c.mapsEqual(m, other)
+	if c.err != nil {
+		return false, c.err
+	}
+	return equal, nil
+}
+
+type equalContext struct {
+	ctx      context.Context
+	compared int
+	err      error
+}
+
+func (c *equalContext) mapsEqual(a, b *Map) bool {
+	if a.Len() != b.Len() || !sameBucketCount(a, b) {
+		return false
+	}
+	err := a.Iter(func(k, v interface{}) error {
+		c.compared++
+		if c.compared%equalContextInterval == 0 {
+			if c.err = c.ctx.Err(); c.err != nil {
+				return c.err
+			}
+		}
+		otherV, ok := b.Get(k)
+		if !ok {
+			return errors.New("notequal")
+		}
+		vm, ok := v.(*Map)
+		otherVM, otherOk := otherV.(*Map)
+		if ok && otherOk {
+			if !c.mapsEqual(vm, otherVM) {
+				return errors.New("notequal")
+			}
+			return nil
+		}
+		if !valueEqual(v, otherV) {
+			return errors.New("notequal")
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// EqualWithKeyEq compares two Maps like Equal, but compares keys with
+// keyEq instead of looking up the keys of the Map in other, such that
+// keys can be compared with custom semantics, like case-insensitive
+// strings. Since keyEq may consider equal keys that the Map hashes
+// differently, each entry of the Map is compared with the entries of
+// other until one has an equal key and value, which makes
+// EqualWithKeyEq O(n*m); Equal should be preferred when keys have
+// the default semantics. keyEq must be an equivalence relation, and
+// each entry of other is matched with at most one entry of the Map.
+// If keyEq is nil, EqualWithKeyEq is equivalent to Equal.
+func (m *Map) EqualWithKeyEq(other *Map, keyEq func(a, b interface{}) bool) bool {
+	if keyEq == nil {
+		return m.Equal(other)
+	}
+	if m.Len() != other.Len() {
+		return false
+	}
+	entries := other.Entries()
+	matched := make([]bool, len(entries))
+	err := m.Iter(func(k, v interface{}) error {
+		for i, e := range entries {
+			if !matched[i] && keyEq(k, e.Key) && valueEqual(v, e.Value) {
+				matched[i] = true
+				return nil
+			}
+		}
+		return errors.New("notequal")
+	})
+	return err == nil
+}
+
+// EqualStream compares two Maps like Equal, walking the storage of the
+// Map in place and looking up each of its entries in other, such that
+// no memory is allocated for the comparison, whether the Maps are
+// equal or not, besides what comparing the values themselves requires,
+// such as comparing nested Maps with Equal. Equal doesn't allocate
+// memory proportional to the size of the Maps either, but allocates an
+// error to stop iterating when the Maps differ.
+func (m *Map) EqualStream(other *Map) bool {
+	if m.Len() != other.Len() || !sameBucketCount(m, other) {
+		return false
+	}
+	if m == nil {
+		return true
+	}
+	for k, v := range m.normal {
+		if otherV, ok := other.Get(k); !ok || !valueEqual(v, otherV) {
+			return false
+		}
+	}
+	for _, ent := range m.custom {
+		for {
+			v := ent.valOrNext
+			chEnt, chained := v.(*chainedEntry)
+			if chained {
+				v = chEnt.val
+			}
+			if otherV, ok := other.Get(ent.k); !ok || !valueEqual(v, otherV) {
+				return false
+			}
+			if !chained {
+				break
+			}
+			ent = chEnt.entry
+		}
+	}
+	return true
+}
+
+// EqualCount compares two Maps like Equal, and also returns the
+// number of entries of the Map that were compared to entries of other
+// before the result was known. This is meant to check, in benchmarks,
+// how many entries the prechecks of Equal, such as comparing the
+// lengths of the Maps, save from being compared.
+func (m *Map) EqualCount(other *Map) (equal bool, compared int) {
+	if m.Len() != other.Len() || !sameBucketCount(m, other) {
+		return false, 0
+	}
+	err := m.Iter(func(k, v interface{}) error {
+		compared++
+		otherV, ok := other.Get(k)
+		if !ok || !valueEqual(v, otherV) {
+			return errors.New("notequal")
+		}
+		return nil
+	})
+	return err == nil, compared
+}
+
+// Diff compares the Map with other like Equal, and returns the key of
+// an entry that differs between the two Maps, along with the value of
+// that entry in the Map and in other, and false. A value is nil when
+// the key is absent from one of the Maps. If the Maps are equal, Diff
+// returns nil, nil, nil and true. When several entries differ, which
+// one is returned is unspecified and may change from call to call.
+func (m *Map) Diff(other *Map) (k, a, b interface{}, equal bool) {
+	errDiff := errors.New("notequal")
+	err := m.Iter(func(key, v interface{}) error {
+		otherV, ok := other.Get(key)
+		if !ok || !valueEqual(v, otherV) {
+			k, a, b = key, v, otherV
+			return errDiff
+		}
+		return nil
+	})
+	if err != nil {
+		return k, a, b, false
+	}
+	err = other.Iter(func(key, v interface{}) error {
+		if _, ok := m.Get(key); !ok {
+			k, b = key, v
+			return errDiff
+		}
+		return nil
+	})
+	return k, a, b, err == nil
+}
+
+// DiffAll compares the Map with other like Equal, and returns all
+// their differences as three new Maps: onlyA holds the entries of the
+// Map whose keys aren't in other, onlyB the entries of other whose
+// keys aren't in the Map, and valueDiffs the entries of the Map whose
+// keys are in other with a different value. The returned Maps are
+// never nil, and are all empty if the Maps are equal.
+func (m *Map) DiffAll(other *Map) (onlyA, onlyB, valueDiffs *Map) {
+	onlyA, onlyB, valueDiffs = NewMap(), NewMap(), NewMap()
+	_ = m.Iter(func(k, v interface{}) error {
+		otherV, ok := other.Get(k)
+		if !ok {
+			onlyA.Set(k, v)
+		} else if !valueEqual(v, otherV) {
+			valueDiffs.Set(k, v)
+		}
+		return nil
+	})
+	_ = other.Iter(func(k, v interface{}) error {
+		if !m.Contains(k) {
+			onlyB.Set(k, v)
+		}
+		return nil
+	})
+	return onlyA, onlyB, valueDiffs
+}
+
+// EqualDepth compares two Maps like Equal, but descends into at most
+// maxDepth levels of nested *Map values. Nested *Map values found
+// past maxDepth are considered equal only if they are the same *Map,
+// which bounds the cost of comparing deeply nested, or cyclic, Maps.
+// A maxDepth of 0 compares the entries of both Maps, but compares
+// *Map values by identity. A negative maxDepth is treated as 0. A
+// nil Map is considered equal to an empty Map.
+func (m *Map) EqualDepth(other *Map, maxDepth int) bool {
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	return mapEqualDepth(m, other, maxDepth)
+}
+
+func mapEqualDepth(a, b *Map, depth int) bool {
+	if a.Len() != b.Len() || !sameBucketCount(a, b) {
+		return false
+	}
+	err := a.Iter(func(k, v interface{}) error {
+		otherV, ok := b.Get(k)
+		if !ok {
+			return errors.New("notequal")
+		}
+		vm, ok := v.(*Map)
+		otherVM, otherOk := otherV.(*Map)
+		if ok && otherOk {
+			if vm == otherVM || depth > 0 && mapEqualDepth(vm, otherVM, depth-1) {
+				return nil
+			}
+			return errors.New("notequal")
+		}
+		if !valueEqual(v, otherV) {
+			return errors.New("notequal")
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// EqualDeepValues compares two Maps like Equal, except that values
+// are compared with reflect.DeepEqual, rather than with Equal methods
+// or ==, such that values that aren't comparable, like slices or
+// structs holding slices, are compared structurally. Values that are
+// both *Map are compared with EqualDeepValues. Keys are still
+// compared as in Equal. Reflection makes EqualDeepValues much slower
+// than Equal, so it should only be used on Maps holding such values.
+func (m *Map) EqualDeepValues(other *Map) bool {
+	if m.Len() != other.Len() {
+		return false
+	}
+	err := m.Iter(func(k, v interface{}) error {
+		otherV, ok := other.Get(k)
+		if !ok {
+			return errors.New("notequal")
+		}
+		vm, ok := v.(*Map)
+		otherVM, otherOk := otherV.(*Map)
+		if ok && otherOk {
+			if !vm.EqualDeepValues(otherVM) {
+				return errors.New("notequal")
+			}
+			return nil
+		}
+		if !reflect.DeepEqual(v, otherV) {
+			return errors.New("notequal")
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// EqualOption changes how EqualOpts compares two Maps.
+type EqualOption func(c *equalConfig)
+
+type equalConfig struct {
+	deepValues bool
+	ignore     *Map
+	coerce     bool
+	maxDepth   int // negative for no limit
+}
+
+// WithDeepValues makes EqualOpts compare values with reflect.DeepEqual,
+// as EqualDeepValues does.
+func WithDeepValues() EqualOption {
+	return func(c *equalConfig) {
+		c.deepValues = true
+	}
+}
+
+// WithIgnoreKeys makes EqualOpts ignore the entries with the given
+// keys, in the Maps compared and in their nested Maps.
+func WithIgnoreKeys(keys ...interface{}) EqualOption {
+	return func(c *equalConfig) {
+		if c.ignore == nil {
+			c.ignore = &Map{}
+		}
+		for _, k := range keys {
+			c.ignore.Set(k, true)
+		}
+	}
+}
+
+// WithNumericCoercion makes EqualOpts consider values, or Keys used
+// as values, that are numbers of different types equal if they have
+// the same numerical value, as CoerceEqual does. Keys themselves are
+// still looked up as in Equal, so entries keyed by int64(1) and
+// float64(1) are different entries.
+func WithNumericCoercion() EqualOption {
+	return func(c *equalConfig) {
+		c.coerce = true
+	}
+}
+
+// WithMaxDepth makes EqualOpts descend into at most maxDepth levels of
+// nested *Map values, as EqualDepth does. A negative maxDepth is
+// treated as 0.
+func WithMaxDepth(maxDepth int) EqualOption {
+	return func(c *equalConfig) {
+		if maxDepth < 0 {
+			maxDepth = 0
+		}
+		c.maxDepth = maxDepth
+	}
+}
+
+// EqualOpts compares two Maps like Equal, with the semantics changed
+// by the given options, which can be combined. Options apply to the
+// nested *Map values of the Maps as well. EqualOpts with no options
+// is equivalent to Equal, which is faster.
+func (m *Map) EqualOpts(other *Map, opts ...EqualOption) bool {
+	c := equalConfig{maxDepth: -1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c.mapsEqual(m, other, c.maxDepth)
+}
+
+func (c *equalConfig) mapsEqual(a, b *Map, depth int) bool {
+	if c.ignore.Len() == 0 && (a.Len() != b.Len() || !sameBucketCount(a, b)) {
+		return false
+	}
+	var n int
+	err := a.Iter(func(k, v interface{}) error {
+		if c.ignore.Contains(k) {
+			return nil
+		}
+		n++
+		otherV, ok := b.Get(k)
+		if !ok || !c.valuesEqual(v, otherV, depth) {
+			return errors.New("notequal")
+		}
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+	if c.ignore.Len() == 0 {
+		return true
+	}
+	// Every entry of a that isn't ignored is in b, so the Maps are
+	// equal if b holds as many entries that aren't ignored.
+	return b.Len()-b.Count(func(k, _ interface{}) bool { return c.ignore.Contains(k) }) == n
+}
+
+func (c *equalConfig) valuesEqual(a, b interface{}, depth int) bool {
+	am, aok := a.(*Map)
+	bm, bok := b.(*Map)
+	if aok && bok {
+		if am == bm {
+			return true
+		} else if depth == 0 {
+			return false
+		} else if depth > 0 {
+			depth--
+		}
+		return c.mapsEqual(am, bm, depth)
+	}
+	if c.coerce {
+		va, vb := a, b
+		if k, ok := va.(Key); ok {
+			va = k.Key()
+		}
+		if k, ok := vb.(Key); ok {
+			vb = k.Key()
+		}
+		if equal, ok := numbersCoerceEqual(va, vb); ok {
+			return equal
+		}
+	}
+	if c.deepValues {
+		return reflect.DeepEqual(a, b)
+	}
+	return valueEqual(a, b)
+}
+
+// sameBucketCount returns whether two Maps store their Hashable keys
+// under the same number of distinct hashes, which is cheap to check
+// and a necessary condition for the Maps to be equal: equal Hashable
+// keys must have equal hashes, an assumption Map already relies on to
+// look up such keys, so equal Maps store their Hashable keys under the
+// same hashes. Maps with keys breaking that assumption don't work
+// reliably in the first place.
+func sameBucketCount(a, b *Map) bool {
+	var na, nb int
+	if a != nil {
+		na = len(a.custom)
+	}
+	if b != nil {
+		nb = len(b.custom)
+	}
+	return na == nb
+}
+
+// valueEqual compares two values stored in a Map. It behaves like
+// keyEqual, except that a *Map and a map[string]interface{} holding
+// the same entries are considered equal, regardless of which of the
+// two values is wrapped in a Map, and that two NaNs of the same type
+// are considered equal.
+func valueEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok && math.IsNaN(a) && math.IsNaN(b) {
+			return true
+		}
+	case float32:
+		if b, ok := b.(float32); ok && math.IsNaN(float64(a)) && math.IsNaN(float64(b)) {
+			return true
+		}
+	case []byte:
+		// []byte values aren't comparable with ==.
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	case *Map:
+		if b, ok := b.(map[string]interface{}); ok {
+			return a.EqualGoMap(b)
+		}
+	case map[string]interface{}:
+		if b, ok := b.(*Map); ok {
+			return b.EqualGoMap(a)
+		}
+	}
+	return keyEqual(a, b)
+}
+
+// EqualGoMap returns whether the Map holds exactly the entries of o,
+// that is whether it holds an entry for each key of o, with a string
+// key and a value equal to that of o as compared by Equal, and no
+// other entry. An entry whose key is a Key wrapping a string, rather
+// than the string itself, or any other non-string key makes the Map
+// unequal to o. A nil Map equals an empty or nil Go map.
+func (m *Map) EqualGoMap(o map[string]interface{}) bool {
+	if m.Len() != len(o) {
+		return false
+	}
+	for k, ov := range o {
+		if v, ok := m.Get(k); !ok || !valueEqual(v, ov) {
+			return false
+		}
+	}
+	return true
+}
+
 // Hash returns the hash value of this Map
 func (m *Map) Hash() uint64 {
 	if m == nil {
@@ -239,6 +917,10 @@ func (m *Map) Set(k, v interface{}) {
 	if k == nil {
 		return
 	}
+	if m.hook != nil {
+		m.hook("Set", k)
+	}
+	m.unshare()
 	if hkey, ok := k.(Hashable); ok {
 		if m.custom == nil {
 			m.custom = make(map[uint64]entry)
@@ -273,11 +955,96 @@ func (m *Map) Set(k, v interface{}) {
 	}
 }
 
+// Apply sets the value of key k in the Map to the value returned by
+// f, which is passed the current value of k and true if k is present
+// in the Map, or nil and false otherwise. The entry of k is looked up
+// only once, which makes Apply cheaper than calling Get then Set to
+// update a value, such as a counter. As with Set, nothing happens if
+// k is nil, in which case f isn't called.
+func (m *Map) Apply(k interface{}, f func(old interface{}, found bool) interface{}) {
+	if k == nil {
+		return
+	}
+	if m.hook != nil {
+		m.hook("Set", k)
+	}
+	m.unshare()
+	if hkey, ok := k.(Hashable); ok {
+		if m.custom == nil {
+			m.custom = make(map[uint64]entry)
+		}
+		h := hkey.Hash()
+		rootentry, ok := m.custom[h]
+		if !ok {
+			m.custom[h] = entry{k: hkey, valOrNext: f(nil, false)}
+			m.length++
+			return
+		}
+		ent, found := entrySearch(&rootentry, hkey)
+		if found {
+			entrySetValue(ent, f(entryGetValue(ent), true))
+			m.custom[h] = rootentry
+			return
+		}
+		entryAppend(ent, hkey, f(nil, false))
+		m.custom[h] = rootentry
+		m.length++
+		return
+	}
+	if m.normal == nil {
+		m.normal = make(map[interface{}]interface{})
+	}
+	v, found := m.normal[k]
+	m.normal[k] = f(v, found)
+	if !found {
+		m.length++
+	}
+}
+
+// LoadOrCompute returns the value of key k and true if k is present in
+// the Map. Otherwise, it calls compute, sets the value of k to the
+// value returned by compute and returns that value and false, such that
+// the value is only built when it's needed. compute is called at most
+// once, and may modify the Map. As with Set, nothing happens if k is
+// nil, in which case compute isn't called and LoadOrCompute returns
+// nil and false. Unlike Apply, LoadOrCompute doesn't modify the Map
+// when k is present, so it doesn't copy the storage of a Map shared
+// with a snapshot in that case.
+func (m *Map) LoadOrCompute(k interface{}, compute func() interface{}) (interface{}, bool) {
+	if k == nil {
+		return nil, false
+	}
+	if v, ok := m.Get(k); ok {
+		return v, true
+	}
+	v := compute()
+	m.Set(k, v)
+	return v, false
+}
+
+// SetAll adds the key-value pairs in keysAndVals to the Map. The
+// arguments should be of form: key1, value1, key2, value2, ... and
+// an error is returned, without modifying the Map, if their number
+// is odd. If a key appears more than once, the later value
+// overwrites the earlier one.
+func (m *Map) SetAll(keysAndVals ...interface{}) error {
+	if len(keysAndVals)%2 != 0 {
+		return errors.New("odd number of arguments passed to SetAll")
+	}
+	for i := 0; i < len(keysAndVals); i += 2 {
+		m.Set(keysAndVals[i], keysAndVals[i+1])
+	}
+	return nil
+}
+
 // Get retrieves the value stored with key k from the Map
 func (m *Map) Get(k interface{}) (interface{}, bool) {
 	if m == nil {
 		return nil, false
 	}
+	if m.hook != nil {
+		m.hook("Get", k)
+	}
 	if hkey, ok := k.(Hashable); ok {
 		h := hkey.Hash()
 		hentry, ok := m.custom[h]
@@ -294,11 +1061,87 @@ func (m *Map) Get(k interface{}) (interface{}, bool) {
 	return v, ok
 }
 
+// GetMulti retrieves the values stored with each of the provided keys
+// from the Map. The returned slices have the same length as keys, and
+// hold at each index the value and the found flag that Get returns for
+// the key at the same index.
+func (m *Map) GetMulti(keys ...interface{}) ([]interface{}, []bool) {
+	values, found := make([]interface{}, len(keys)), make([]bool, len(keys))
+	for i, k := range keys {
+		values[i], found[i] = m.Get(k)
+	}
+	return values, found
+}
+
+// Contains returns whether the Map has an entry with key k.
+func (m *Map) Contains(k interface{}) bool {
+	_, ok := m.Get(k)
+	return ok
+}
+
+// GetBool retrieves the bool value stored with key k from the Map.
+// The second return value is false if k is absent, or if k is present
+// but its value isn't a bool, in which case false is returned.
+func (m *Map) GetBool(k interface{}) (bool, bool) {
+	v, _ := m.Get(k)
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// GetString retrieves the string value stored with key k from the Map.
+// The second return value is false if k is absent, or if k is present
+// but its value isn't a string, in which case "" is returned.
+func (m *Map) GetString(k interface{}) (string, bool) {
+	v, _ := m.Get(k)
+	s, ok := v.(string)
+	return s, ok
+}
+
+// GetInt64 retrieves the integer value stored with key k from the Map
+// as an int64. Values of any signed or unsigned integer type are
+// converted to int64, so long as they fit in one. The second return
+// value is false if k is absent, or if k is present but its value
+// isn't an integer that fits in an int64, in which case 0 is returned.
+func (m *Map) GetInt64(k interface{}) (int64, bool) {
+	v, _ := m.Get(k)
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v), true
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
 // Del removes an entry with key k from the Map
 func (m *Map) Del(k interface{}) {
 	if m == nil {
 		return
 	}
+	if m.hook != nil {
+		m.hook("Del", k)
+	}
+	m.unshare()
 	if hkey, ok := k.(Hashable); ok {
 		if m.custom == nil {
 			return
@@ -331,11 +1174,209 @@ func (m *Map) Del(k interface{}) {
 	}
 }
 
-// Iter applies func f to every key-value pair in the Map
+// DeleteFunc removes every entry of the Map for which pred returns
+// true, in a single pass over the Map, and returns the number of
+// entries removed.
+func (m *Map) DeleteFunc(pred func(k, v interface{}) bool) int {
+	if m == nil {
+		return 0
+	}
+	m.unshare()
+	if m.hook != nil {
+		hook, deletePred := m.hook, pred
+		pred = func(k, v interface{}) bool {
+			if !deletePred(k, v) {
+				return false
+			}
+			hook("Del", k)
+			return true
+		}
+	}
+	var removed int
+	for k, v := range m.normal {
+		if pred(k, v) {
+			delete(m.normal, k)
+			removed++
+		}
+	}
+	for h, ent := range m.custom {
+		head, n := entryFilter(ent, pred)
+		if n == 0 {
+			continue
+		}
+		removed += n
+		if head == nil {
+			delete(m.custom, h)
+		} else {
+			m.custom[h] = *head
+		}
+	}
+	m.length -= removed
+	return removed
+}
+
+// CopyInto sets all the entries of the Map into dst, overwriting the
+// value of entries of dst with the same keys. Unlike creating a new
+// Map, CopyInto reuses the memory already allocated by dst, which
+// makes clearing dst with Clear then calling CopyInto an efficient
+// way to refresh a Map that is reused over time.
+func (m *Map) CopyInto(dst *Map) {
+	_ = m.Iter(func(k, v interface{}) error {
+		dst.Set(k, v)
+		return nil
+	})
+}
+
+// Clear removes all the entries of the Map, retaining the memory
+// allocated for them so that it can be reused by later calls to Set.
+func (m *Map) Clear() {
+	if m == nil {
+		return
+	}
+	if m.hook != nil {
+		hook := m.hook
+		_ = m.iter(func(k, _ interface{}) error {
+			hook("Del", k)
+			return nil
+		})
+	}
+	if m.shared {
+		m.replaceStorage(&Map{})
+		return
+	}
+	for k := range m.normal {
+		delete(m.normal, k)
+	}
+	for h := range m.custom {
+		delete(m.custom, h)
+	}
+	m.length = 0
+}
+
+// replaceStorage replaces the entries of the Map with those of o,
+// keeping the access hook of the Map.
+func (m *Map) replaceStorage(o *Map) {
+	m.normal, m.custom, m.length, m.shared = o.normal, o.custom, o.length, o.shared
+}
+
+// Resize rebuilds the internal storage of the Map so that it is sized
+// for the entries it currently holds. Go maps never shrink, so a Map
+// that once held many more entries than it does now keeps the memory
+// needed for all of them; Resize releases that memory. Resize is O(n)
+// in the number of entries and does not change the contents of the
+// Map in any way.
+func (m *Map) Resize() {
+	if m == nil {
+		return
+	}
+	if m.shared {
+		// Copying the shared storage sizes it for the entries it holds.
+		m.unshare()
+		if m.length != 0 {
+			return
+		}
+	}
+	if len(m.normal) == 0 {
+		m.normal = nil
+	} else {
+		normal := make(map[interface{}]interface{}, len(m.normal))
+		for k, v := range m.normal {
+			normal[k] = v
+		}
+		m.normal = normal
+	}
+	if len(m.custom) == 0 {
+		m.custom = nil
+	} else {
+		custom := make(map[uint64]entry, len(m.custom))
+		for h, ent := range m.custom {
+			custom[h] = ent
+		}
+		m.custom = custom
+	}
+}
+
+// SetAccessHook makes the Map call hook with the name of the
+// operation and the key each time one of its keys is accessed by Get,
+// Set, Del or Iter, where the operation names are "Get", "Set", "Del"
+// and "Iter". Iter calls hook for each entry it visits, before calling
+// its function with that entry. Other methods built upon these ones,
+// such as Contains or Equal, also call hook for the accesses they
+// make. Apply calls hook with "Set", and DeleteFunc and Clear call it
+// with "Del" for each entry they remove. UnmarshalJSON and
+// UnmarshalBinary replace the entries of the Map without calling hook.
+// This is meant for tests to check which keys a piece of code
+// accesses. Calling SetAccessHook with a nil hook removes the hook.
+// The hook isn't copied to snapshots or other Maps created from the
+// Map.
+func (m *Map) SetAccessHook(hook func(op string, k interface{})) {
+	m.hook = hook
+}
+
+// Snapshot returns a Map holding the entries currently held by the
+// Map. Taking a snapshot is O(1): the snapshot shares the storage of
+// the Map, and the first change to either of them after the snapshot
+// was taken copies that storage, in O(n), so that changes to one are
+// never seen by the other. This makes snapshots a cheap way to keep
+// versions of a Map that is rarely modified. Since changes to the Map
+// leave the storage of the snapshot untouched, a snapshot may be read
+// concurrently with changes to the Map, provided Snapshot was called
+// by the goroutine making them. Values themselves are shared, so
+// changes to values held by the Map, such as *Map values, are seen by
+// the snapshot. Snapshot returns nil if the Map is nil.
+func (m *Map) Snapshot() *Map {
+	if m == nil {
+		return nil
+	}
+	m.shared = true
+	return &Map{normal: m.normal, custom: m.custom, length: m.length, shared: true}
+}
+
+// unshare copies the storage of the Map if it may be shared with a
+// snapshot, including the chains of entries, which are modified in
+// place by Set and Del.
+func (m *Map) unshare() {
+	if !m.shared {
+		return
+	}
+	m.shared = false
+	if m.normal != nil {
+		normal := make(map[interface{}]interface{}, len(m.normal))
+		for k, v := range m.normal {
+			normal[k] = v
+		}
+		m.normal = normal
+	}
+	if m.custom != nil {
+		custom := make(map[uint64]entry, len(m.custom))
+		for h, ent := range m.custom {
+			head, _ := entryFilter(ent, func(k, v interface{}) bool { return false })
+			custom[h] = *head
+		}
+		m.custom = custom
+	}
+}
+
+// Iter applies func f to every key-value pair in the Map. The keys
+// passed to f are the exact values that were passed to Set, including
+// keys that are Hashable, such as keys created by New from a map,
+// which are handed back as is rather than as some internal form.
 func (m *Map) Iter(f func(k, v interface{}) error) error {
 	if m == nil {
 		return nil
 	}
+	if m.hook != nil {
+		hook, iterF := m.hook, f
+		f = func(k, v interface{}) error {
+			hook("Iter", k)
+			return iterF(k, v)
+		}
+	}
+	return m.iter(f)
+}
+
+// iter is like Iter, but doesn't call the access hook.
+func (m *Map) iter(f func(k, v interface{}) error) error {
 	for k, v := range m.normal {
 		if err := f(k, v); err != nil {
 			return err
@@ -348,3 +1389,194 @@ func (m *Map) Iter(f func(k, v interface{}) error) error {
 	}
 	return nil
 }
+
+// IterIndexed is like Iter, but also passes to f the index of each
+// entry in the order in which entries are visited, starting from 0
+// and incremented by one for every entry.
+func (m *Map) IterIndexed(f func(i int, k, v interface{}) error) error {
+	var i int
+	return m.Iter(func(k, v interface{}) error {
+		err := f(i, k, v)
+		i++
+		return err
+	})
+}
+
+// Keys returns the keys of all the entries in the Map, in no
+// particular order.
+func (m *Map) Keys() []interface{} {
+	keys := make([]interface{}, 0, m.Len())
+	_ = m.Iter(func(k, v interface{}) error {
+		keys = append(keys, k)
+		return nil
+	})
+	return keys
+}
+
+// Values returns the values of all the entries in the Map, in no
+// particular order.
+func (m *Map) Values() []interface{} {
+	values := make([]interface{}, 0, m.Len())
+	_ = m.Iter(func(k, v interface{}) error {
+		values = append(values, v)
+		return nil
+	})
+	return values
+}
+
+// Entry is an entry of a Map.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Entries returns all the entries of the Map, in no particular order.
+func (m *Map) Entries() []Entry {
+	entries := make([]Entry, 0, m.Len())
+	_ = m.Iter(func(k, v interface{}) error {
+		entries = append(entries, Entry{Key: k, Value: v})
+		return nil
+	})
+	return entries
+}
+
+// SortedEntries returns all the entries of the Map, sorted by key
+// according to less, which reports whether the key a sorts before the
+// key b. If less is nil, keys are sorted with Compare. Entries whose
+// keys are neither less than the other keep an unspecified order, so
+// the order is only deterministic if less is a total order of the
+// keys, as Compare is for all the keys but those of unknown types with
+// the same string representation.
+func (m *Map) SortedEntries(less func(a, b interface{}) bool) []Entry {
+	if less == nil {
+		less = func(a, b interface{}) bool { return Compare(a, b) < 0 }
+	}
+	entries := m.Entries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i].Key, entries[j].Key)
+	})
+	return entries
+}
+
+// IterByValue applies f to every key-value pair in the Map, in the
+// order of their values according to less, which reports whether the
+// value a sorts before the value b. If less is nil, values are sorted
+// with Compare. Entries with values that are neither less than the
+// other are visited in the order of their keys, sorted with Compare.
+// As with Iter, iteration stops at the first error returned by f,
+// which is then returned, so IterByValue can visit only the entries
+// with the N lowest values. Entries are sorted before the first call
+// to f, so IterByValue is O(n*log(n)) however early f stops.
+func (m *Map) IterByValue(less func(a, b interface{}) bool,
+	f func(k, v interface{}) error) error {
+	if less == nil {
+		less = func(a, b interface{}) bool { return Compare(a, b) < 0 }
+	}
+	entries := m.Entries()
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if less(a.Value, b.Value) {
+			return true
+		} else if less(b.Value, a.Value) {
+			return false
+		}
+		return Compare(a.Key, b.Key) < 0
+	})
+	for _, e := range entries {
+		if err := f(e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IterBatch calls f with the entries of the Map grouped in batches of
+// n entries, in no particular order, except for the last batch, which
+// holds the remaining entries and may be smaller. f is not called if
+// the Map is empty. A value of n lower than 1 is treated as 1. Each
+// batch is a new slice, which f may retain. Iteration stops at the
+// first error returned by f, which is then returned.
+func (m *Map) IterBatch(n int, f func(batch []Entry) error) error {
+	if n < 1 {
+		n = 1
+	}
+	size := n
+	if l := m.Len(); l < size {
+		size = l
+	}
+	batch := make([]Entry, 0, size)
+	err := m.Iter(func(k, v interface{}) error {
+		batch = append(batch, Entry{Key: k, Value: v})
+		if len(batch) < n {
+			return nil
+		}
+		full := batch
+		batch = make([]Entry, 0, size)
+		return f(full)
+	})
+	if err != nil || len(batch) == 0 {
+		return err
+	}
+	return f(batch)
+}
+
+// NDJSONOption changes how WriteNDJSON writes a Map.
+type NDJSONOption func(c *ndjsonConfig)
+
+type ndjsonConfig struct {
+	skipInvalid bool
+}
+
+// SkipInvalidEntries makes WriteNDJSON skip the entries whose key or
+// value can't be encoded to JSON, instead of failing.
+func SkipInvalidEntries() NDJSONOption {
+	return func(c *ndjsonConfig) {
+		c.skipInvalid = true
+	}
+}
+
+// WriteNDJSON writes the entries of the Map to w as newline-delimited
+// JSON, with one {"key":...,"value":...} object per line, in no
+// particular order. Each entry is written to w as soon as it's
+// encoded, so the Map is never encoded as a whole in memory. By
+// default, WriteNDJSON stops and returns an error when the key or the
+// value of an entry can't be encoded, after having written the
+// previous entries; the SkipInvalidEntries option makes it skip such
+// entries instead. Errors returned by w are always returned.
+func (m *Map) WriteNDJSON(w io.Writer, options ...NDJSONOption) error {
+	var c ndjsonConfig
+	for _, option := range options {
+		option(&c)
+	}
+	type ndjsonEntry struct {
+		Key   interface{} `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	return m.Iter(func(k, v interface{}) error {
+		line, err := json.Marshal(ndjsonEntry{Key: k, Value: v})
+		if err != nil {
+			if c.skipInvalid {
+				return nil
+			}
+			return fmt.Errorf("unable to encode entry with key %v: %s", k, err)
+		}
+		_, err = w.Write(append(line, '\n'))
+		return err
+	})
+}
+
+// Count returns the number of entries in the Map for which pred
+// returns true. If pred is nil, Count returns the length of the Map.
+func (m *Map) Count(pred func(k, v interface{}) bool) int {
+	if pred == nil {
+		return m.Len()
+	}
+	var n int
+	_ = m.Iter(func(k, v interface{}) error {
+		if pred(k, v) {
+			n++
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+//go:build go1.18
+// +build go1.18
+
+package key
+
+import (
+	"testing"
+)
+
+// collidingHashable is a Hashable whose hash only takes a handful of
+// values, so that distinct keys form long collision chains.
+type collidingHashable struct {
+	id byte
+}
+
+func (c collidingHashable) Equal(other interface{}) bool {
+	o, ok := other.(collidingHashable)
+	return ok && c.id == o.id
+}
+
+func (c collidingHashable) Hash() uint64 {
+	return uint64(c.id % 3)
+}
+
+// fuzzKey returns the Map key identified by b. Half the keys share a
+// single hash and the other half is spread over a few hashes.
+func fuzzKey(b byte) interface{} {
+	if b&1 == 0 {
+		return dumbHashable{dumb: b}
+	}
+	return collidingHashable{id: b}
+}
+
+// FuzzMapCollisions applies a sequence of operations described by the
+// fuzzed input to a Map of colliding keys, and checks the Map against
+// a native Go map after each operation.
+func FuzzMapCollisions(f *testing.F) {
+	f.Add([]byte{0, 2, 4, 6, 1, 3, 5, 7})
+	f.Add([]byte{0, 2, 4, 6, 0x80, 2, 0x80, 4, 0x80, 0, 8})
+	f.Add([]byte{1, 4, 7, 10, 13, 0x80, 7, 0x80, 13, 0x80, 1, 16, 0xc0, 3})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		m := NewMap()
+		expected := map[byte]int{}
+		for i, op := range ops {
+			// The top two bits of op select the operation, and the
+			// remaining bits the key it applies to.
+			id := op & 0x3f
+			switch op >> 6 {
+			case 0, 1:
+				m.Set(fuzzKey(id), i)
+				expected[id] = i
+			case 2:
+				m.Del(fuzzKey(id))
+				delete(expected, id)
+			case 3:
+				m.DeleteFunc(func(k, v interface{}) bool { return v.(int)%int(id+1) == 0 })
+				for id, v := range expected {
+					if v%int(op&0x3f+1) == 0 {
+						delete(expected, id)
+					}
+				}
+			}
+			checkFuzzMap(t, m, expected)
+		}
+
+		// A Map holding the same entries, inserted in the opposite
+		// order, holds them in different positions of the chains.
+		other := NewMap()
+		for id := byte(0x3f); ; id-- {
+			if v, ok := expected[id]; ok {
+				other.Set(fuzzKey(id), v)
+			}
+			if id == 0 {
+				break
+			}
+		}
+		if !m.Equal(other) || !other.Equal(m) {
+			t.Fatalf("expected %s to equal %s", m.debug(), other.debug())
+		}
+		if !m.EqualDepth(other, 0) {
+			t.Fatalf("expected %s to equal %s at depth 0", m.debug(), other.debug())
+		}
+		if len(expected) > 0 {
+			for id := range expected {
+				other.Set(fuzzKey(id), -1)
+				break
+			}
+			if m.Equal(other) || other.Equal(m) {
+				t.Fatalf("expected %s not to equal %s", m.debug(), other.debug())
+			}
+		}
+	})
+}
+
+func checkFuzzMap(t *testing.T, m *Map, expected map[byte]int) {
+	t.Helper()
+	if m.Len() != len(expected) {
+		t.Fatalf("expected length %d, got %d: %s", len(expected), m.Len(), m.debug())
+	}
+	for id := byte(0); id < 0x40; id++ {
+		v, ok := m.Get(fuzzKey(id))
+		expectedV, expectedOk := expected[id]
+		if ok != expectedOk || ok && v != expectedV {
+			t.Fatalf("key %d: expected %d (%t), got %v (%t): %s",
+				id, expectedV, expectedOk, v, ok, m.debug())
+		}
+	}
+	n := 0
+	_ = m.Iter(func(k, v interface{}) error {
+		n++
+		var id byte
+		switch k := k.(type) {
+		case dumbHashable:
+			id = k.dumb.(byte)
+		case collidingHashable:
+			id = k.id
+		}
+		if expectedV, ok := expected[id]; !ok || v != expectedV {
+			t.Fatalf("unexpected entry %v: %v", k, v)
+		}
+		return nil
+	})
+	if n != len(expected) {
+		t.Fatalf("expected %d entries to be iterated over, got %d", len(expected), n)
+	}
+}
@@ -5,8 +5,14 @@
 package key
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -102,11 +108,1299 @@ func TestMapEqual(t *testing.T) {
 		b: &Map{normal: map[interface{}]interface{}{
 			"foo": &Map{normal: map[interface{}]interface{}{"a": 1}, length: 1}}, length: 1},
 		result: true,
+	}, { // *Map value on side a and map[string]interface{} value on side b
+		a:      NewMap("foo", NewMap("a", 1, "b", NewMap("c", true))),
+		b:      NewMap("foo", map[string]interface{}{"a": 1, "b": NewMap("c", true)}),
+		result: true,
+	}, { // map[string]interface{} value on side a and *Map value on side b
+		a:      NewMap("foo", map[string]interface{}{"a": 1}),
+		b:      NewMap("foo", NewMap("a", 1)),
+		result: true,
+	}, { // *Map value and map[string]interface{} value with differing contents
+		a:      NewMap("foo", NewMap("a", 1)),
+		b:      NewMap("foo", map[string]interface{}{"a": 2}),
+		result: false,
+	}, { // *Map value with a non-string key can't equal a map[string]interface{}
+		a:      NewMap("foo", NewMap(1, 1)),
+		b:      NewMap("foo", map[string]interface{}{"1": 1}),
+		result: false,
+	}, { // same length, but Hashable keys stored under a different number of hashes
+		a:      NewMap(dumbHashable{dumb: 1}, 1, dumbHashable{dumb: 2}, 2),
+		b:      NewMap(dumbHashable{dumb: 1}, 1, New(map[string]interface{}{"a": 2}), 2),
+		result: false,
+	}, { // []Key values built separately
+		a:      NewMap("a", []Key{New("foo"), New(uint32(1))}, "b", []Key{}),
+		b:      NewMap("a", []Key{New("foo"), New(uint32(1))}, "b", []Key{}),
+		result: true,
+	}, { // []Key values with differing elements
+		a:      NewMap("a", []Key{New("foo"), New(uint32(1))}),
+		b:      NewMap("a", []Key{New("foo"), New(uint32(2))}),
+		result: false,
+	}, { // []Key values with differing lengths
+		a:      NewMap("a", []Key{New("foo")}),
+		b:      NewMap("a", []Key{New("foo"), New("foo")}),
+		result: false,
+	}, { // a []Key value doesn't equal a Path value with the same elements
+		a:      NewMap("a", []Key{New("foo")}),
+		b:      NewMap("a", Path{New("foo")}),
+		result: false,
+	}}
+
+	for _, tcase := range tests {
+		if tcase.a.Equal(tcase.b) != tcase.result {
+			t.Errorf("%v and %v are not equal", tcase.a, tcase.b)
+		}
+	}
+
+	a := NewMap("a", []Key{New("foo"), New(uint32(1))})
+	b := NewMap("a", []Key{New("foo"), New(uint32(1))})
+	if a.Hash() != b.Hash() {
+		t.Errorf("expected %v and %v to have the same hash", a, b)
+	}
+}
+
+func TestMapEqualCount(t *testing.T) {
+	a := NewMap("a", 1, "b", 2, "c", 3)
+	tests := []struct {
+		other    *Map
+		equal    bool
+		compared int
+	}{{
+		other:    NewMap("a", 1, "b", 2, "c", 3),
+		equal:    true,
+		compared: 3,
+	}, {
+		other:    NewMap("a", 1, "b", 2),
+		equal:    false,
+		compared: 0,
+	}, {
+		other:    NewMap("a", 1, "b", 2, dumbHashable{dumb: "c"}, 3),
+		equal:    false,
+		compared: 0,
+	}, {
+		other:    NewMap("a", 0, "b", 0, "c", 0),
+		equal:    false,
+		compared: 1,
+	}, {
+		other:    nil,
+		equal:    false,
+		compared: 0,
+	}}
+	for i, tcase := range tests {
+		equal, compared := a.EqualCount(tcase.other)
+		if equal != tcase.equal || compared != tcase.compared {
+			t.Errorf("Test %d failed: expected %t, %d, got %t, %d",
+				i, tcase.equal, tcase.compared, equal, compared)
+		}
+		if equal != a.Equal(tcase.other) {
+			t.Errorf("Test %d failed: EqualCount and Equal disagree", i)
+		}
+	}
+	var m *Map
+	if equal, compared := m.EqualCount(NewMap()); !equal || compared != 0 {
+		t.Errorf("expected a nil Map to equal an empty Map, got %t, %d", equal, compared)
+	}
+}
+
+func TestNewMapFromJSON(t *testing.T) {
+	m, err := NewMapFromJSON([]byte(`{
+		"a": 1,
+		"b": "foo",
+		"c": {"d": true, "e": null},
+		"f": [1, {"g": "h"}],
+		"i": {}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewMap(
+		"a", float64(1),
+		"b", "foo",
+		"c", NewMap("d", true, "e", nil),
+		"f", []interface{}{float64(1), NewMap("g", "h")},
+		"i", NewMap(),
+	)
+	if !m.EqualDepth(expected, 2) {
+		t.Errorf("expected %s, got %s", expected, m)
+	}
+	if c, _ := m.Get("c"); c.(*Map).Len() != 2 {
+		t.Errorf("expected a nested Map of 2 entries, got %s", c)
+	}
+
+	for in, expectedErr := range map[string]string{
+		`[1, 2]`: "cannot unmarshal JSON array into key.Map, expected an object",
+		`null`:   "cannot unmarshal JSON null into key.Map, expected an object",
+		`"foo"`:  "cannot unmarshal JSON string into key.Map, expected an object",
+		`42`:     "cannot unmarshal JSON number into key.Map, expected an object",
+	} {
+		if m, err := NewMapFromJSON([]byte(in)); err == nil || err.Error() != expectedErr {
+			t.Errorf("expected error %q for %s, got %v, %v", expectedErr, in, m, err)
+		}
+	}
+	for _, in := range []string{`{"a":`, `{"a": 1} {}`, `{"a": 1} x`, `{"a": [1}`, `{1: 2}`} {
+		if m, err := NewMapFromJSON([]byte(in)); err == nil {
+			t.Errorf("expected an error for invalid JSON %s, got %s", in, m)
+		}
+	}
+	m, err = NewMapFromJSON([]byte(` {"a": 1, "b": [[{"c": []}], null], "a": 2} `))
+	expected = NewMap("a", float64(2),
+		"b", []interface{}{[]interface{}{NewMap("c", []interface{}{})}, nil})
+	if err != nil || m.Len() != 2 || !m.EqualDepth(expected, 5) {
+		t.Errorf("expected %s, got %s (%v)", expected, m, err)
+	}
+
+	// UnmarshalJSON replaces the entries of the Map on success only.
+	m = NewMap("x", 1)
+	if err := json.Unmarshal([]byte(`[]`), m); err == nil || !m.Equal(NewMap("x", 1)) {
+		t.Errorf("expected an error leaving the Map untouched, got %v: %s", err, m)
+	}
+	if err := json.Unmarshal([]byte(`{"y": 2}`), m); err != nil ||
+		!m.Equal(NewMap("y", float64(2))) {
+		t.Errorf("expected the Map to be replaced, got %v: %s", err, m)
+	}
+}
+
+func TestMapSnapshot(t *testing.T) {
+	newMap := func() *Map {
+		return NewMap(
+			"a", 1,
+			"b", 2,
+			dumbHashable{dumb: "c"}, 3,
+			dumbHashable{dumb: "d"}, 4,
+			dumbHashable{dumb: "e"}, 5,
+		)
+	}
+	mutations := map[string]func(m *Map){
+		"Set":        func(m *Map) { m.Set("a", 10) },
+		"Set new":    func(m *Map) { m.Set("f", 6) },
+		"Set chain":  func(m *Map) { m.Set(dumbHashable{dumb: "d"}, 40) },
+		"Set append": func(m *Map) { m.Set(dumbHashable{dumb: "f"}, 6) },
+		"Apply": func(m *Map) {
+			m.Apply(dumbHashable{dumb: "e"}, func(v interface{}, _ bool) interface{} {
+				return v.(int) + 1
+			})
+		},
+		"Del":       func(m *Map) { m.Del("b") },
+		"Del chain": func(m *Map) { m.Del(dumbHashable{dumb: "d"}) },
+		"DeleteFunc": func(m *Map) {
+			m.DeleteFunc(func(k, v interface{}) bool { return v.(int)%2 == 1 })
+		},
+		"Clear":  func(m *Map) { m.Clear() },
+		"Resize": func(m *Map) { m.Del("a"); m.Resize() },
+	}
+	for name, mutate := range mutations {
+		// Mutating the Map leaves the snapshot untouched.
+		m := newMap()
+		snapshot := m.Snapshot()
+		mutate(m)
+		if !snapshot.Equal(newMap()) {
+			t.Errorf("%s: snapshot changed to %s after the Map changed to %s",
+				name, snapshot, m)
+		}
+		expected := newMap()
+		mutate(expected)
+		if !m.Equal(expected) {
+			t.Errorf("%s: expected the Map to be %s, got %s", name, expected, m)
+		}
+
+		// Mutating the snapshot leaves the Map untouched.
+		m = newMap()
+		snapshot = m.Snapshot()
+		mutate(snapshot)
+		if !m.Equal(newMap()) {
+			t.Errorf("%s: Map changed to %s after the snapshot changed to %s",
+				name, m, snapshot)
+		}
+		if !snapshot.Equal(expected) {
+			t.Errorf("%s: expected the snapshot to be %s, got %s", name, expected, snapshot)
+		}
+	}
+
+	// Successive snapshots each keep their own version.
+	m := NewMap("a", 1)
+	v1 := m.Snapshot()
+	m.Set("a", 2)
+	v2 := m.Snapshot()
+	m.Set("a", 3)
+	for i, v := range []*Map{v1, v2, m} {
+		if !v.Equal(NewMap("a", i+1)) {
+			t.Errorf("expected version %d to hold a:%d, got %s", i+1, i+1, v)
+		}
+	}
+
+	var nilMap *Map
+	if nilMap.Snapshot() != nil {
+		t.Error("expected the snapshot of a nil Map to be nil")
+	}
+}
+
+func TestMapEqualNaN(t *testing.T) {
+	nan32 := float32(math.NaN())
+	a := NewMap("f64", math.NaN(), "f32", nan32, dumbHashable{dumb: 1}, math.NaN())
+	b := NewMap("f64", math.NaN(), "f32", nan32, dumbHashable{dumb: 1}, math.NaN())
+	if !a.Equal(a) || !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("expected %s to equal %s", a, b)
+	}
+	if !a.EqualDepth(b, 0) || !a.EqualOpts(b) {
+		t.Errorf("expected %s to equal %s with EqualDepth and EqualOpts", a, b)
+	}
+	if _, _, _, equal := a.Diff(b); !equal {
+		t.Errorf("expected no difference between %s and %s", a, b)
+	}
+	if !NewMap("n", NewMap("f", math.NaN())).Equal(NewMap("n", NewMap("f", math.NaN()))) {
+		t.Error("expected nested Maps holding NaNs to be equal")
+	}
+	// Equal Maps have the same hash, so that they can be used as keys.
+	if a.Hash() != b.Hash() || a.Hash() != a.Hash() {
+		t.Errorf("expected %s and %s to have the same hash, got %d and %d",
+			a, b, a.Hash(), b.Hash())
+	}
+	nested := NewMap(NewMap("f", math.NaN()), 1)
+	if v, ok := nested.Get(NewMap("f", math.NaN())); !ok || v != 1 {
+		t.Errorf("expected to find a Map holding a NaN in %s", nested)
+	}
+	for _, other := range []*Map{
+		NewMap("f64", 1.0, "f32", nan32, dumbHashable{dumb: 1}, math.NaN()),
+		NewMap("f64", nan32, "f32", nan32, dumbHashable{dumb: 1}, math.NaN()),
+		NewMap("f64", math.NaN(), "f32", math.NaN(), dumbHashable{dumb: 1}, math.NaN()),
+	} {
+		if a.Equal(other) || other.Equal(a) {
+			t.Errorf("expected %s not to equal %s", a, other)
+		}
+	}
+}
+
+func TestMapEqualBytes(t *testing.T) {
+	a := NewMap("a", []byte("payload"), dumbHashable{dumb: 1}, []byte{})
+	b := NewMap("a", []byte("payload"), dumbHashable{dumb: 1}, []byte{})
+	if !a.Equal(b) || !b.Equal(a) || !a.EqualDepth(b, 0) || !a.EqualOpts(b) {
+		t.Errorf("expected %s to equal %s", a, b)
+	}
+	if _, _, _, equal := a.Diff(b); !equal {
+		t.Errorf("expected no difference between %s and %s", a, b)
+	}
+	for _, other := range []*Map{
+		NewMap("a", []byte("paylaod"), dumbHashable{dumb: 1}, []byte{}),
+		NewMap("a", "payload", dumbHashable{dumb: 1}, []byte{}),
+		NewMap("a", []byte("payload"), dumbHashable{dumb: 1}, []byte{0}),
+	} {
+		if a.Equal(other) || other.Equal(a) {
+			t.Errorf("expected %s not to equal %s", a, other)
+		}
+	}
+}
+
+func TestMapEqualGoMap(t *testing.T) {
+	m := NewMap("a", 1, "b", []byte("c"), "d", NewMap("e", math.NaN()), "f", nil)
+	tcases := []struct {
+		m     *Map
+		o     map[string]interface{}
+		equal bool
+	}{{
+		m: m,
+		o: map[string]interface{}{"a": 1, "b": []byte("c"),
+			"d": map[string]interface{}{"e": math.NaN()}, "f": nil},
+		equal: true,
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": 1, "b": []byte("c"), "d": NewMap("e", math.NaN()),
+			"f": nil},
+		equal: true,
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": 2, "b": []byte("c"),
+			"d": map[string]interface{}{"e": math.NaN()}, "f": nil},
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": int64(1), "b": []byte("c"),
+			"d": map[string]interface{}{"e": math.NaN()}, "f": nil},
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": 1, "b": []byte("c"),
+			"d": map[string]interface{}{"e": math.NaN()}},
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": 1, "b": []byte("c"),
+			"d": map[string]interface{}{"e": math.NaN()}, "f": nil, "g": nil},
+	}, {
+		m: m,
+		o: map[string]interface{}{"a": 1, "b": []byte("c"),
+			"d": map[string]interface{}{"e": 1.0}, "f": nil},
+	}, {
+		m:     NewMap(New("a"), 1),
+		o:     map[string]interface{}{"a": 1},
+		equal: false,
+	}, {
+		m:     NewMap("a", 1, dumbHashable{dumb: "a"}, 1),
+		o:     map[string]interface{}{"a": 1},
+		equal: false,
+	}, {
+		m:     NewMap(1, "a"),
+		o:     map[string]interface{}{"1": "a"},
+		equal: false,
+	}, {
+		m:     nil,
+		o:     map[string]interface{}{},
+		equal: true,
+	}, {
+		m:     NewMap(),
+		o:     nil,
+		equal: true,
+	}, {
+		m:     nil,
+		o:     map[string]interface{}{"a": 1},
+		equal: false,
+	}}
+	for i, tcase := range tcases {
+		if equal := tcase.m.EqualGoMap(tcase.o); equal != tcase.equal {
+			t.Errorf("Test %d failed: expected %s.EqualGoMap(%v) to be %t",
+				i, tcase.m, tcase.o, tcase.equal)
+		}
+	}
+}
+
+func TestMapNilReceiver(t *testing.T) {
+	var m *Map
+	if v, ok := m.Get("a"); ok || v != nil {
+		t.Errorf("Get: expected nil (false), got %v (%t)", v, ok)
+	}
+	if v, ok := m.Get(dumbHashable{dumb: 1}); ok || v != nil {
+		t.Errorf("Get: expected nil (false), got %v (%t)", v, ok)
+	}
+	if m.Contains("a") || m.Contains(dumbHashable{dumb: 1}) {
+		t.Error("Contains: expected false")
+	}
+	if m.Len() != 0 {
+		t.Errorf("Len: expected 0, got %d", m.Len())
+	}
+	if err := m.Iter(func(k, v interface{}) error {
+		return fmt.Errorf("unexpected entry %v: %v", k, v)
+	}); err != nil {
+		t.Errorf("Iter: %s", err)
+	}
+	if keys := m.Keys(); len(keys) != 0 {
+		t.Errorf("Keys: expected no keys, got %v", keys)
+	}
+	if values := m.Values(); len(values) != 0 {
+		t.Errorf("Values: expected no values, got %v", values)
+	}
+	if n := m.Count(func(k, v interface{}) bool { return true }); n != 0 {
+		t.Errorf("Count: expected 0, got %d", n)
+	}
+	if !m.Equal(m) || !m.Equal(NewMap()) || !NewMap().Equal(m) {
+		t.Error("Equal: expected a nil Map to equal an empty Map")
+	}
+	if m.Equal(NewMap("a", 1)) || NewMap("a", 1).Equal(m) {
+		t.Error("Equal: expected a nil Map not to equal a non-empty Map")
+	}
+	if m.Equal(nil) || m.Equal(map[string]interface{}{}) {
+		t.Error("Equal: expected a nil Map not to equal a value that isn't a Map")
+	}
+	if s := m.String(); s != "key.Map(nil)" {
+		t.Errorf("String: expected \"key.Map(nil)\", got %q", s)
+	}
+}
+
+func TestMapKeysValues(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		New(map[string]interface{}{"a": 1}), 2,
+		dumbHashable{dumb: 1}, 3,
+	)
+	keys, values := m.Keys(), m.Values()
+	if len(keys) != 3 || len(values) != 3 {
+		t.Fatalf("expected 3 keys and values, got %v and %v", keys, values)
+	}
+	for _, k := range []interface{}{"a", New(map[string]interface{}{"a": 1}),
+		dumbHashable{dumb: 1}} {
+		if !contains(keys, k) {
+			t.Errorf("expected %v in keys %v", k, keys)
+		}
+		if !m.Contains(k) {
+			t.Errorf("expected map to contain %v", k)
+		}
+	}
+	for _, v := range []interface{}{1, 2, 3} {
+		if !contains(values, v) {
+			t.Errorf("expected %v in values %v", v, values)
+		}
+	}
+	if m.Contains("b") || m.Contains(dumbHashable{dumb: 2}) {
+		t.Error("unexpected key found in map")
+	}
+}
+
+func TestMapSortedEntries(t *testing.T) {
+	m := NewMap(
+		"b", 1,
+		int64(10), 2,
+		"a", 3,
+		uint32(2), 4,
+		New(map[string]interface{}{"a": 1}), 5,
+		int64(-1), 6,
+	)
+	expected := []Entry{
+		{int64(-1), 6},
+		{uint32(2), 4},
+		{int64(10), 2},
+		{"a", 3},
+		{"b", 1},
+		{New(map[string]interface{}{"a": 1}), 5},
+	}
+	for i := 0; i < 10; i++ {
+		entries := m.SortedEntries(nil)
+		if len(entries) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, entries)
+		}
+		for j, e := range entries {
+			if !Equal(e.Key, expected[j].Key) || e.Value != expected[j].Value {
+				t.Fatalf("expected %v, got %v", expected, entries)
+			}
+		}
+	}
+
+	descending := m.SortedEntries(func(a, b interface{}) bool { return Compare(a, b) > 0 })
+	for j, e := range descending {
+		if k := expected[len(expected)-1-j].Key; !Equal(e.Key, k) {
+			t.Fatalf("expected key %v at index %d, got %v", k, j, descending)
+		}
+	}
+
+	var nilMap *Map
+	if entries := nilMap.SortedEntries(nil); len(entries) != 0 {
+		t.Errorf("expected no entries, got %v", entries)
+	}
+}
+
+func TestMapIterByValue(t *testing.T) {
+	m := NewMap(
+		"eth0", uint64(30),
+		"eth1", uint64(10),
+		"eth2", uint64(50),
+		"eth3", uint64(10),
+		dumbHashable{dumb: "eth4"}, uint64(20),
+	)
+	var keys []string
+	collect := func(k, v interface{}) error {
+		keys = append(keys, fmt.Sprint(k))
+		return nil
+	}
+	if err := m.IterByValue(nil, collect); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(keys, ","); s != "eth1,eth3,{eth4},eth0,eth2" {
+		t.Errorf("unexpected order of keys: %s", s)
+	}
+
+	// Visit the top 2 entries, by descending value.
+	keys = nil
+	errDone := errors.New("done")
+	err := m.IterByValue(func(a, b interface{}) bool {
+		return a.(uint64) > b.(uint64)
+	}, func(k, v interface{}) error {
+		if len(keys) == 2 {
+			return errDone
+		}
+		return collect(k, v)
+	})
+	if err != errDone {
+		t.Errorf("expected error %v, got %v", errDone, err)
+	}
+	if s := strings.Join(keys, ","); s != "eth2,eth0" {
+		t.Errorf("unexpected top 2 keys: %s", s)
+	}
+
+	var nilMap *Map
+	if err := nilMap.IterByValue(nil, func(k, v interface{}) error {
+		return fmt.Errorf("unexpected entry %v: %v", k, v)
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapIterBatch(t *testing.T) {
+	m := NewMap()
+	for i := 0; i < 10; i++ {
+		m.Set(fmt.Sprint(i), i)
+		m.Set(dumbHashable{dumb: i}, i)
+	}
+	tests := []struct {
+		n     int
+		sizes []int
+	}{
+		{n: 1, sizes: []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{n: 0, sizes: []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{n: 6, sizes: []int{6, 6, 6, 2}},
+		{n: 10, sizes: []int{10, 10}},
+		{n: 20, sizes: []int{20}},
+		{n: 100, sizes: []int{20}},
+	}
+	for i, tcase := range tests {
+		var sizes []int
+		seen := NewMap()
+		err := m.IterBatch(tcase.n, func(batch []Entry) error {
+			sizes = append(sizes, len(batch))
+			for _, e := range batch {
+				if seen.Contains(e.Key) {
+					t.Errorf("Test %d failed: key %v seen twice", i, e.Key)
+				}
+				seen.Set(e.Key, e.Value)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Test %d failed: %s", i, err)
+		}
+		if fmt.Sprint(sizes) != fmt.Sprint(tcase.sizes) {
+			t.Errorf("Test %d failed: expected batches of %v, got %v", i, tcase.sizes, sizes)
+		}
+		if !seen.Equal(m) || seen.Len() != m.Len() {
+			t.Errorf("Test %d failed: expected %s, got %s", i, m, seen)
+		}
+	}
+
+	errDone := errors.New("done")
+	calls := 0
+	err := m.IterBatch(3, func(batch []Entry) error {
+		calls++
+		return errDone
+	})
+	if err != errDone || calls != 1 {
+		t.Errorf("expected IterBatch to stop with %v after 1 call, got %v after %d calls",
+			errDone, err, calls)
+	}
+	// An error from the final partial batch is returned too.
+	err = m.IterBatch(15, func(batch []Entry) error {
+		if len(batch) < 15 {
+			return errDone
+		}
+		return nil
+	})
+	if err != errDone {
+		t.Errorf("expected %v, got %v", errDone, err)
+	}
+
+	if err := NewMap().IterBatch(3, func(batch []Entry) error {
+		return fmt.Errorf("unexpected batch %v", batch)
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapEstimatedSize(t *testing.T) {
+	var nilMap *Map
+	empty := NewMap()
+	if nilMap.EstimatedSize() != empty.EstimatedSize() || empty.EstimatedSize() <= 0 {
+		t.Errorf("expected nil and empty Maps to have the same positive size, got %d and %d",
+			nilMap.EstimatedSize(), empty.EstimatedSize())
+	}
+	m := NewMap()
+	prev := m.EstimatedSize()
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+		m.Set(dumbHashable{dumb: i}, i)
+		if size := m.EstimatedSize(); size <= prev {
+			t.Fatalf("expected size to grow past %d after %d inserts, got %d", prev, i+1, size)
+		} else {
+			prev = size
+		}
+	}
+	// Replacing values doesn't change the size.
+	m.Set(1, "a")
+	m.Set(dumbHashable{dumb: 1}, "a")
+	if size := m.EstimatedSize(); size != prev {
+		t.Errorf("expected size %d, got %d", prev, size)
+	}
+
+	// Colliding Hashable keys cost more than native keys, since they
+	// are chained.
+	native, colliding := NewMap(), NewMap()
+	for i := 0; i < 100; i++ {
+		native.Set(i, i)
+		colliding.Set(dumbHashable{dumb: i}, i)
+	}
+	if native.EstimatedSize() >= colliding.EstimatedSize() {
+		t.Errorf("expected %d < %d", native.EstimatedSize(), colliding.EstimatedSize())
+	}
+	prev = colliding.EstimatedSize()
+	colliding.Del(dumbHashable{dumb: 50})
+	if size := colliding.EstimatedSize(); size >= prev {
+		t.Errorf("expected size to shrink after a deletion, got %d", size)
+	}
+}
+
+// cancelingContext is a context that is canceled after its Err method
+// was called a given number of times.
+type cancelingContext struct {
+	context.Context
+	calls, cancelAfter int
+}
+
+func (c *cancelingContext) Err() error {
+	c.calls++
+	if c.calls > c.cancelAfter {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestMapEqualContext(t *testing.T) {
+	a, b := NewMap(), NewMap()
+	for i := 0; i < 5000; i++ {
+		a.Set(i, i)
+		b.Set(i, i)
+	}
+	nestedA, nestedB := NewMap(), NewMap()
+	for i := 0; i < 5000; i++ {
+		nestedA.Set(dumbHashable{dumb: i % 10}, fmt.Sprint(i))
+		nestedB.Set(dumbHashable{dumb: i % 10}, fmt.Sprint(i))
+		nestedA.Set(fmt.Sprint(i), i)
+		nestedB.Set(fmt.Sprint(i), i)
+	}
+	a.Set("nested", nestedA)
+	b.Set("nested", nestedB)
+
+	ctx := &cancelingContext{Context: context.Background(), cancelAfter: 100}
+	if equal, err := a.EqualContext(ctx, b); !equal || err != nil {
+		t.Errorf("expected the Maps to be equal, got %t, %v", equal, err)
+	}
+	// The context is checked before starting, then every 1024 of the
+	// 10011 entries of a and its nested Map.
+	if ctx.calls != 1+10011/equalContextInterval {
+		t.Errorf("expected %d checks of the context, got %d",
+			1+10011/equalContextInterval, ctx.calls)
+	}
+
+	// Cancel the context mid-comparison.
+	ctx = &cancelingContext{Context: context.Background(), cancelAfter: 3}
+	if equal, err := a.EqualContext(ctx, b); equal || err != context.Canceled {
+		t.Errorf("expected %v, got %t, %v", context.Canceled, equal, err)
+	}
+	if ctx.calls != 4 {
+		t.Errorf("expected the comparison to stop at the 4th check, got %d", ctx.calls)
+	}
+
+	nestedB.Set("0", -1)
+	ctx = &cancelingContext{Context: context.Background(), cancelAfter: 100}
+	if equal, err := a.EqualContext(ctx, b); equal || err != nil {
+		t.Errorf("expected the Maps not to be equal, got %t, %v", equal, err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if equal, err := NewMap().EqualContext(canceled, NewMap()); equal ||
+		err != context.Canceled {
+		t.Errorf("expected %v, got %t, %v", context.Canceled, equal, err)
+	}
+}
+
+func TestMapEqualWithKeyEq(t *testing.T) {
+	foldEq := func(a, b interface{}) bool {
+		as, aOk := a.(string)
+		bs, bOk := b.(string)
+		if aOk && bOk {
+			return strings.EqualFold(as, bs)
+		}
+		return Compare(a, b) == 0
+	}
+	tests := []struct {
+		a, b   *Map
+		keyEq  func(a, b interface{}) bool
+		result bool
+	}{{
+		a:      NewMap("Ethernet1", 1, "ethernet2", 2),
+		b:      NewMap("ETHERNET1", 1, "Ethernet2", 2),
+		keyEq:  foldEq,
+		result: true,
+	}, {
+		a:      NewMap("Ethernet1", 1, "ethernet2", 2),
+		b:      NewMap("ETHERNET1", 1, "Ethernet2", 2),
+		result: false,
+	}, {
+		a:      NewMap("Ethernet1", 1, "ethernet2", 2),
+		b:      NewMap("ETHERNET1", 1, "Ethernet2", 3),
+		keyEq:  foldEq,
+		result: false,
+	}, {
+		// Each entry of b is matched at most once.
+		a:      NewMap("a", 1, "A", 1),
+		b:      NewMap("a", 1, "b", 1),
+		keyEq:  foldEq,
+		result: false,
+	}, {
+		a:      NewMap("a", 1, "A", 1),
+		b:      NewMap("A", 1, "a", 1),
+		keyEq:  foldEq,
+		result: true,
+	}, {
+		a:      NewMap(dumbHashable{dumb: "x"}, 1, int64(1), "one"),
+		b:      NewMap(dumbHashable{dumb: "x"}, 1, int64(1), "one"),
+		keyEq:  foldEq,
+		result: true,
+	}, {
+		a:      NewMap("a", 1),
+		b:      NewMap("A", 1, "b", 2),
+		keyEq:  foldEq,
+		result: false,
+	}, {
+		a:      nil,
+		b:      NewMap(),
+		keyEq:  foldEq,
+		result: true,
+	}}
+	for i, tcase := range tests {
+		if r := tcase.a.EqualWithKeyEq(tcase.b, tcase.keyEq); r != tcase.result {
+			t.Errorf("Test %d failed: expected %t comparing %s and %s",
+				i, tcase.result, tcase.a, tcase.b)
+		}
+	}
+}
+
+func TestMapAccessHook(t *testing.T) {
+	m := NewMap("a", 1, dumbHashable{dumb: "b"}, 2)
+	var accesses []string
+	m.SetAccessHook(func(op string, k interface{}) {
+		accesses = append(accesses, fmt.Sprintf("%s %v", op, k))
+	})
+	m.Get("a")
+	m.Contains(dumbHashable{dumb: "c"})
+	m.Set("d", 3)
+	m.Del("a")
+	m.Del("missing")
+	m.GetMulti("d", dumbHashable{dumb: "b"})
+	var iterated []string
+	_ = m.Iter(func(k, v interface{}) error {
+		iterated = append(iterated, fmt.Sprintf("Iter %v", k))
+		return nil
+	})
+	sort.Strings(iterated)
+	expected := append([]string{
+		"Get a",
+		"Get {c}",
+		"Set d",
+		"Del a",
+		"Del missing",
+		"Get d",
+		"Get {b}",
+	}, iterated...)
+	if len(accesses) != len(expected) {
+		t.Fatalf("expected accesses %q, got %q", expected, accesses)
+	}
+	// Iter visits entries in no particular order.
+	sort.Strings(accesses[len(accesses)-2:])
+	if strings.Join(accesses, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected accesses %q, got %q", expected, accesses)
+	}
+
+	// Apply and DeleteFunc report the entries they set and remove.
+	accesses = nil
+	m.Apply("d", func(old interface{}, found bool) interface{} { return 4 })
+	m.Set("e", 5)
+	m.DeleteFunc(func(k, v interface{}) bool { return v == 5 })
+	expected = []string{"Set d", "Set e", "Del e"}
+	if strings.Join(accesses, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected accesses %q, got %q", expected, accesses)
+	}
+
+	// Clear reports every entry it removes, in no particular order,
+	// and the hook survives the replacement of the storage of the Map.
+	snapshot := m.Snapshot()
+	accesses = nil
+	m.Clear()
+	sort.Strings(accesses)
+	expected = []string{"Del d", "Del {b}"}
+	if strings.Join(accesses, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected accesses %q, got %q", expected, accesses)
+	}
+	accesses = nil
+	m.Set("a", 1)
+	m.Get("a")
+	if err := m.UnmarshalJSON([]byte(`{"b": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+	m.Del("b")
+	encoded, _ := NewMap("c", 3).MarshalBinary()
+	if err := m.UnmarshalBinary(encoded); err != nil {
+		t.Fatal(err)
+	}
+	m.Get("c")
+	expected = []string{"Set a", "Get a", "Del b", "Get c"}
+	if strings.Join(accesses, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected accesses %q, got %q", expected, accesses)
+	}
+	if snapshot.Len() == 0 {
+		t.Errorf("expected the snapshot to be left untouched by Clear")
+	}
+
+	accesses = nil
+	m.SetAccessHook(nil)
+	m.Get("d")
+	m.Set("e", 4)
+	if len(accesses) != 0 {
+		t.Errorf("expected no accesses to be recorded, got %q", accesses)
+	}
+}
+
+func TestMapDiffAll(t *testing.T) {
+	a := NewMap(
+		"same", 1,
+		"onlyA", 2,
+		"changed", 3,
+		dumbHashable{dumb: "same"}, 4,
+		dumbHashable{dumb: "onlyA"}, 5,
+		dumbHashable{dumb: "changed"}, 6,
+		New(map[string]interface{}{"k": "v"}), NewMap("x", 7),
+	)
+	b := NewMap(
+		"same", 1,
+		"onlyB", 8,
+		"changed", -3,
+		dumbHashable{dumb: "same"}, 4,
+		dumbHashable{dumb: "onlyB"}, 9,
+		dumbHashable{dumb: "changed"}, -6,
+		New(map[string]interface{}{"k": "v"}), NewMap("x", 7),
+	)
+	onlyA, onlyB, valueDiffs := a.DiffAll(b)
+	if expected := NewMap("onlyA", 2, dumbHashable{dumb: "onlyA"}, 5); !onlyA.Equal(expected) {
+		t.Errorf("onlyA: expected %s, got %s", expected, onlyA)
+	}
+	if expected := NewMap("onlyB", 8, dumbHashable{dumb: "onlyB"}, 9); !onlyB.Equal(expected) {
+		t.Errorf("onlyB: expected %s, got %s", expected, onlyB)
+	}
+	expected := NewMap("changed", 3, dumbHashable{dumb: "changed"}, 6)
+	if !valueDiffs.Equal(expected) {
+		t.Errorf("valueDiffs: expected %s, got %s", expected, valueDiffs)
+	}
+
+	for _, maps := range [][2]*Map{{a, a}, {nil, NewMap()}, {nil, nil}} {
+		onlyA, onlyB, valueDiffs := maps[0].DiffAll(maps[1])
+		if onlyA.Len() != 0 || onlyB.Len() != 0 || valueDiffs.Len() != 0 {
+			t.Errorf("expected no differences between %s and %s, got %s, %s, %s",
+				maps[0], maps[1], onlyA, onlyB, valueDiffs)
+		}
+	}
+}
+
+func TestMapLoadOrCompute(t *testing.T) {
+	m := NewMap("a", 1, dumbHashable{dumb: "b"}, 2)
+	calls := 0
+	compute := func(v interface{}) func() interface{} {
+		return func() interface{} {
+			calls++
+			return v
+		}
+	}
+	tests := []struct {
+		k      interface{}
+		v      interface{}
+		loaded bool
+		calls  int
+	}{
+		{k: "a", v: 1, loaded: true, calls: 0},
+		{k: dumbHashable{dumb: "b"}, v: 2, loaded: true, calls: 0},
+		{k: "c", v: 3, loaded: false, calls: 1},
+		{k: "c", v: 3, loaded: true, calls: 1},
+		{k: dumbHashable{dumb: "d"}, v: 4, loaded: false, calls: 2},
+		{k: dumbHashable{dumb: "d"}, v: 4, loaded: true, calls: 2},
+		{k: New(map[string]interface{}{"e": true}), v: 5, loaded: false, calls: 3},
+		{k: New(map[string]interface{}{"e": true}), v: 5, loaded: true, calls: 3},
+		{k: nil, v: nil, loaded: false, calls: 3},
+	}
+	for i, tcase := range tests {
+		v, loaded := m.LoadOrCompute(tcase.k, compute(tcase.v))
+		if v != tcase.v || loaded != tcase.loaded || calls != tcase.calls {
+			t.Errorf("Test %d failed: expected %v (%t) after %d calls, got %v (%t) after %d",
+				i, tcase.v, tcase.loaded, tcase.calls, v, loaded, calls)
+		}
+	}
+	expected := NewMap("a", 1, dumbHashable{dumb: "b"}, 2, "c", 3, dumbHashable{dumb: "d"}, 4,
+		New(map[string]interface{}{"e": true}), 5)
+	if !m.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, m)
+	}
+}
+
+func TestMapEqualStream(t *testing.T) {
+	tests := []struct {
+		a, b   *Map
+		result bool
+	}{{
+		a:      nil,
+		b:      NewMap(),
+		result: true,
+	}, {
+		a:      NewMap("a", 1),
+		b:      nil,
+		result: false,
+	}, {
+		a:      NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 3),
+		b:      NewMap(dumbHashable{dumb: 2}, 3, dumbHashable{dumb: 1}, 2, "a", 1),
+		result: true,
+	}, {
+		a:      NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 3),
+		b:      NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 4),
+		result: false,
+	}, {
+		a:      NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 3),
+		b:      NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 3}, 3),
+		result: false,
+	}, {
+		a:      NewMap("a", NewMap("b", []byte("c"), "d", math.NaN())),
+		b:      NewMap("a", NewMap("b", []byte("c"), "d", math.NaN())),
+		result: true,
+	}, {
+		a:      NewMap("a", 1),
+		b:      NewMap("b", 1),
+		result: false,
+	}}
+	for i, tcase := range tests {
+		if r := tcase.a.EqualStream(tcase.b); r != tcase.result {
+			t.Errorf("Test %d failed: expected %t comparing %s and %s",
+				i, tcase.result, tcase.a, tcase.b)
+		}
+		if r := tcase.a.Equal(tcase.b); r != tcase.result {
+			t.Errorf("Test %d is inconsistent with Equal", i)
+		}
+	}
+}
+
+// TestMapEqualAllocs checks that comparing Maps doesn't allocate
+// scratch memory proportional to their size.
+func TestMapEqualAllocs(t *testing.T) {
+	a, b, c := NewMap(), NewMap(), NewMap()
+	for i := 0; i < 1000; i++ {
+		for _, m := range []*Map{a, b, c} {
+			m.Set(int64(i), i)
+			m.Set(dumbHashable{dumb: i}, i)
+		}
+	}
+	c.Set(dumbHashable{dumb: 999}, -1)
+	if allocs := testing.AllocsPerRun(10, func() { a.Equal(b) }); allocs != 0 {
+		t.Errorf("expected Equal not to allocate, got %v allocations", allocs)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { a.Equal(c) }); allocs > 1 {
+		t.Errorf("expected Equal to allocate at most 1 error, got %v allocations", allocs)
+	}
+	for _, other := range []*Map{b, c} {
+		if allocs := testing.AllocsPerRun(10, func() { a.EqualStream(other) }); allocs != 0 {
+			t.Errorf("expected EqualStream not to allocate, got %v allocations", allocs)
+		}
+	}
+}
+
+func TestMapEqualOpts(t *testing.T) {
+	type record struct {
+		names []string
+	}
+	shared := NewMap("x", 1)
+	tests := []struct {
+		a, b   *Map
+		opts   []EqualOption
+		result bool
+	}{{ // no options behaves like Equal
+		a:      NewMap("a", 1, "b", NewMap("c", 2)),
+		b:      NewMap("a", 1, "b", NewMap("c", 2)),
+		result: true,
+	}, {
+		a:      NewMap("a", 1),
+		b:      NewMap("a", 2),
+		result: false,
+	}, { // deep values
+		a:      NewMap("a", record{names: []string{"x"}}),
+		b:      NewMap("a", record{names: []string{"x"}}),
+		opts:   []EqualOption{WithDeepValues()},
+		result: true,
+	}, { // ignored keys, on either side and in nested Maps
+		a:      NewMap("a", 1, "ts", 10, "n", NewMap("b", 2, "ts", 11)),
+		b:      NewMap("a", 1, "n", NewMap("b", 2, "ts", 12)),
+		opts:   []EqualOption{WithIgnoreKeys("ts")},
+		result: true,
+	}, {
+		a:      NewMap("a", 1, "ts", 10),
+		b:      NewMap("a", 1, "ts", 11, "c", 3),
+		opts:   []EqualOption{WithIgnoreKeys("ts")},
+		result: false,
+	}, { // ignored Hashable keys
+		a:      NewMap("a", 1, dumbHashable{dumb: "h"}, 1),
+		b:      NewMap("a", 1, dumbHashable{dumb: "h"}, 2),
+		opts:   []EqualOption{WithIgnoreKeys(dumbHashable{dumb: "h"})},
+		result: true,
+	}, { // numeric coercion
+		a:      NewMap("a", int64(1), "b", New(uint8(2))),
+		b:      NewMap("a", float64(1), "b", New(float32(2))),
+		opts:   []EqualOption{WithNumericCoercion()},
+		result: true,
+	}, {
+		a:      NewMap("a", int64(1)),
+		b:      NewMap("a", float64(1)),
+		result: false,
+	}, {
+		a:      NewMap("a", int64(1)),
+		b:      NewMap("a", true),
+		opts:   []EqualOption{WithNumericCoercion()},
+		result: false,
+	}, { // max depth
+		a:      NewMap("a", NewMap("b", NewMap("c", 1))),
+		b:      NewMap("a", NewMap("b", NewMap("c", 1))),
+		opts:   []EqualOption{WithMaxDepth(1)},
+		result: false,
+	}, {
+		a:      NewMap("a", NewMap("b", shared)),
+		b:      NewMap("a", NewMap("b", shared)),
+		opts:   []EqualOption{WithMaxDepth(1)},
+		result: true,
+	}, {
+		a:      NewMap("a", NewMap("b", NewMap("c", 1))),
+		b:      NewMap("a", NewMap("b", NewMap("c", 1))),
+		opts:   []EqualOption{WithMaxDepth(2)},
+		result: true,
+	}, { // all options combined
+		a: NewMap("a", int64(1), "ts", 1,
+			"n", NewMap("r", record{names: []string{"x"}}, "v", uint32(3), "ts", 2)),
+		b: NewMap("a", float64(1),
+			"n", NewMap("r", record{names: []string{"x"}}, "v", int8(3))),
+		opts: []EqualOption{WithDeepValues(), WithIgnoreKeys("ts"), WithNumericCoercion(),
+			WithMaxDepth(1)},
+		result: true,
+	}, {
+		a: NewMap("a", int64(1), "ts", 1,
+			"n", NewMap("r", record{names: []string{"x"}}, "v", uint32(3), "ts", 2)),
+		b: NewMap("a", float64(1),
+			"n", NewMap("r", record{names: []string{"y"}}, "v", int8(3))),
+		opts: []EqualOption{WithDeepValues(), WithIgnoreKeys("ts"), WithNumericCoercion(),
+			WithMaxDepth(1)},
+		result: false,
+	}, {
+		a: NewMap("a", int64(1), "ts", 1,
+			"n", NewMap("r", record{names: []string{"x"}}, "v", uint32(3), "ts", 2)),
+		b: NewMap("a", float64(1),
+			"n", NewMap("r", record{names: []string{"x"}}, "v", int8(3))),
+		opts: []EqualOption{WithDeepValues(), WithIgnoreKeys("ts"), WithNumericCoercion(),
+			WithMaxDepth(0)},
+		result: false,
 	}}
+	for i, tcase := range tests {
+		if tcase.a.EqualOpts(tcase.b, tcase.opts...) != tcase.result {
+			t.Errorf("Test %d failed: expected EqualOpts(%v, %v) to be %t",
+				i, tcase.a, tcase.b, tcase.result)
+		}
+		if tcase.b.EqualOpts(tcase.a, tcase.opts...) != tcase.result {
+			t.Errorf("Test %d failed: expected EqualOpts(%v, %v) to be %t",
+				i, tcase.b, tcase.a, tcase.result)
+		}
+		if len(tcase.opts) == 0 && tcase.a.Equal(tcase.b) != tcase.result {
+			t.Errorf("Test %d failed: EqualOpts and Equal disagree", i)
+		}
+	}
+}
 
-	for _, tcase := range tests {
-		if tcase.a.Equal(tcase.b) != tcase.result {
-			t.Errorf("%v and %v are not equal", tcase.a, tcase.b)
+func TestMapEqualDeepValues(t *testing.T) {
+	type record struct {
+		Name  string
+		Addrs []string
+	}
+	tests := []struct {
+		a      *Map
+		b      *Map
+		result bool
+	}{{
+		a:      nil,
+		b:      NewMap(),
+		result: true,
+	}, {
+		a:      NewMap("a", []string{"x", "y"}),
+		b:      NewMap("a", []string{"x", "y"}),
+		result: true,
+	}, {
+		a:      NewMap("a", []string{"x", "y"}),
+		b:      NewMap("a", []string{"y", "x"}),
+		result: false,
+	}, {
+		a:      NewMap("a", []string{"x"}),
+		b:      NewMap("b", []string{"x"}),
+		result: false,
+	}, {
+		a: NewMap(
+			dumbHashable{dumb: 1}, record{Name: "a", Addrs: []string{"10.0.0.1"}},
+			New(map[string]interface{}{"k": 1}), []int{1, 2},
+		),
+		b: NewMap(
+			dumbHashable{dumb: 1}, record{Name: "a", Addrs: []string{"10.0.0.1"}},
+			New(map[string]interface{}{"k": 1}), []int{1, 2},
+		),
+		result: true,
+	}, {
+		a:      NewMap(dumbHashable{dumb: 1}, record{Name: "a", Addrs: []string{"10.0.0.1"}}),
+		b:      NewMap(dumbHashable{dumb: 1}, record{Name: "a", Addrs: []string{"10.0.0.2"}}),
+		result: false,
+	}, {
+		a: NewMap("a", NewMap(dumbHashable{dumb: 1}, []string{"x"},
+			dumbHashable{dumb: 2}, []string{"y"})),
+		b: NewMap("a", NewMap(dumbHashable{dumb: 2}, []string{"y"},
+			dumbHashable{dumb: 1}, []string{"x"})),
+		result: true,
+	}, {
+		a:      NewMap("a", NewMap("b", []string{"x"})),
+		b:      NewMap("a", NewMap("b", []string{"y"})),
+		result: false,
+	}, {
+		a:      NewMap("a", int32(1)),
+		b:      NewMap("a", int64(1)),
+		result: false,
+	}}
+	for i, tcase := range tests {
+		if tcase.a.EqualDeepValues(tcase.b) != tcase.result {
+			t.Errorf("Test %d failed: expected %t for %v and %v", i, tcase.result, tcase.a,
+				tcase.b)
+		}
+		if tcase.b.EqualDeepValues(tcase.a) != tcase.result {
+			t.Errorf("Test %d failed: expected %t for %v and %v", i, tcase.result, tcase.b,
+				tcase.a)
+		}
+	}
+}
+
+func TestMapDiff(t *testing.T) {
+	tests := []struct {
+		a     *Map
+		b     *Map
+		k     interface{}
+		va    interface{}
+		vb    interface{}
+		equal bool
+	}{{
+		a:     nil,
+		b:     NewMap(),
+		equal: true,
+	}, {
+		a:     NewMap("a", 1, dumbHashable{dumb: 1}, NewMap("b", 2)),
+		b:     NewMap("a", 1, dumbHashable{dumb: 1}, map[string]interface{}{"b": 2}),
+		equal: true,
+	}, {
+		a:  NewMap("a", 1, "b", 2),
+		b:  NewMap("a", 1, "b", 3),
+		k:  "b",
+		va: 2,
+		vb: 3,
+	}, {
+		a:  NewMap("a", 1, "b", 2),
+		b:  NewMap("a", 1),
+		k:  "b",
+		va: 2,
+	}, {
+		a:  NewMap("a", 1),
+		b:  NewMap("a", 1, "b", 2),
+		k:  "b",
+		vb: 2,
+	}, {
+		a:  NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 3),
+		b:  NewMap("a", 1, dumbHashable{dumb: 1}, 2, dumbHashable{dumb: 2}, 4),
+		k:  dumbHashable{dumb: 2},
+		va: 3,
+		vb: 4,
+	}, {
+		a:  NewMap(New(map[string]interface{}{"a": 1}), "x"),
+		b:  NewMap(New(map[string]interface{}{"a": 2}), "x"),
+		k:  New(map[string]interface{}{"a": 1}),
+		va: "x",
+	}, {
+		a:  nil,
+		b:  NewMap("a", nil),
+		k:  "a",
+		va: nil,
+		vb: nil,
+	}}
+	for i, tcase := range tests {
+		k, va, vb, equal := tcase.a.Diff(tcase.b)
+		if equal != tcase.equal {
+			t.Errorf("Test %d failed: expected equal to be %t, got %t", i, tcase.equal, equal)
+		}
+		if equal != tcase.a.Equal(tcase.b) {
+			t.Errorf("Test %d failed: Diff and Equal disagree", i)
+		}
+		if !keyEqual(k, tcase.k) || !valueEqual(va, tcase.va) || !valueEqual(vb, tcase.vb) {
+			t.Errorf("Test %d failed: expected %v (%v, %v), got %v (%v, %v)",
+				i, tcase.k, tcase.va, tcase.vb, k, va, vb)
+		}
+	}
+}
+
+func TestMapEqualDepth(t *testing.T) {
+	nested := func(depth int, leaf interface{}) *Map {
+		m := NewMap("leaf", leaf)
+		for i := 0; i < depth; i++ {
+			m = NewMap("child", m)
+		}
+		return m
+	}
+	shared := NewMap("a", 1)
+	cyclic := NewMap()
+	cyclic.Set("self", cyclic)
+
+	for i, tc := range []struct {
+		a        *Map
+		b        *Map
+		maxDepth int
+		result   bool
+	}{{
+		a:        NewMap(),
+		b:        nil,
+		maxDepth: 0,
+		result:   true,
+	}, {
+		a:        NewMap("a", 1),
+		b:        NewMap("a", 1),
+		maxDepth: 0,
+		result:   true,
+	}, {
+		a:        NewMap("a", 1),
+		b:        NewMap("a", 2),
+		maxDepth: 5,
+		result:   false,
+	}, {
+		a:        nested(1, "x"),
+		b:        nested(1, "x"),
+		maxDepth: 0,
+		result:   false, // distinct nested Maps past maxDepth
+	}, {
+		a:        NewMap("child", shared),
+		b:        NewMap("child", shared),
+		maxDepth: 0,
+		result:   true, // identical nested Maps past maxDepth
+	}, {
+		a:        nested(1, "x"),
+		b:        nested(1, "x"),
+		maxDepth: -1,
+		result:   false,
+	}, {
+		a:        nested(1, "x"),
+		b:        nested(1, "x"),
+		maxDepth: 1,
+		result:   true,
+	}, {
+		a:        nested(10, "x"),
+		b:        nested(10, "x"),
+		maxDepth: 9,
+		result:   false,
+	}, {
+		a:        nested(10, "x"),
+		b:        nested(10, "x"),
+		maxDepth: 10,
+		result:   true,
+	}, {
+		a:        nested(10, "x"),
+		b:        nested(10, "y"),
+		maxDepth: 10,
+		result:   false,
+	}, {
+		a:        cyclic,
+		b:        cyclic,
+		maxDepth: 1000,
+		result:   true,
+	}} {
+		if result := tc.a.EqualDepth(tc.b, tc.maxDepth); result != tc.result {
+			t.Errorf("test case %d: expected EqualDepth(%v, %v, %d) to return %t",
+				i, tc.a, tc.b, tc.maxDepth, tc.result)
 		}
 	}
 }
@@ -294,6 +1588,196 @@ func TestMapSetGet(t *testing.T) {
 
 }
 
+func TestMapApply(t *testing.T) {
+	incr := func(v interface{}, found bool) interface{} {
+		if !found {
+			return 1
+		}
+		return v.(int) + 1
+	}
+	m := NewMap()
+	keys := []interface{}{
+		"a",
+		42,
+		New(map[string]interface{}{"a": 1}),
+		dumbHashable{dumb: "hashable1"},
+		dumbHashable{dumb: "hashable2"},
+	}
+	for i := 1; i <= 3; i++ {
+		for _, k := range keys {
+			m.Apply(k, incr)
+		}
+		if m.Len() != len(keys) {
+			t.Fatalf("expected length %d, got %d", len(keys), m.Len())
+		}
+		for _, k := range keys {
+			if v, ok := m.Get(k); !ok || v != i {
+				t.Errorf("expected %d for key %v, got %v (%t)", i, k, v, ok)
+			}
+		}
+	}
+
+	var calls int
+	m.Apply(nil, func(v interface{}, found bool) interface{} {
+		calls++
+		return nil
+	})
+	if calls != 0 || m.Len() != len(keys) {
+		t.Errorf("expected Apply with a nil key to do nothing, got %d calls and %v", calls, m)
+	}
+
+	m.Apply(dumbHashable{dumb: "hashable2"}, func(v interface{}, found bool) interface{} {
+		if !found || v != 3 {
+			t.Errorf("expected 3 (true), got %v (%t)", v, found)
+		}
+		return nil
+	})
+	if v, ok := m.Get(dumbHashable{dumb: "hashable2"}); !ok || v != nil {
+		t.Errorf("expected nil value, got %v (%t)", v, ok)
+	}
+	if v, ok := m.Get(dumbHashable{dumb: "hashable1"}); !ok || v != 3 {
+		t.Errorf("expected 3, got %v (%t)", v, ok)
+	}
+}
+
+func TestMapGetMulti(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		"nil", nil,
+		New(map[string]interface{}{"a": 1}), 2,
+		dumbHashable{dumb: 1}, 3,
+	)
+	values, found := m.GetMulti(
+		"a",
+		"b",
+		dumbHashable{dumb: 1},
+		"nil",
+		New(map[string]interface{}{"a": 2}),
+		New(map[string]interface{}{"a": 1}),
+		dumbHashable{dumb: 2},
+		"a",
+	)
+	expectedValues := []interface{}{1, nil, 3, nil, nil, 2, nil, 1}
+	expectedFound := []bool{true, false, true, true, false, true, false, true}
+	if len(values) != len(expectedValues) || len(found) != len(expectedFound) {
+		t.Fatalf("expected %d values, got %v and %v", len(expectedValues), values, found)
+	}
+	for i := range values {
+		if values[i] != expectedValues[i] || found[i] != expectedFound[i] {
+			t.Errorf("key %d: expected %v (%t), got %v (%t)",
+				i, expectedValues[i], expectedFound[i], values[i], found[i])
+		}
+	}
+
+	values, found = m.GetMulti()
+	if len(values) != 0 || len(found) != 0 {
+		t.Errorf("expected no values, got %v and %v", values, found)
+	}
+	var nilMap *Map
+	values, found = nilMap.GetMulti("a", dumbHashable{dumb: 1})
+	if len(values) != 2 || values[0] != nil || values[1] != nil || found[0] || found[1] {
+		t.Errorf("expected no values found in nil map, got %v and %v", values, found)
+	}
+}
+
+func TestMapSetAll(t *testing.T) {
+	m := NewMap()
+	if err := m.SetAll("a", 1, "b"); err == nil {
+		t.Error("expected error for odd number of arguments")
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected map to be untouched on error, got %v", m)
+	}
+	err := m.SetAll(
+		"a", 1,
+		"b", 2,
+		New(map[string]interface{}{"a": 1}), 3,
+		dumbHashable{dumb: "hashable1"}, 4,
+		"a", 5,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := NewMap(
+		"a", 5,
+		"b", 2,
+		New(map[string]interface{}{"a": 1}), 3,
+		dumbHashable{dumb: "hashable1"}, 4,
+	)
+	if !m.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+}
+
+func TestMapTypedGet(t *testing.T) {
+	m := NewMap(
+		"bool", true,
+		"string", "foo",
+		"int", 1,
+		"int8", int8(-2),
+		"int64", int64(math.MinInt64),
+		"uint32", uint32(math.MaxUint32),
+		"uint64", uint64(math.MaxInt64),
+		"big", uint64(math.MaxUint64),
+		"float", 1.0,
+		"nil", nil,
+		New(map[string]interface{}{"a": 1}), "bar",
+	)
+
+	for _, tc := range []struct {
+		k        interface{}
+		expected bool
+		ok       bool
+	}{
+		{k: "bool", expected: true, ok: true},
+		{k: "string", expected: false, ok: false},
+		{k: "nil", expected: false, ok: false},
+		{k: "missing", expected: false, ok: false},
+	} {
+		if b, ok := m.GetBool(tc.k); b != tc.expected || ok != tc.ok {
+			t.Errorf("GetBool(%v): expected (%t, %t), got (%t, %t)",
+				tc.k, tc.expected, tc.ok, b, ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		k        interface{}
+		expected string
+		ok       bool
+	}{
+		{k: "string", expected: "foo", ok: true},
+		{k: New(map[string]interface{}{"a": 1}), expected: "bar", ok: true},
+		{k: "bool", expected: "", ok: false},
+		{k: "missing", expected: "", ok: false},
+	} {
+		if s, ok := m.GetString(tc.k); s != tc.expected || ok != tc.ok {
+			t.Errorf("GetString(%v): expected (%q, %t), got (%q, %t)",
+				tc.k, tc.expected, tc.ok, s, ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		k        interface{}
+		expected int64
+		ok       bool
+	}{
+		{k: "int", expected: 1, ok: true},
+		{k: "int8", expected: -2, ok: true},
+		{k: "int64", expected: math.MinInt64, ok: true},
+		{k: "uint32", expected: math.MaxUint32, ok: true},
+		{k: "uint64", expected: math.MaxInt64, ok: true},
+		{k: "big", expected: 0, ok: false},
+		{k: "float", expected: 0, ok: false},
+		{k: "string", expected: 0, ok: false},
+		{k: "missing", expected: 0, ok: false},
+	} {
+		if i, ok := m.GetInt64(tc.k); i != tc.expected || ok != tc.ok {
+			t.Errorf("GetInt64(%v): expected (%d, %t), got (%d, %t)",
+				tc.k, tc.expected, tc.ok, i, ok)
+		}
+	}
+}
+
 func TestMapDel(t *testing.T) {
 	tests := []struct {
 		m   *Map
@@ -326,6 +1810,146 @@ func TestMapDel(t *testing.T) {
 	}
 }
 
+func TestMapDeleteFunc(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		"b", 2,
+		"c", 3,
+		New(map[string]interface{}{"a": 1}), 4,
+		New(map[string]interface{}{"b": 2}), 5,
+		dumbHashable{dumb: 1}, 6,
+		dumbHashable{dumb: 2}, 7,
+		dumbHashable{dumb: 3}, 8,
+		dumbHashable{dumb: 4}, 9,
+	)
+	odd := func(k, v interface{}) bool { return v.(int)%2 == 1 }
+	if n := m.DeleteFunc(odd); n != 5 {
+		t.Errorf("expected 5 entries removed, got %d", n)
+	}
+	expected := NewMap(
+		"b", 2,
+		New(map[string]interface{}{"a": 1}), 4,
+		dumbHashable{dumb: 1}, 6,
+		dumbHashable{dumb: 3}, 8,
+	)
+	if !m.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+	if len(m.custom) != 2 {
+		t.Errorf("expected 2 entry lists in custom map, got %d:\n%s", len(m.custom), m.debug())
+	}
+	// The remaining chained entries should still be reachable and removable.
+	m.Del(dumbHashable{dumb: 3})
+	if _, ok := m.Get(dumbHashable{dumb: 1}); !ok || m.Len() != 3 {
+		t.Errorf("unexpected map after Del: %v", m)
+	}
+
+	if n := m.DeleteFunc(func(k, v interface{}) bool { return false }); n != 0 {
+		t.Errorf("expected no entries removed, got %d", n)
+	}
+	if n := m.DeleteFunc(func(k, v interface{}) bool { return true }); n != 3 {
+		t.Errorf("expected 3 entries removed, got %d", n)
+	}
+	if m.Len() != 0 || len(m.normal) != 0 || len(m.custom) != 0 {
+		t.Errorf("expected empty map, got %v", m)
+	}
+
+	var nilMap *Map
+	if n := nilMap.DeleteFunc(odd); n != 0 {
+		t.Errorf("expected no entries removed from nil map, got %d", n)
+	}
+}
+
+func TestMapCopyInto(t *testing.T) {
+	src := NewMap(
+		"a", 1,
+		"b", NewMap("c", 2),
+		New(map[string]interface{}{"a": 1}), 3,
+		dumbHashable{dumb: 1}, 4,
+		dumbHashable{dumb: 2}, 5,
+	)
+	dst := NewMap()
+	src.CopyInto(dst)
+	if !dst.Equal(src) {
+		t.Errorf("expected %v, got %v", src, dst)
+	}
+
+	dst = NewMap("a", 0, "z", 26, dumbHashable{dumb: 2}, 0, dumbHashable{dumb: 3}, 6)
+	src.CopyInto(dst)
+	expected := NewMap(
+		"a", 1,
+		"b", NewMap("c", 2),
+		"z", 26,
+		New(map[string]interface{}{"a": 1}), 3,
+		dumbHashable{dumb: 1}, 4,
+		dumbHashable{dumb: 2}, 5,
+		dumbHashable{dumb: 3}, 6,
+	)
+	if !dst.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, dst)
+	}
+
+	dst.Clear()
+	if dst.Len() != 0 {
+		t.Errorf("expected empty map after Clear, got %v", dst)
+	}
+	src.CopyInto(dst)
+	if !dst.Equal(src) {
+		t.Errorf("expected %v, got %v", src, dst)
+	}
+
+	var nilMap *Map
+	nilMap.Clear()
+	nilMap.CopyInto(dst)
+	if !dst.Equal(src) {
+		t.Errorf("expected %v, got %v", src, dst)
+	}
+}
+
+func TestMapResize(t *testing.T) {
+	m := NewMap()
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+		m.Set(New(map[string]interface{}{"i": i}), i)
+	}
+	m.Set(dumbHashable{dumb: 1}, "a")
+	m.Set(dumbHashable{dumb: 2}, "b")
+	m.DeleteFunc(func(k, v interface{}) bool {
+		i, ok := v.(int)
+		return ok && i > 0
+	})
+	expected := NewMap(
+		0, 0,
+		New(map[string]interface{}{"i": 0}), 0,
+		dumbHashable{dumb: 1}, "a",
+		dumbHashable{dumb: 2}, "b",
+	)
+	m.Resize()
+	if !m.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+	if m.Len() != 4 {
+		t.Errorf("expected length 4, got %d", m.Len())
+	}
+	if v, ok := m.Get(dumbHashable{dumb: 2}); !ok || v != "b" {
+		t.Errorf("expected b, got %v (%t)", v, ok)
+	}
+
+	m.Clear()
+	m.Resize()
+	if m.Len() != 0 {
+		t.Errorf("expected empty map, got %v", m)
+	}
+	m.Set("a", 1)
+	m.Set(dumbHashable{dumb: 1}, 2)
+	if !m.Equal(NewMap("a", 1, dumbHashable{dumb: 1}, 2)) {
+		t.Errorf("unexpected map after Resize: %v", m)
+	}
+
+	var nilMap *Map
+	nilMap.Resize()
+}
+
 func contains(elementlist []interface{}, element interface{}) bool {
 	equal := func(v interface{}) bool { return element == v }
 	if comp, ok := element.(Comparable); ok {
@@ -394,6 +2018,203 @@ func TestMapIter(t *testing.T) {
 	}
 }
 
+func TestMapIterIndexed(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		"b", 2,
+		New(map[string]interface{}{"c": 3}), 3,
+		dumbHashable{dumb: 4}, 4,
+		dumbHashable{dumb: 5}, 5,
+	)
+	var keys []interface{}
+	err := m.IterIndexed(func(i int, k, v interface{}) error {
+		if i != len(keys) {
+			t.Errorf("expected index %d, got %d", len(keys), i)
+		}
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != m.Len() {
+		t.Errorf("expected %d entries, got %d", m.Len(), len(keys))
+	}
+
+	stop := errors.New("stop")
+	var last int
+	err = m.IterIndexed(func(i int, k, v interface{}) error {
+		last = i
+		if i == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || last != 2 {
+		t.Errorf("expected iteration to stop at index 2 with %v, got %d with %v",
+			stop, last, err)
+	}
+
+	var nilMap *Map
+	if err := nilMap.IterIndexed(func(i int, k, v interface{}) error {
+		return fmt.Errorf("unexpected entry %d", i)
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapCount(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		"b", nil,
+		"c", 3,
+		New(map[string]interface{}{"a": 1}), nil,
+		dumbHashable{dumb: "hashable1"}, 4,
+		dumbHashable{dumb: "hashable2"}, nil,
+	)
+	for name, tc := range map[string]struct {
+		pred     func(k, v interface{}) bool
+		expected int
+	}{
+		"nil predicate": {
+			pred:     nil,
+			expected: 6,
+		},
+		"non-nil values": {
+			pred:     func(k, v interface{}) bool { return v != nil },
+			expected: 3,
+		},
+		"string keys": {
+			pred: func(k, v interface{}) bool {
+				_, ok := k.(string)
+				return ok
+			},
+			expected: 3,
+		},
+		"custom keys": {
+			pred: func(k, v interface{}) bool {
+				_, ok := k.(Hashable)
+				return ok
+			},
+			expected: 3,
+		},
+		"none": {
+			pred:     func(k, v interface{}) bool { return false },
+			expected: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := m.Count(tc.pred); got != tc.expected {
+				t.Errorf("expected count %d, got %d", tc.expected, got)
+			}
+		})
+	}
+	var nilMap *Map
+	if got := nilMap.Count(nil); got != 0 {
+		t.Errorf("expected count of nil map to be 0, got %d", got)
+	}
+}
+
+func TestMapWriteNDJSON(t *testing.T) {
+	m := NewMap(
+		"a", 1,
+		int64(2), []interface{}{"b", true},
+		New(map[string]interface{}{"c": "d"}), map[string]interface{}{"e": nil},
+		New([]byte("f")), "g",
+	)
+	var b strings.Builder
+	if err := m.WriteNDJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != m.Len() {
+		t.Fatalf("expected %d lines, got %d: %q", m.Len(), len(lines), b.String())
+	}
+	for _, expected := range []string{
+		`{"key":"a","value":1}`,
+		`{"key":2,"value":["b",true]}`,
+		`{"key":{"c":"d"},"value":{"e":null}}`,
+		`{"key":"Zg==","value":"g"}`,
+	} {
+		found := false
+		for _, line := range lines {
+			if line == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected line %s in %q", expected, b.String())
+		}
+	}
+
+	m.Set("invalid", func() {})
+	m.Set(math.NaN(), "invalid")
+	b.Reset()
+	if err := m.WriteNDJSON(&b); err == nil {
+		t.Error("expected an error writing entries that can't be encoded")
+	}
+	b.Reset()
+	if err := m.WriteNDJSON(&b, SkipInvalidEntries()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(b.String(), "\n"); n != m.Len()-2 {
+		t.Errorf("expected %d lines, got %d: %q", m.Len()-2, n, b.String())
+	}
+
+	var nilMap *Map
+	b.Reset()
+	if err := nilMap.WriteNDJSON(&b); err != nil || b.Len() != 0 {
+		t.Errorf("expected no output for a nil map, got %q (%v)", b.String(), err)
+	}
+	if err := m.WriteNDJSON(failingWriter{}, SkipInvalidEntries()); err != errWrite {
+		t.Errorf("expected %v, got %v", errWrite, err)
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestMapIterOriginalKeys(t *testing.T) {
+	mapKey := New(map[string]interface{}{"a": 123, "b": []interface{}{"c"}})
+	pathKey := New(Path{New("foo"), New("bar")})
+	hashable := dumbHashable{dumb: "hashable1"}
+	m := NewMap(mapKey, 1, pathKey, 2, hashable, 3, "d", 4)
+
+	seen := make(map[interface{}]bool)
+	err := m.Iter(func(k, v interface{}) error {
+		var expected interface{}
+		switch v {
+		case 1:
+			expected = mapKey
+		case 2:
+			expected = pathKey
+		case 3:
+			expected = hashable
+		case 4:
+			expected = "d"
+		}
+		if !keyEqual(k, expected) {
+			return fmt.Errorf("expected key %#v for value %v, got %#v", expected, v, k)
+		}
+		if _, ok := k.(Key); ok && fmt.Sprintf("%T", k) != fmt.Sprintf("%T", expected) {
+			return fmt.Errorf("expected key of type %T, got %T", expected, k)
+		}
+		seen[v] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 4 {
+		t.Errorf("expected 4 entries, got %d", len(seen))
+	}
+}
+
 func TestMapString(t *testing.T) {
 	for _, tc := range []struct {
 		m *Map
@@ -460,6 +2281,97 @@ func BenchmarkMapGrow(b *testing.B) {
 	})
 }
 
+func BenchmarkMapEqualCustomKeys(b *testing.B) {
+	const n = 10000
+	// Both maps hold as many entries, but a has one hash per key
+	// whereas b has colliding keys, so the maps differ in their
+	// number of hashes.
+	a, other := NewMap(), NewMap()
+	for j := 0; j < n; j++ {
+		a.Set(New(map[string]interface{}{"j": j}), j)
+		if j%2 == 0 {
+			other.Set(dumbHashable{dumb: j}, j)
+		} else {
+			other.Set(New(map[string]interface{}{"j": j}), j)
+		}
+	}
+	// c differs from a only in its last value, so it has the same
+	// number of hashes and comparing them walks every entry.
+	c := NewMap()
+	a.CopyInto(c)
+	c.Set(New(map[string]interface{}{"j": n - 1}), -1)
+	b.Run("bucket count mismatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if a.Equal(other) {
+				b.Fatal("expected maps to differ")
+			}
+		}
+	})
+	b.Run("full comparison", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if a.Equal(c) {
+				b.Fatal("expected maps to differ")
+			}
+		}
+	})
+}
+
+func BenchmarkMapEqualStream(b *testing.B) {
+	const n = 10000
+	a, c := NewMap(), NewMap()
+	for j := 0; j < n; j++ {
+		a.Set(int64(j), j)
+		c.Set(int64(j), j)
+		a.Set(dumbHashable{dumb: j % 100}, j)
+		c.Set(dumbHashable{dumb: j % 100}, j)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { a.EqualStream(c) }); allocs != 0 {
+		b.Fatalf("expected no scratch allocations, got %v", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !a.EqualStream(c) {
+			b.Fatal("expected maps to be equal")
+		}
+	}
+}
+
+func BenchmarkMapEqualCount(b *testing.B) {
+	const n = 10000
+	a, c := NewMap(), NewMap()
+	for j := 0; j < n; j++ {
+		a.Set(New(map[string]interface{}{"j": j}), j)
+		c.Set(New(map[string]interface{}{"j": j}), j)
+	}
+	c.Set(New(map[string]interface{}{"j": n - 1}), -1)
+	// other has one more entry than a, so the length precheck fires.
+	other := NewMap()
+	c.CopyInto(other)
+	other.Set("extra", true)
+	b.Run("length mismatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if equal, compared := a.EqualCount(other); equal || compared != 0 {
+				b.Fatalf("expected no entry to be compared, got %t, %d", equal, compared)
+			}
+		}
+	})
+	b.Run("full comparison", func(b *testing.B) {
+		b.ReportAllocs()
+		var compared int
+		for i := 0; i < b.N; i++ {
+			var equal bool
+			if equal, compared = a.EqualCount(c); equal {
+				b.Fatal("expected maps to differ")
+			}
+		}
+		b.ReportMetric(float64(compared), "compared/op")
+	})
+}
+
 func BenchmarkMapGet(b *testing.B) {
 	keys := make([]Key, 150)
 	for j := 0; j < len(keys); j++ {
@@ -506,3 +2418,43 @@ func BenchmarkMapGet(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkMapResize(b *testing.B) {
+	const n = 100000
+	heapInUse := func() uint64 {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		return ms.HeapInuse
+	}
+	load := func() *Map {
+		m := NewMap()
+		for j := 0; j < n; j++ {
+			m.Set(j, j)
+		}
+		m.DeleteFunc(func(k, v interface{}) bool { return v.(int) >= 10 })
+		return m
+	}
+	for _, resize := range []bool{false, true} {
+		b.Run(fmt.Sprintf("resize=%t", resize), func(b *testing.B) {
+			b.ReportAllocs()
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				before := heapInUse()
+				b.StartTimer()
+				m := load()
+				if resize {
+					m.Resize()
+				}
+				b.StopTimer()
+				if after := heapInUse(); after > before {
+					retained += after - before
+				}
+				runtime.KeepAlive(m)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
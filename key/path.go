@@ -5,8 +5,11 @@
 package key
 
 import (
+	"encoding/binary"
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Path represents a path decomposed into elements where each
@@ -33,6 +36,47 @@ func (p Path) String() string {
 	return b.String()
 }
 
+// QuotedString returns the Path as an absolute path string like
+// String does, except that elements that are empty or contain a
+// space, a '/', a '=', a '"', a '\\' or non-printable characters are
+// quoted and escaped as Go string literals, as in `/a/"b c"/"d/e"`.
+// Unlike the result of String, the result of QuotedString can be
+// parsed back unambiguously, with path.FromQuotedString.
+func (p Path) QuotedString() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, element := range p {
+		b.WriteByte('/')
+		s, err := StringifyInterface(element.Key())
+		if err != nil {
+			panic(fmt.Errorf("unable to stringify %#v: %s", element, err))
+		}
+		if needsQuoting(s) {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		switch r {
+		case ' ', '/', '=', '"', '\\':
+			return true
+		}
+		if !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
 // MarshalJSON marshals a Path to JSON.
 func (p Path) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`{"_path":%q}`, p)), nil
@@ -44,6 +88,18 @@ func (p Path) Equal(other interface{}) bool {
 	return ok && pathEqual(p, o)
 }
 
+// Hash returns a hash of the Path, which makes a Path Hashable, so
+// that it can be used as a key in a Map. The hash combines the hashes
+// of the elements in order, so that paths holding the same elements
+// in a different order hash differently.
+func (p Path) Hash() uint64 {
+	h := uint64(31 * (len(p) + 1))
+	for _, element := range p {
+		h = h*31 + uint64(hashInterface(element))
+	}
+	return h
+}
+
 func pathEqual(a, b Path) bool {
 	if len(a) != len(b) {
 		return false
@@ -55,3 +111,85 @@ func pathEqual(a, b Path) bool {
 	}
 	return true
 }
+
+// OriginPath pairs a Path with the origin it belongs to, such as
+// the origin field of a gNMI path, which disambiguates between
+// schemas that may share the same element names.
+type OriginPath struct {
+	Origin string
+	Path   Path
+}
+
+// String returns the OriginPath as an absolute path string prefixed
+// with its origin, as in "origin:/a/b". If the origin is empty, only
+// the path is returned.
+func (p OriginPath) String() string {
+	if p.Origin == "" {
+		return p.Path.String()
+	}
+	return p.Origin + ":" + p.Path.String()
+}
+
+// Equal returns whether an OriginPath is equal to @other. Two
+// OriginPaths are equal when both their origins and paths are.
+func (p OriginPath) Equal(other interface{}) bool {
+	o, ok := other.(OriginPath)
+	return ok && p.Origin == o.Origin && pathEqual(p.Path, o.Path)
+}
+
+// EncodePaths encodes a slice of paths into a compact binary form.
+// Each path is encoded as the length of the prefix it shares with
+// its predecessor followed by its remaining elements, so that paths
+// sharing long prefixes, such as a sorted slice of paths, are
+// encoded efficiently. The original slice can be reconstructed with
+// DecodePaths. EncodePaths panics if an element can't be encoded,
+// which is the case for elements wrapping a value.Value.
+func EncodePaths(paths []Path) []byte {
+	b := appendUvarint(nil, uint64(len(paths)))
+	var prev Path
+	for _, p := range paths {
+		n := 0
+		for n < len(p) && n < len(prev) && p[n].Equal(prev[n]) {
+			n++
+		}
+		b = appendUvarint(b, uint64(n))
+		var err error
+		if b, err = appendPath(b, p[n:]); err != nil {
+			panic(fmt.Errorf("unable to encode path %s: %s", p, err))
+		}
+		prev = p
+	}
+	return b
+}
+
+// DecodePaths decodes a slice of paths encoded by EncodePaths.
+func DecodePaths(b []byte) ([]Path, error) {
+	n, b, err := decodeLength(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode paths: %s", err)
+	}
+	paths := make([]Path, n)
+	var prev Path
+	for i := range paths {
+		shared, l := binary.Uvarint(b)
+		if l <= 0 {
+			return nil, fmt.Errorf("unable to decode path %d: %s", i, errTruncated)
+		}
+		if shared > uint64(len(prev)) {
+			return nil, fmt.Errorf("unable to decode path %d: shared prefix length %d "+
+				"exceeds length %d of previous path", i, shared, len(prev))
+		}
+		var suffix Path
+		if suffix, b, err = decodePath(b[l:]); err != nil {
+			return nil, fmt.Errorf("unable to decode path %d: %s", i, err)
+		}
+		p := make(Path, int(shared)+len(suffix))
+		copy(p, prev[:shared])
+		copy(p[shared:], suffix)
+		paths[i], prev = p, p
+	}
+	if len(b) != 0 {
+		return nil, fmt.Errorf("unable to decode paths: %d trailing bytes", len(b))
+	}
+	return paths, nil
+}
@@ -138,6 +138,37 @@ func TestPathAsKey(t *testing.T) {
 	}
 }
 
+func TestPathAsMapKey(t *testing.T) {
+	a := key.Path{key.New("foo"), key.New(uint32(1)), key.New("bar")}
+	b := path.New("foo", uint32(1), "bar")
+	if a.Hash() != b.Hash() {
+		t.Errorf("expected %s and %s to have the same hash", a, b)
+	}
+	reversed := path.New("bar", uint32(1), "foo")
+	if a.Hash() == reversed.Hash() {
+		t.Errorf("expected %s and %s to have different hashes", a, reversed)
+	}
+
+	m := key.NewMap(a, 1, reversed, 2, key.Path{}, 3)
+	m.Set(b, 4)
+	if m.Len() != 3 {
+		t.Errorf("expected paths with equal contents to be the same key, got %s", m)
+	}
+	if v, ok := m.Get(path.New("foo", uint32(1), "bar")); !ok || v != 4 {
+		t.Errorf("expected 4, got %v (%t)", v, ok)
+	}
+	if v, ok := m.Get(key.Path(nil)); !ok || v != 3 {
+		t.Errorf("expected 3 for the empty path, got %v (%t)", v, ok)
+	}
+	if _, ok := m.Get(key.New(a)); ok {
+		t.Errorf("expected a Path and a key wrapping it to be different keys")
+	}
+	m.Del(b)
+	if m.Contains(a) || m.Len() != 2 {
+		t.Errorf("expected %s to be deleted, got %s", a, m)
+	}
+}
+
 func TestInvalidUTF8(t *testing.T) {
 	bytesAsString := string([]byte{0xFF, 0xFF, 0xFF, 0xFF})
 	if utf8.ValidString(bytesAsString) {
@@ -149,3 +180,193 @@ func TestInvalidUTF8(t *testing.T) {
 		t.Errorf("expected %q to be valid utf8", pathString)
 	}
 }
+
+func TestPathQuotedString(t *testing.T) {
+	tests := []struct {
+		p key.Path
+		s string
+	}{{
+		p: key.Path{},
+		s: "/",
+	}, {
+		p: path.New("foo", "bar"),
+		s: "/foo/bar",
+	}, {
+		p: path.New(""),
+		s: `/""`,
+	}, {
+		p: path.New("foo bar", "a/b", "c=d"),
+		s: `/"foo bar"/"a/b"/"c=d"`,
+	}, {
+		p: path.New(`say "hi"`, `back\slash`),
+		s: `/"say \"hi\""/"back\\slash"`,
+	}, {
+		p: path.New("tab\tnewline\n", "héllo"),
+		s: `/"tab\tnewline\n"/héllo`,
+	}, {
+		p: path.New(uint32(1), key.New(path.New("a", "b"))),
+		s: `/1/"[/a/b]"`,
+	}}
+	for i, tcase := range tests {
+		if s := tcase.p.QuotedString(); s != tcase.s {
+			t.Errorf("Test %d failed: expected %q, got %q", i, tcase.s, s)
+		}
+	}
+
+	paths := []key.Path{
+		key.Path{},
+		path.New(""),
+		path.New("", ""),
+		path.New("foo", "", "bar"),
+		path.New(`"`, `""`, `\"`, "/", "//"),
+		path.New(" leading", "trailing ", "in side"),
+		path.New(`"quoted"`, "a=b", "\x00"),
+	}
+	for _, p := range paths {
+		s := p.QuotedString()
+		out, err := path.FromQuotedString(s)
+		if err != nil {
+			t.Errorf("unable to parse %q: %s", s, err)
+		} else if !path.Equal(out, p) {
+			t.Errorf("round trip of %#v via %q failed: got %#v", p, s, out)
+		}
+	}
+}
+
+func TestOriginPath(t *testing.T) {
+	for i, tc := range []struct {
+		a      key.OriginPath
+		b      interface{}
+		result bool
+	}{{
+		a:      key.OriginPath{},
+		b:      key.OriginPath{},
+		result: true,
+	}, {
+		a:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		b:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		result: true,
+	}, {
+		a:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		b:      key.OriginPath{Origin: "eos_native", Path: path.New("a", "b")},
+		result: false,
+	}, {
+		a:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		b:      key.OriginPath{Path: path.New("a", "b")},
+		result: false,
+	}, {
+		a:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		b:      key.OriginPath{Origin: "openconfig", Path: path.New("a", "c")},
+		result: false,
+	}, {
+		a:      key.OriginPath{Path: path.New("a", "b")},
+		b:      path.New("a", "b"),
+		result: false,
+	}} {
+		if result := tc.a.Equal(tc.b); result != tc.result {
+			t.Errorf("test case %d: expected Equal to return %t, got %t", i, tc.result, result)
+		}
+	}
+
+	for _, tc := range []struct {
+		p        key.OriginPath
+		expected string
+	}{{
+		p:        key.OriginPath{},
+		expected: "/",
+	}, {
+		p:        key.OriginPath{Path: path.New("a", "b")},
+		expected: "/a/b",
+	}, {
+		p:        key.OriginPath{Origin: "openconfig", Path: path.New("a", "b")},
+		expected: "openconfig:/a/b",
+	}} {
+		if s := tc.p.String(); s != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, s)
+		}
+	}
+}
+
+func TestEncodeDecodePaths(t *testing.T) {
+	for name, paths := range map[string][]key.Path{
+		"nil":   nil,
+		"empty": []key.Path{},
+		"empty paths": []key.Path{
+			path.New(),
+			path.New(),
+		},
+		"shared prefixes": []key.Path{
+			path.New("interfaces", "interface", "Ethernet1", "state", "counters"),
+			path.New("interfaces", "interface", "Ethernet1", "state", "counters", "in"),
+			path.New("interfaces", "interface", "Ethernet1", "state", "counters", "out"),
+			path.New("interfaces", "interface", "Ethernet2"),
+			path.New("interfaces", "interface", "Ethernet2", "state"),
+			path.New("interfaces"),
+			path.New("system"),
+		},
+		"element types": []key.Path{
+			path.New("foo", []byte("foo"), true, nil),
+			path.New("foo", int8(-1), int16(-2), int32(-3), int64(-4)),
+			path.New("foo", uint8(1), uint16(2), uint32(3), uint64(4)),
+			path.New("foo", float32(1.5), float64(-2.5)),
+			path.New("foo", map[string]interface{}{"a": 1, "b": []interface{}{"c", uint(2)}}),
+			path.New("foo", []interface{}{"a", nil, false}),
+			path.New("foo", path.New("bar", "baz")),
+			path.New("foo", key.NewPointer(path.New("bar", "baz"))),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := key.EncodePaths(paths)
+			decoded, err := key.DecodePaths(b)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(decoded) != len(paths) {
+				t.Fatalf("expected %d paths, got %d", len(paths), len(decoded))
+			}
+			for i := range paths {
+				if !path.Equal(paths[i], decoded[i]) {
+					t.Errorf("path %d: expected %#v, got %#v", i, paths[i], decoded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncodePathsSharesPrefixes(t *testing.T) {
+	base := path.New("interfaces", "interface", "Ethernet1", "state", "counters")
+	var paths []key.Path
+	for _, counter := range []string{"in-octets", "in-pkts", "out-octets", "out-pkts"} {
+		paths = append(paths, path.Append(base, counter))
+	}
+	shared := len(key.EncodePaths(paths))
+	var separate int
+	for _, p := range paths {
+		separate += len(key.EncodePaths([]key.Path{p}))
+	}
+	if shared >= separate/2 {
+		t.Errorf("expected shared prefixes to be encoded once: "+
+			"%d bytes for all paths, %d bytes for each path separately", shared, separate)
+	}
+}
+
+func TestDecodePathsErrors(t *testing.T) {
+	valid := key.EncodePaths([]key.Path{path.New("foo"), path.New("foo", "bar")})
+	for name, b := range map[string][]byte{
+		"empty":              []byte{},
+		"truncated":          valid[:len(valid)-1],
+		"trailing bytes":     append(append([]byte{}, valid...), 0),
+		"shared prefix":      []byte{1, 1, 0},
+		"unknown type tag":   []byte{1, 0, 1, 0xff},
+		"invalid key type":   []byte{1, 0, 1, 4, 2},
+		"nested key":         []byte{1, 0, 1, 20, 20, 1, 1, 'x'},
+		"nested key map":     []byte{1, 0, 1, 20, 21, 0},
+		"implausible length": []byte{1, 0, 100},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if paths, err := key.DecodePaths(b); err == nil {
+				t.Errorf("expected error, got %#v", paths)
+			}
+		})
+	}
+}
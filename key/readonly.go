@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+// ReadonlyMap is a read-only view of a Map, which lacks the methods
+// of Map that modify it, such that code holding a ReadonlyMap can't
+// modify the Map by accident.
+type ReadonlyMap interface {
+	// Get returns the value of the entry with key k, and whether
+	// that entry exists, as Map.Get does.
+	Get(k interface{}) (interface{}, bool)
+	// Len returns the number of entries of the Map.
+	Len() int
+	// Iter calls f for each entry of the Map, as Map.Iter does.
+	Iter(f func(k, v interface{}) error) error
+	// Contains returns whether the Map has an entry with key k.
+	Contains(k interface{}) bool
+	// Keys returns the keys of the entries of the Map, in no
+	// particular order.
+	Keys() []interface{}
+	// Values returns the values of the entries of the Map, in no
+	// particular order.
+	Values() []interface{}
+	// Equal compares the Map to other, which may be a Map or a
+	// ReadonlyMap, as Map.Equal does.
+	Equal(other interface{}) bool
+}
+
+// readonlyMap implements ReadonlyMap. It wraps the Map rather than
+// being one, so that the Map can't be recovered with a type assertion.
+type readonlyMap struct {
+	m *Map
+}
+
+// Readonly returns a read-only view of the Map, to hand the Map to
+// code that must not modify it without copying it. The view shares
+// the entries of the Map, so changes to the Map are seen through the
+// view, and the Map must not be modified while the view is in use,
+// unless the changes are meant to be seen and synchronized with the
+// users of the view. Values themselves are shared, so values such as
+// *Map values can still be modified through the view. Use Snapshot to
+// hand out a view of the entries that the Map currently holds.
+func (m *Map) Readonly() ReadonlyMap {
+	return readonlyMap{m: m}
+}
+
+func (r readonlyMap) Get(k interface{}) (interface{}, bool) {
+	return r.m.Get(k)
+}
+
+func (r readonlyMap) Len() int {
+	return r.m.Len()
+}
+
+func (r readonlyMap) Iter(f func(k, v interface{}) error) error {
+	return r.m.Iter(f)
+}
+
+func (r readonlyMap) Contains(k interface{}) bool {
+	return r.m.Contains(k)
+}
+
+func (r readonlyMap) Keys() []interface{} {
+	return r.m.Keys()
+}
+
+func (r readonlyMap) Values() []interface{} {
+	return r.m.Values()
+}
+
+func (r readonlyMap) Equal(other interface{}) bool {
+	return r.m.Equal(other)
+}
+
+func (r readonlyMap) String() string {
+	return r.m.String()
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapReadonly(t *testing.T) {
+	m := NewMap("a", 1, New("b"), "c", dumbHashable{dumb: 1}, 2)
+	r := m.Readonly()
+	if r.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", r.Len())
+	}
+	if v, ok := r.Get("a"); !ok || v != 1 {
+		t.Errorf("expected 1 for \"a\", got %v (%t)", v, ok)
+	}
+	if !r.Contains(dumbHashable{dumb: 1}) || r.Contains("z") {
+		t.Errorf("unexpected Contains results for %v", r)
+	}
+	if len(r.Keys()) != 3 || len(r.Values()) != 3 {
+		t.Errorf("unexpected keys %v or values %v", r.Keys(), r.Values())
+	}
+	n := 0
+	if err := r.Iter(func(k, v interface{}) error {
+		n++
+		return nil
+	}); err != nil || n != 3 {
+		t.Errorf("expected 3 entries to be iterated over, got %d (%v)", n, err)
+	}
+	other := NewMap(dumbHashable{dumb: 1}, 2, New("b"), "c", "a", 1)
+	if !r.Equal(other) || !other.Equal(r) || !r.Equal(other.Readonly()) || !r.Equal(m) {
+		t.Errorf("expected %v to equal %v", r, other)
+	}
+	other.Set("a", 2)
+	if r.Equal(other) || other.Equal(r) || r.Equal(other.Readonly()) {
+		t.Errorf("expected %v not to equal %v", r, other)
+	}
+
+	// Changes to the Map are seen through the view.
+	m.Set("d", 3)
+	if v, ok := r.Get("d"); !ok || v != 3 || r.Len() != 4 {
+		t.Errorf("expected the view to see the changes of the Map, got %v", r)
+	}
+
+	// The view can't be turned back into a Map.
+	if _, ok := r.(*Map); ok {
+		t.Errorf("expected the view not to be a *Map")
+	}
+	mutators := []string{"Set", "SetAll", "Apply", "LoadOrCompute", "Del", "DeleteFunc",
+		"Clear", "Resize", "SetAccessHook", "UnmarshalJSON", "UnmarshalBinary"}
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf((*ReadonlyMap)(nil)).Elem(),
+		reflect.TypeOf(r),
+	} {
+		for _, name := range mutators {
+			if _, ok := typ.MethodByName(name); ok {
+				t.Errorf("expected %s to lack method %s", typ, name)
+			}
+		}
+	}
+	for _, name := range mutators {
+		if _, ok := reflect.TypeOf(m).MethodByName(name); !ok {
+			t.Errorf("expected *Map to have method %s", name)
+		}
+	}
+}
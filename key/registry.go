@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"reflect"
+	"sync"
+)
+
+var keyTypes struct {
+	sync.RWMutex
+	handlers map[reflect.Type]func(interface{}) Key
+}
+
+// RegisterKeyType teaches New how to wrap values of type t in a Key:
+// New passes such values to newKey and returns the Key it returns,
+// which typically wraps a representation of the value that New
+// handles natively, like a map[string]interface{}, or a Hashable.
+// This allows values of types that New would otherwise reject, such
+// as structs holding slices, to be used as keys without wrapping them
+// at every call site. newKey must not call New with a value of type t.
+// Registered types are only consulted for values of types that New
+// doesn't handle natively, so the handling of those can't be changed.
+// Registering a type again replaces its handler, and registering a
+// nil newKey unregisters the type. RegisterKeyType is safe for
+// concurrent use, but is typically called from init functions.
+func RegisterKeyType(t reflect.Type, newKey func(interface{}) Key) {
+	if t == nil {
+		panic("key: RegisterKeyType called with a nil type")
+	}
+	keyTypes.Lock()
+	defer keyTypes.Unlock()
+	if newKey == nil {
+		delete(keyTypes.handlers, t)
+		return
+	}
+	if keyTypes.handlers == nil {
+		keyTypes.handlers = make(map[reflect.Type]func(interface{}) Key)
+	}
+	keyTypes.handlers[t] = newKey
+}
+
+// newRegisteredKey returns the Key returned by the handler registered
+// for the type of intf, if any.
+func newRegisteredKey(intf interface{}) (Key, bool) {
+	keyTypes.RLock()
+	newKey, ok := keyTypes.handlers[reflect.TypeOf(intf)]
+	keyTypes.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return newKey(intf), true
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/aristanetworks/goarista/key"
+)
+
+// route isn't comparable, since it holds a slice.
+type route struct {
+	prefix  string
+	nexthop []string
+}
+
+func newRouteKey(intf interface{}) Key {
+	r := intf.(route)
+	nexthop := make([]interface{}, len(r.nexthop))
+	for i, hop := range r.nexthop {
+		nexthop[i] = hop
+	}
+	return New(map[string]interface{}{"prefix": r.prefix, "nexthop": nexthop})
+}
+
+func TestRegisterKeyType(t *testing.T) {
+	r1 := route{prefix: "10.0.0.0/8", nexthop: []string{"a", "b"}}
+	r2 := route{prefix: "10.0.0.0/8", nexthop: []string{"a"}}
+	newKey := func(v interface{}) (k Key, err interface{}) {
+		defer func() { err = recover() }()
+		return New(v), nil
+	}
+	if _, err := newKey(r1); err == nil {
+		t.Fatal("expected New to panic for an unregistered type")
+	}
+
+	RegisterKeyType(reflect.TypeOf(route{}), newRouteKey)
+	defer RegisterKeyType(reflect.TypeOf(route{}), nil)
+
+	m := NewMap()
+	m.Set(New(r1), 1)
+	m.Set(New(r2), 2)
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %s", m)
+	}
+	// A distinct but equal route finds the same entry.
+	if v, ok := m.Get(New(route{prefix: "10.0.0.0/8", nexthop: []string{"a", "b"}})); !ok ||
+		v != 1 {
+		t.Errorf("expected 1, got %v (%t)", v, ok)
+	}
+	if v, ok := m.Get(New(route{prefix: "10.0.0.0/8"})); ok {
+		t.Errorf("unexpected value %v", v)
+	}
+	if !New(r1).Equal(newRouteKey(r1)) || New(r1).Equal(New(r2)) {
+		t.Error("unexpected result of Equal")
+	}
+	// Registered types don't change how natively handled types are wrapped.
+	if k := New("route"); k.Key() != "route" {
+		t.Errorf("unexpected key %#v", k)
+	}
+
+	RegisterKeyType(reflect.TypeOf(route{}), nil)
+	if _, err := newKey(r1); err == nil ||
+		!strings.Contains(err.(string), "Invalid type for key") {
+		t.Errorf("expected New to panic once the type is unregistered, got %v", err)
+	}
+}
@@ -48,6 +48,10 @@ func StringifyInterface(key interface{}) (string, error) {
 		str = "f" + strconv.FormatInt(int64(math.Float32bits(key)), 10)
 	case float64:
 		str = "f" + strconv.FormatInt(int64(math.Float64bits(key)), 10)
+	case complex64:
+		str = New(key).String()
+	case complex128:
+		str = New(key).String()
 	case string:
 		str = escape(key)
 	case map[string]interface{}:
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"time"
+)
+
+// TTLMap is a Map whose entries expire after a time-to-live. Expired
+// entries are treated as absent by Get, but are only removed from the
+// TTLMap by Expire, or by Get if the TTLMap was created with the
+// WithLazyExpiry option. A TTLMap is not safe for concurrent use.
+type TTLMap struct {
+	m    Map
+	now  func() time.Time
+	lazy bool
+}
+
+type ttlEntry struct {
+	v      interface{}
+	expiry time.Time
+}
+
+// TTLMapOption changes the behavior of a TTLMap.
+type TTLMapOption func(m *TTLMap)
+
+// WithClock makes a TTLMap use now to get the current time, instead
+// of time.Now. This is mostly useful in tests.
+func WithClock(now func() time.Time) TTLMapOption {
+	return func(m *TTLMap) {
+		m.now = now
+	}
+}
+
+// WithLazyExpiry makes the Get method of a TTLMap remove the expired
+// entry it finds, if any.
+func WithLazyExpiry() TTLMapOption {
+	return func(m *TTLMap) {
+		m.lazy = true
+	}
+}
+
+// NewTTLMap creates a new empty TTLMap.
+func NewTTLMap(opts ...TTLMapOption) *TTLMap {
+	m := &TTLMap{now: time.Now}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Set associates k with v until ttl has elapsed, replacing the value
+// and expiry time already associated with k, if any.
+func (m *TTLMap) Set(k, v interface{}, ttl time.Duration) {
+	m.SetExpiry(k, v, m.now().Add(ttl))
+}
+
+// SetExpiry associates k with v until the given expiry time, replacing
+// the value and expiry time already associated with k, if any.
+func (m *TTLMap) SetExpiry(k, v interface{}, expiry time.Time) {
+	m.m.Set(k, ttlEntry{v: v, expiry: expiry})
+}
+
+// Get returns the value associated with k, and true, if k is in the
+// TTLMap and its entry hasn't expired. An entry expires once the
+// current time is at or past its expiry time.
+func (m *TTLMap) Get(k interface{}) (interface{}, bool) {
+	e, ok := m.m.Get(k)
+	if !ok {
+		return nil, false
+	}
+	ent := e.(ttlEntry)
+	if !m.now().Before(ent.expiry) {
+		if m.lazy {
+			m.m.Del(k)
+		}
+		return nil, false
+	}
+	return ent.v, true
+}
+
+// Expiry returns the expiry time of the entry with key k, and true,
+// if k is in the TTLMap, whether its entry has expired or not.
+func (m *TTLMap) Expiry(k interface{}) (time.Time, bool) {
+	e, ok := m.m.Get(k)
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.(ttlEntry).expiry, true
+}
+
+// Del removes the entry with key k, whether it has expired or not.
+func (m *TTLMap) Del(k interface{}) {
+	m.m.Del(k)
+}
+
+// Len returns the number of entries in the TTLMap, including the
+// expired entries that haven't been removed yet.
+func (m *TTLMap) Len() int {
+	return m.m.Len()
+}
+
+// Expire removes the entries expired at time now, and returns the
+// number of entries removed.
+func (m *TTLMap) Expire(now time.Time) int {
+	return m.m.DeleteFunc(func(_, v interface{}) bool {
+		return !now.Before(v.(ttlEntry).expiry)
+	})
+}
+
+// Iter calls f for each entry of the TTLMap that hasn't expired, in no
+// particular order. If f returns an error, Iter stops and returns it.
+func (m *TTLMap) Iter(f func(k, v interface{}) error) error {
+	now := m.now()
+	return m.m.Iter(func(k, v interface{}) error {
+		ent := v.(ttlEntry)
+		if !now.Before(ent.expiry) {
+			return nil
+		}
+		return f(k, ent.v)
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package key
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only advances when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestTTLMap(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	m := NewTTLMap(WithClock(clock.now))
+	composite := New(map[string]interface{}{"a": int64(1)})
+	m.Set("short", 1, time.Second)
+	m.Set(composite, 2, time.Minute)
+	m.Set(dumbHashable{dumb: 1}, 3, time.Hour)
+
+	check := func(step string, expected *Map) {
+		t.Helper()
+		for _, k := range []interface{}{"short", composite, dumbHashable{dumb: 1}} {
+			v, ok := m.Get(k)
+			expectedV, expectedOk := expected.Get(k)
+			if ok != expectedOk || v != expectedV {
+				t.Errorf("%s: Get(%v): expected %v (%t), got %v (%t)",
+					step, k, expectedV, expectedOk, v, ok)
+			}
+		}
+		n := 0
+		_ = m.Iter(func(k, v interface{}) error {
+			n++
+			if expectedV, _ := expected.Get(k); expectedV != v {
+				t.Errorf("%s: Iter: unexpected entry %v: %v", step, k, v)
+			}
+			return nil
+		})
+		if n != expected.Len() {
+			t.Errorf("%s: Iter: expected %d entries, got %d", step, expected.Len(), n)
+		}
+	}
+
+	check("initial", NewMap("short", 1, composite, 2, dumbHashable{dumb: 1}, 3))
+	clock.advance(time.Second)
+	check("after 1s", NewMap(composite, 2, dumbHashable{dumb: 1}, 3))
+	if m.Len() != 3 {
+		t.Errorf("expected the expired entry to be kept until Expire, got length %d",
+			m.Len())
+	}
+	if expiry, ok := m.Expiry("short"); !ok || !expiry.Equal(time.Unix(1001, 0)) {
+		t.Errorf("unexpected expiry %s (%t)", expiry, ok)
+	}
+	clock.advance(time.Minute)
+	check("after 61s", NewMap(dumbHashable{dumb: 1}, 3))
+	if n := m.Expire(clock.now()); n != 2 {
+		t.Errorf("expected Expire to remove 2 entries, got %d", n)
+	}
+	if m.Len() != 1 {
+		t.Errorf("expected length 1, got %d", m.Len())
+	}
+	if n := m.Expire(clock.now()); n != 0 {
+		t.Errorf("expected Expire to remove no entries, got %d", n)
+	}
+
+	// Setting an entry again renews its expiry time.
+	m.Set(dumbHashable{dumb: 1}, 4, time.Second)
+	clock.advance(time.Hour)
+	check("after renewal", NewMap())
+	m.Del(dumbHashable{dumb: 1})
+	if m.Len() != 0 {
+		t.Errorf("expected length 0, got %d", m.Len())
+	}
+}
+
+func TestTTLMapLazyExpiry(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	lazy := NewTTLMap(WithClock(clock.now), WithLazyExpiry())
+	eager := NewTTLMap(WithClock(clock.now))
+	for _, m := range []*TTLMap{lazy, eager} {
+		m.Set("a", 1, time.Second)
+		m.Set("b", 2, time.Second)
+		m.SetExpiry("c", 3, clock.now().Add(time.Hour))
+	}
+	clock.advance(2 * time.Second)
+	for _, m := range []*TTLMap{lazy, eager} {
+		if v, ok := m.Get("a"); ok {
+			t.Errorf("expected a to have expired, got %v", v)
+		}
+		if v, ok := m.Get("c"); !ok || v != 3 {
+			t.Errorf("expected 3 for c, got %v (%t)", v, ok)
+		}
+	}
+	if lazy.Len() != 2 {
+		t.Errorf("expected Get to remove the expired entry, got length %d", lazy.Len())
+	}
+	if eager.Len() != 3 {
+		t.Errorf("expected Get not to remove the expired entry, got length %d", eager.Len())
+	}
+	if n := lazy.Expire(clock.now()); n != 1 {
+		t.Errorf("expected Expire to remove 1 entry, got %d", n)
+	}
+}
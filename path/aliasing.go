@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// aliasingChecks is 1 when aliasing checks are enabled.
+var aliasingChecks int32
+
+// SetAliasingChecks enables or disables aliasing checks, and returns
+// whether they were enabled. Some functions of this package, such as
+// Parent, return paths sharing backing with the paths they are given,
+// so that modifying the elements of one of them in place, or appending
+// to it, silently modifies the other. When aliasing checks are
+// enabled, these functions return copies instead, which makes their
+// results safe to modify at the expense of extra allocations. They are
+// meant to track aliasing bugs down in tests, along with
+// CheckMutation, and are disabled by default, unless the package is
+// built with the "pathdebug" build tag.
+func SetAliasingChecks(enabled bool) bool {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	return atomic.SwapInt32(&aliasingChecks, v) != 0
+}
+
+func aliasingChecksEnabled() bool {
+	return atomic.LoadInt32(&aliasingChecks) != 0
+}
+
+// unaliased returns path, or a copy of it if aliasing checks are
+// enabled. Functions returning a path sharing backing with a path
+// they are given return it through unaliased.
+func unaliased(path key.Path) key.Path {
+	if !aliasingChecksEnabled() {
+		return path
+	}
+	return Clone(path)
+}
+
+// PathError is returned by CheckMutation when a path was modified in
+// place.
+type PathError struct {
+	// Path is the path that was modified, as it was before.
+	Path key.Path
+	// Index is the index of the first element that was modified.
+	Index int
+	// Element is the element found at Index after the modification.
+	Element key.Key
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("path %s was modified in place: element %d changed from %s to %s",
+		e.Path, e.Index, e.Path[e.Index], e.Element)
+}
+
+// CheckMutation calls f with path, and returns a *PathError if the
+// elements of path were modified in place by the time f returns,
+// which happens when f modifies path or one of the paths sharing
+// backing with it, such as one returned by Parent(path). It returns
+// nil if path was left untouched.
+func CheckMutation(path key.Path, f func(path key.Path)) error {
+	before := Clone(path)
+	f(path)
+	for i, element := range path {
+		if !element.Equal(before[i]) {
+			return &PathError{Path: before, Index: i, Element: element}
+		}
+	}
+	return nil
+}
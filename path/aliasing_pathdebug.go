@@ -0,0 +1,12 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+//go:build pathdebug
+// +build pathdebug
+
+package path
+
+func init() {
+	SetAliasingChecks(true)
+}
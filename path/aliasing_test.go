@@ -0,0 +1,87 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestCheckMutation(t *testing.T) {
+	enabled := SetAliasingChecks(false)
+	defer SetAliasingChecks(enabled)
+
+	mutations := map[string]func(p key.Path){
+		"element": func(p key.Path) { p[1] = key.New("qux") },
+		"parent":  func(p key.Path) { Parent(p)[0] = key.New("qux") },
+		"append to parent": func(p key.Path) {
+			_ = append(Parent(p), key.New("qux"))
+		},
+		"append": func(p key.Path) { Append(p)[2] = key.New("qux") },
+		"trim wildcard prefix": func(p key.Path) {
+			TrimWildcardPrefix(p)[0] = key.New("qux")
+		},
+		"path diff": func(p key.Path) {
+			_, rest, _ := PathDiff(p, New("foo"))
+			rest[0] = key.New("qux")
+		},
+	}
+	expectedIndex := map[string]int{"element": 1, "parent": 0, "append to parent": 2,
+		"append": 2, "trim wildcard prefix": 0, "path diff": 1}
+	for name, mutate := range mutations {
+		p := New("foo", "bar", "baz")
+		err := CheckMutation(p, mutate)
+		pathErr, ok := err.(*PathError)
+		if !ok {
+			t.Errorf("%s: expected a *PathError, got %v", name, err)
+			continue
+		}
+		if pathErr.Index != expectedIndex[name] || !pathErr.Element.Equal(key.New("qux")) ||
+			!Equal(pathErr.Path, New("foo", "bar", "baz")) {
+			t.Errorf("%s: unexpected error %#v", name, pathErr)
+		}
+	}
+
+	// With aliasing checks enabled, the paths returned by these
+	// functions are copies that can be modified safely.
+	SetAliasingChecks(true)
+	for _, name := range []string{"parent", "append to parent", "append",
+		"trim wildcard prefix", "path diff"} {
+		if err := CheckMutation(New("foo", "bar", "baz"), mutations[name]); err != nil {
+			t.Errorf("%s: unexpected error with aliasing checks enabled: %s", name, err)
+		}
+	}
+	p := New("foo", "bar", "baz")
+	if err := CheckMutation(p, func(p key.Path) {
+		for _, ancestor := range Ancestors(p) {
+			for i := range ancestor {
+				ancestor[i] = key.New("qux")
+			}
+		}
+	}); err != nil {
+		t.Errorf("ancestors: unexpected error with aliasing checks enabled: %s", err)
+	}
+	if err := CheckMutation(p, mutations["element"]); err == nil {
+		t.Error("expected an error for a path modified directly")
+	}
+
+	expected := `path /foo/bar/baz was modified in place: element 1 changed from bar to qux`
+	err := CheckMutation(New("foo", "bar", "baz"), mutations["element"])
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+
+	// Elements of uncomparable types are compared with Equal.
+	p = New("interfaces", map[string]interface{}{"name": "eth0"})
+	if err := CheckMutation(p, func(key.Path) {}); err != nil {
+		t.Errorf("map element: unexpected error: %s", err)
+	}
+	if err := CheckMutation(p, func(p key.Path) {
+		p[1] = key.New(map[string]interface{}{"name": "eth1"})
+	}); err == nil {
+		t.Error("map element: expected an error for a path modified in place")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// canonicalWildcard is the representation of Wildcard in the strings
+// returned by CanonicalString. It can't be confused with the
+// representation of another element, which always holds a colon.
+const canonicalWildcard = "*"
+
+// CanonicalString returns a representation of path that identifies it,
+// such that it can be used as the key of a Go map: equal paths have
+// the same representation and paths that aren't equal have different
+// representations, unlike the representations returned by String,
+// where the string "1" and the number 1 look the same. Each element
+// is represented by its length followed by a colon and by
+// key.KeyString of the element, which preserves its type, or by "*"
+// for Wildcard. For instance, the path /a/*/1, whose last element is
+// an int64, is represented as `5:s:"a"1:*5:i64:1`. The empty path is
+// represented by the empty string.
+// Elements wrapping a value.Value other than Wildcard are represented
+// by their type and string representation, so such elements of
+// different types are told apart, but ParseCanonical can't rebuild
+// them and returns an error for their representation.
+func CanonicalString(path key.Path) string {
+	var b strings.Builder
+	for _, element := range path {
+		s := canonicalWildcard
+		if !element.Equal(Wildcard) {
+			s = key.KeyString(element)
+		}
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// ParseCanonical parses the representation of a path returned by
+// CanonicalString, and returns a path equal to the original path.
+func ParseCanonical(s string) (key.Path, error) {
+	path := key.Path{}
+	for i := 0; i < len(s); {
+		colon := strings.IndexByte(s[i:], ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid canonical path %q at offset %d: missing length",
+				s, i)
+		}
+		n, err := strconv.Atoi(s[i : i+colon])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid canonical path %q at offset %d: invalid length %q",
+				s, i, s[i:i+colon])
+		}
+		i += colon + 1
+		if n > len(s)-i {
+			return nil, fmt.Errorf("invalid canonical path %q at offset %d: "+
+				"element of length %d exceeds the path", s, i, n)
+		}
+		element := s[i : i+n]
+		i += n
+		if element == canonicalWildcard {
+			path = append(path, Wildcard)
+			continue
+		}
+		k, err := key.ParseKeyString(element)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canonical path %q: %s", s, err)
+		}
+		path = append(path, k)
+	}
+	return path, nil
+}
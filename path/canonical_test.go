@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"math"
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestCanonicalString(t *testing.T) {
+	paths := []key.Path{
+		nil,
+		New("a"),
+		New("1"),
+		New(int64(1)),
+		New(uint8(1)),
+		New(1.0),
+		New(float32(1)),
+		New(true),
+		New("true"),
+		New([]byte("a")),
+		New(nil),
+		New("a", "b"),
+		New("a/b"),
+		New(`1:*`),
+		New(`5:s:"a"`),
+		New("a", Wildcard),
+		New(Wildcard, "a"),
+		New("*"),
+		New(net.ParseIP("192.0.2.1")),
+		New(map[string]interface{}{"a": int64(1), "b": "c"}),
+		New(map[string]interface{}{"a": int64(1), "b": []interface{}{"c", uint32(2)}}),
+		New(map[string]interface{}{"a": "1"}),
+		New("a", map[string]interface{}{"path": New("b", int64(2))}),
+		New([]interface{}{"a", "b"}),
+		key.Path{key.New(New("a", "b"))},
+		New(key.NewPointer(New("a", "b"))),
+		New("x", int16(-3), map[string]interface{}{"k": true}, Wildcard, []byte{0, 255}),
+		New(complex64(1 + 2i)),
+		New(complex(1, 2)),
+		New(complex(1, -2)),
+		New("a", complex(math.Inf(1), math.NaN()), map[string]interface{}{"c": 1i}),
+	}
+	seen := map[string]key.Path{}
+	for i, p := range paths {
+		s := CanonicalString(p)
+		if other, ok := seen[s]; ok {
+			t.Errorf("Test %d failed: %s and %s have the same representation %q",
+				i, other, p, s)
+		}
+		seen[s] = p
+		if s2 := CanonicalString(Clone(p)); s2 != s {
+			t.Errorf("Test %d failed: expected %q for a clone, got %q", i, s, s2)
+		}
+		parsed, err := ParseCanonical(s)
+		if err != nil {
+			t.Errorf("Test %d failed: unexpected error parsing %q: %s", i, s, err)
+		} else if !Equal(parsed, p) {
+			t.Errorf("Test %d failed: expected %#v, got %#v", i, p, parsed)
+		}
+	}
+	if s := CanonicalString(New("a", Wildcard, int64(1))); s != `5:s:"a"1:*5:i64:1` {
+		t.Errorf("unexpected representation: %q", s)
+	}
+	// Keys of maps are sorted, whatever the order of insertion.
+	a := map[string]interface{}{}
+	b := map[string]interface{}{}
+	for i, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		a[k] = i
+		b[k] = i
+	}
+	if CanonicalString(New(a)) != CanonicalString(New(b)) {
+		t.Errorf("expected equal maps to have the same representation")
+	}
+}
+
+// customValue is a value.Value with the same string representation as
+// Wildcard.
+type customValue struct{}
+
+func (customValue) String() string               { return "*" }
+func (customValue) MarshalJSON() ([]byte, error) { return []byte(`"*"`), nil }
+func (customValue) ToBuiltin() interface{}       { return "*" }
+
+func TestCanonicalStringValues(t *testing.T) {
+	// Elements wrapping a value.Value are told apart by their type,
+	// but can't be parsed back.
+	paths := []key.Path{
+		New("*"),
+		New(Wildcard),
+		New(customValue{}),
+		New("a", map[string]interface{}{"v": customValue{}}),
+		New("a", map[string]interface{}{"v": WildcardType{}}),
+	}
+	seen := map[string]key.Path{}
+	for i, p := range paths {
+		s := CanonicalString(p)
+		if other, ok := seen[s]; ok {
+			t.Errorf("Test %d failed: %#v and %#v have the same representation %q",
+				i, other, p, s)
+		}
+		seen[s] = p
+		parsed, err := ParseCanonical(s)
+		if i < 2 {
+			if err != nil || !Equal(parsed, p) {
+				t.Errorf("Test %d failed: expected %#v, got %#v (%v)", i, p, parsed, err)
+			}
+		} else if err == nil {
+			t.Errorf("Test %d failed: expected an error parsing %q, got %#v", i, s, parsed)
+		}
+	}
+}
+
+func TestParseCanonicalErrors(t *testing.T) {
+	for i, s := range []string{
+		"a",
+		":s:\"a\"",
+		"x:s:\"a\"",
+		"-1:",
+		"10:s:\"a\"",
+		"5:s:\"a\"5",
+		"1:a",
+		"3:s:a",
+		"9:i64:1,i:2",
+		`11:v:"custom"`,
+	} {
+		if p, err := ParseCanonical(s); err == nil {
+			t.Errorf("Test %d failed: expected an error parsing %q, got %#v", i, s, p)
+		}
+	}
+}
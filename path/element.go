@@ -0,0 +1,132 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"net"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// ElementKind classifies the elements of a path.
+type ElementKind int
+
+const (
+	// OtherElement is the kind of elements not covered by any other kind,
+	// such as elements wrapping a value.Value other than the Wildcard.
+	OtherElement ElementKind = iota
+	// NilElement is the kind of an element wrapping nil.
+	NilElement
+	// StringElement is the kind of an element wrapping a string.
+	StringElement
+	// IntElement is the kind of an element wrapping a signed or
+	// unsigned integer of any size.
+	IntElement
+	// FloatElement is the kind of an element wrapping a float32 or a
+	// float64.
+	FloatElement
+	// BoolElement is the kind of an element wrapping a bool.
+	BoolElement
+	// BytesElement is the kind of an element wrapping a []byte.
+	BytesElement
+	// MapElement is the kind of an element wrapping a
+	// map[string]interface{}, such as the keys of a list element.
+	MapElement
+	// SliceElement is the kind of an element wrapping a []interface{}.
+	SliceElement
+	// PathElement is the kind of an element wrapping a key.Path.
+	PathElement
+	// PointerElement is the kind of an element wrapping a key.Pointer.
+	PointerElement
+	// WildcardElement is the kind of the Wildcard element.
+	WildcardElement
+	// ComplexElement is the kind of an element wrapping a complex64 or
+	// a complex128.
+	ComplexElement
+	// IPElement is the kind of an element wrapping an IP address,
+	// created from a net.IP or a netip.Addr.
+	IPElement
+)
+
+var elementKindStrings = [...]string{
+	OtherElement:    "other",
+	NilElement:      "nil",
+	StringElement:   "string",
+	IntElement:      "integer",
+	FloatElement:    "float",
+	BoolElement:     "boolean",
+	BytesElement:    "bytes",
+	MapElement:      "map",
+	SliceElement:    "slice",
+	PathElement:     "path",
+	PointerElement:  "pointer",
+	WildcardElement: "wildcard",
+	ComplexElement:  "complex",
+	IPElement:       "ip address",
+}
+
+func (k ElementKind) String() string {
+	if k < 0 || int(k) >= len(elementKindStrings) {
+		return "unknown"
+	}
+	return elementKindStrings[k]
+}
+
+// ElementType returns the kind of the path element k.
+func ElementType(k key.Key) ElementKind {
+	if k == nil {
+		return NilElement
+	}
+	switch v := k.Key().(type) {
+	case nil:
+		return NilElement
+	case string:
+		// Elements wrapping a []byte also return a string from Key.
+		if k.Equal(key.New([]byte(v))) {
+			return BytesElement
+		}
+		return StringElement
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return IntElement
+	case float32, float64:
+		return FloatElement
+	case complex64, complex128:
+		return ComplexElement
+	case bool:
+		return BoolElement
+	case map[string]interface{}:
+		return MapElement
+	case []interface{}:
+		return SliceElement
+	case key.Path:
+		return PathElement
+	case key.Pointer:
+		return PointerElement
+	case net.IP:
+		return IPElement
+	case WildcardType:
+		return WildcardElement
+	}
+	return OtherElement
+}
+
+// MapElementKeys returns the keys of the path element k, as in the
+// keys of a list element of a gNMI path, along with true if k wraps a
+// map[string]interface{}, and nil and false otherwise. The returned
+// map is a copy, so modifying it doesn't affect k.
+func MapElementKeys(k key.Key) (map[string]interface{}, bool) {
+	if k == nil {
+		return nil, false
+	}
+	m, ok := k.Key().(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	keys := make(map[string]interface{}, len(m))
+	for name, v := range m {
+		keys[name] = v
+	}
+	return keys, true
+}
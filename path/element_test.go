@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestElementType(t *testing.T) {
+	tcases := []struct {
+		in  key.Key
+		out ElementKind
+		str string
+	}{
+		{in: nil, out: NilElement, str: "nil"},
+		{in: key.New(nil), out: NilElement, str: "nil"},
+		{in: key.New("foo"), out: StringElement, str: "string"},
+		{in: key.New("*"), out: StringElement, str: "string"},
+		{in: key.New([]byte("foo")), out: BytesElement, str: "bytes"},
+		{in: key.New(int8(1)), out: IntElement, str: "integer"},
+		{in: key.New(int64(-1)), out: IntElement, str: "integer"},
+		{in: key.New(uint16(1)), out: IntElement, str: "integer"},
+		{in: key.New(uint64(1)), out: IntElement, str: "integer"},
+		{in: key.New(float32(1)), out: FloatElement, str: "float"},
+		{in: key.New(float64(1)), out: FloatElement, str: "float"},
+		{in: key.New(complex64(1 + 2i)), out: ComplexElement, str: "complex"},
+		{in: key.New(complex128(1 + 2i)), out: ComplexElement, str: "complex"},
+		{in: key.New(true), out: BoolElement, str: "boolean"},
+		{
+			in:  key.New(map[string]interface{}{"name": "Ethernet1"}),
+			out: MapElement,
+			str: "map",
+		},
+		{in: key.New([]interface{}{"foo"}), out: SliceElement, str: "slice"},
+		{in: key.New(New("foo")), out: PathElement, str: "path"},
+		{in: key.New(key.NewPointer(New("foo"))), out: PointerElement, str: "pointer"},
+		{in: Wildcard, out: WildcardElement, str: "wildcard"},
+		{in: key.New(net.ParseIP("10.0.0.1")), out: IPElement, str: "ip address"},
+		{in: key.New(net.ParseIP("2001:db8::1")), out: IPElement, str: "ip address"},
+		{in: key.New(customKey{i: &a}), out: OtherElement, str: "other"},
+	}
+	for i, tcase := range tcases {
+		if kind := ElementType(tcase.in); kind != tcase.out {
+			t.Errorf("Test %d failed: ElementType(%#v) = %s, expected %s",
+				i, tcase.in, kind, tcase.out)
+		}
+		if s := tcase.out.String(); s != tcase.str {
+			t.Errorf("Test %d failed: expected %q, got %q", i, tcase.str, s)
+		}
+	}
+	if s := ElementKind(-1).String(); s != "unknown" {
+		t.Errorf("expected %q, got %q", "unknown", s)
+	}
+}
+
+func TestMapElementKeys(t *testing.T) {
+	tcases := []struct {
+		in   key.Key
+		keys map[string]interface{}
+		ok   bool
+	}{
+		{
+			in:   key.New(map[string]interface{}{"name": "Ethernet1"}),
+			keys: map[string]interface{}{"name": "Ethernet1"},
+			ok:   true,
+		}, {
+			in: key.New(map[string]interface{}{
+				"prefix":   "10.0.0.0/8",
+				"vrf":      "default",
+				"priority": uint32(10),
+			}),
+			keys: map[string]interface{}{
+				"prefix":   "10.0.0.0/8",
+				"vrf":      "default",
+				"priority": uint32(10),
+			},
+			ok: true,
+		}, {
+			in:   key.New(map[string]interface{}{}),
+			keys: map[string]interface{}{},
+			ok:   true,
+		},
+		{in: nil},
+		{in: key.New("name")},
+		{in: key.New([]interface{}{"name", "Ethernet1"})},
+		{in: key.New(New("name", "Ethernet1"))},
+		{in: Wildcard},
+	}
+	for i, tcase := range tcases {
+		keys, ok := MapElementKeys(tcase.in)
+		if ok != tcase.ok {
+			t.Errorf("Test %d failed: expected %t, got %t", i, tcase.ok, ok)
+		}
+		if !tcase.ok {
+			if keys != nil {
+				t.Errorf("Test %d failed: expected nil keys, got %v", i, keys)
+			}
+			continue
+		}
+		if !key.Equal(keys, tcase.keys) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, tcase.keys, keys)
+		}
+	}
+
+	k := key.New(map[string]interface{}{"name": "Ethernet1"})
+	keys, _ := MapElementKeys(k)
+	keys["name"] = "Ethernet2"
+	if !k.Equal(key.New(map[string]interface{}{"name": "Ethernet1"})) {
+		t.Errorf("modifying the keys returned by MapElementKeys modified %v", k)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// Explain returns a human-readable explanation of how path a compares
+// to path b, meant to debug unexpected results of the functions of
+// this package. It holds one line for each of Equal(a, b),
+// HasPrefix(a, b), Match(a, b) and MatchPrefix(a, b), giving the
+// result and, when it's false, the first point of mismatch, such as
+// the index of the first elements that differ, rendered with their
+// types, or the lengths of the paths. A last line tells whether the
+// string representation of a starts with that of b, which doesn't
+// imply that b is a prefix of a: "/foo/barbaz" starts with "/foo/bar".
+// The format of the explanation isn't meant to be parsed and may
+// change.
+func Explain(a, b key.Path) string {
+	var sb strings.Builder
+	explainLine(&sb, "Equal", explainMismatch(a, b, false, false))
+	explainLine(&sb, "HasPrefix", explainMismatch(a, b, false, true))
+	explainLine(&sb, "Match", explainMismatch(a, b, true, false))
+	explainLine(&sb, "MatchPrefix", explainMismatch(a, b, true, true))
+	as, bs := a.String(), b.String()
+	switch {
+	case !strings.HasPrefix(as, bs):
+		explainLine(&sb, "StringPrefix", fmt.Sprintf("%q doesn't start with %q", as, bs))
+	case !HasPrefix(a, b):
+		fmt.Fprintf(&sb, "StringPrefix: true, but b isn't a prefix of a: %s\n",
+			explainMismatch(a, b, false, true))
+	default:
+		explainLine(&sb, "StringPrefix", "")
+	}
+	return sb.String()
+}
+
+func explainLine(sb *strings.Builder, name, mismatch string) {
+	sb.WriteString(name)
+	if mismatch == "" {
+		sb.WriteString(": true\n")
+		return
+	}
+	sb.WriteString(": false: " + mismatch + "\n")
+}
+
+// explainMismatch returns the first point of mismatch between a and b
+// for the comparison of paths selected by wildcards, which makes
+// wildcards of a match any element of b, and prefix, which only
+// requires b to be a prefix of a, or the empty string if there is no
+// mismatch.
+func explainMismatch(a, b key.Path, wildcards, prefix bool) string {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Equal(b[i]) || wildcards && a[i].Equal(Wildcard) {
+			continue
+		}
+		explanation := fmt.Sprintf("element %d differs: a has %s, b has %s",
+			i, explainElement(a[i]), explainElement(b[i]))
+		if wildcards && b[i].Equal(Wildcard) {
+			explanation += ", and a wildcard in b only matches a wildcard in a"
+		}
+		return explanation
+	}
+	switch {
+	case len(a) < len(b):
+		return fmt.Sprintf("b is longer than a: a has %d elements, b has %d",
+			len(a), len(b))
+	case len(a) > len(b) && !prefix:
+		return fmt.Sprintf("a is longer than b: a has %d elements, b has %d",
+			len(a), len(b))
+	}
+	return ""
+}
+
+func explainElement(element key.Key) string {
+	if element.Equal(Wildcard) {
+		return "a wildcard"
+	}
+	return fmt.Sprintf("%q (%T)", element.String(), element.Key())
+}
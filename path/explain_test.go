@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestExplain(t *testing.T) {
+	tcases := []struct {
+		a, b     key.Path
+		expected string
+	}{{
+		a: New("foo", "bar"),
+		b: New("foo", "bar"),
+		expected: `Equal: true
+HasPrefix: true
+Match: true
+MatchPrefix: true
+StringPrefix: true
+`,
+	}, {
+		a: New("foo", "bar", "baz"),
+		b: New("foo", "bar"),
+		expected: `Equal: false: a is longer than b: a has 3 elements, b has 2
+HasPrefix: true
+Match: false: a is longer than b: a has 3 elements, b has 2
+MatchPrefix: true
+StringPrefix: true
+`,
+	}, {
+		a: New("foo"),
+		b: New("foo", "bar"),
+		expected: `Equal: false: b is longer than a: a has 1 elements, b has 2
+HasPrefix: false: b is longer than a: a has 1 elements, b has 2
+Match: false: b is longer than a: a has 1 elements, b has 2
+MatchPrefix: false: b is longer than a: a has 1 elements, b has 2
+StringPrefix: false: "/foo" doesn't start with "/foo/bar"
+`,
+	}, {
+		a: New("foo", int64(1), "baz"),
+		b: New("foo", "1", "baz"),
+		expected: `Equal: false: element 1 differs: a has "1" (int64), b has "1" (string)
+HasPrefix: false: element 1 differs: a has "1" (int64), b has "1" (string)
+Match: false: element 1 differs: a has "1" (int64), b has "1" (string)
+MatchPrefix: false: element 1 differs: a has "1" (int64), b has "1" (string)
+StringPrefix: true, but b isn't a prefix of a: ` +
+			`element 1 differs: a has "1" (int64), b has "1" (string)
+`,
+	}, {
+		a: New("foo", Wildcard, "baz"),
+		b: New("foo", "bar"),
+		expected: `Equal: false: element 1 differs: a has a wildcard, b has "bar" (string)
+HasPrefix: false: element 1 differs: a has a wildcard, b has "bar" (string)
+Match: false: a is longer than b: a has 3 elements, b has 2
+MatchPrefix: true
+StringPrefix: false: "/foo/*/baz" doesn't start with "/foo/bar"
+`,
+	}, {
+		a: New("foo", "bar"),
+		b: New("foo", Wildcard),
+		expected: `Equal: false: element 1 differs: a has "bar" (string), b has a wildcard
+HasPrefix: false: element 1 differs: a has "bar" (string), b has a wildcard
+Match: false: element 1 differs: a has "bar" (string), b has a wildcard, ` +
+			`and a wildcard in b only matches a wildcard in a
+MatchPrefix: false: element 1 differs: a has "bar" (string), b has a wildcard, ` +
+			`and a wildcard in b only matches a wildcard in a
+StringPrefix: false: "/foo/bar" doesn't start with "/foo/*"
+`,
+	}, {
+		a: New("foo", "barbaz"),
+		b: New("foo", "bar"),
+		expected: `Equal: false: element 1 differs: a has "barbaz" (string), b has "bar" (string)
+HasPrefix: false: element 1 differs: a has "barbaz" (string), b has "bar" (string)
+Match: false: element 1 differs: a has "barbaz" (string), b has "bar" (string)
+MatchPrefix: false: element 1 differs: a has "barbaz" (string), b has "bar" (string)
+StringPrefix: true, but b isn't a prefix of a: ` +
+			`element 1 differs: a has "barbaz" (string), b has "bar" (string)
+`,
+	}}
+	for i, tcase := range tcases {
+		// The explanation must be consistent with the functions.
+		for name, result := range map[string]bool{
+			"Equal":       Equal(tcase.a, tcase.b),
+			"HasPrefix":   HasPrefix(tcase.a, tcase.b),
+			"Match":       Match(tcase.a, tcase.b),
+			"MatchPrefix": MatchPrefix(tcase.a, tcase.b),
+		} {
+			if line := name + ": true\n"; result != strings.Contains(tcase.expected, line) {
+				t.Fatalf("Test %d is inconsistent with %s", i, name)
+			}
+		}
+		if s := Explain(tcase.a, tcase.b); s != tcase.expected {
+			t.Errorf("Test %d failed: expected:\n%s\ngot:\n%s", i, tcase.expected, s)
+		}
+	}
+}
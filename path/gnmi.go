@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// FromStringGNMI constructs a path from a gNMI path string, as
+// described in
+// https://github.com/openconfig/reference/blob/master/rpc/gnmi/gnmi-path-conventions.md
+// Elements are separated by '/', except within brackets, and an
+// element of a keyed list, such as "interface[name=eth0][index=1]",
+// is turned into two elements: its name, as a string, followed by a
+// map[string]interface{} holding its keys and their values, as
+// strings. Within elements, a '\' escapes the character following
+// it, such as a ']' within the value of a key. As with FromString, a
+// leading '/' is optional and both "" and "/" are treated as a
+// key.Path{}. Elements that can't be parsed by ParseGNMIElement, such
+// as "a[b", are kept as strings.
+func FromStringGNMI(str string) key.Path {
+	if str != "" && str[0] == '/' {
+		str = str[1:]
+	}
+	result := key.Path{}
+	if str == "" {
+		return result
+	}
+	for {
+		i := nextGNMIElement(str)
+		name, keys, err := ParseGNMIElement(str[:i])
+		if err != nil {
+			result = append(result, key.New(str[:i]))
+		} else {
+			result = append(result, key.New(name))
+			if keys != nil {
+				result = append(result, keys)
+			}
+		}
+		if i == len(str) {
+			return result
+		}
+		str = str[i+1:]
+	}
+}
+
+// nextGNMIElement returns the index of the '/' ending the first element
+// of str, or len(str) if it's the last element.
+func nextGNMIElement(str string) int {
+	var inBrackets, escape bool
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case escape:
+			escape = false
+		case c == '\\':
+			escape = true
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case c == '/' && !inBrackets:
+			return i
+		}
+	}
+	return len(str)
+}
+
+// ParseGNMIElement parses an element of a gNMI path string, such as
+// "interface[name=eth0][index=1]", into its name and, for an element
+// of a keyed list, a key.Key wrapping a map[string]interface{} that
+// holds the keys of the element and their values, as strings. keys
+// is nil for an element without keys. Within the element, a '\'
+// escapes the character following it. An error is returned if the
+// element has a malformed key, or keys but no name.
+func ParseGNMIElement(element string) (name string, keys key.Key, err error) {
+	name, rest, found := cutUnescaped(element, '[')
+	if !found {
+		return name, nil, nil
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("missing element name in %q", element)
+	}
+	m := map[string]interface{}{}
+	for {
+		var k, v string
+		if k, rest, found = cutUnescaped(rest, '='); !found {
+			return "", nil, fmt.Errorf("missing '=' in %q", element)
+		} else if k == "" {
+			return "", nil, fmt.Errorf("missing key name in %q", element)
+		}
+		if v, rest, found = cutUnescaped(rest, ']'); !found {
+			return "", nil, fmt.Errorf("missing ']' in %q", element)
+		}
+		if _, ok := m[k]; ok {
+			return "", nil, fmt.Errorf("duplicate key %q in %q", k, element)
+		}
+		m[k] = v
+		if rest == "" {
+			break
+		} else if rest[0] != '[' {
+			return "", nil, fmt.Errorf("unexpected %q after key %q in %q", rest, k, element)
+		}
+		rest = rest[1:]
+	}
+	return name, key.New(m), nil
+}
+
+// cutUnescaped returns the unescaped text of s before the first
+// unescaped occurrence of sep, and what follows it, with found set to
+// true. If sep isn't found, it returns the unescaped s, "" and false.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	if strings.IndexByte(s, '\\') < 0 {
+		if i := strings.IndexByte(s, sep); i >= 0 {
+			return s[:i], s[i+1:], true
+		}
+		return s, "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == sep:
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), "", false
+}
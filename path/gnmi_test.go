@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestFromStringGNMI(t *testing.T) {
+	tcases := []struct {
+		in  string
+		out key.Path
+	}{{
+		in:  "",
+		out: key.Path{},
+	}, {
+		in:  "/",
+		out: key.Path{},
+	}, {
+		in:  "/interfaces/interface/state",
+		out: New("interfaces", "interface", "state"),
+	}, {
+		in: "/interfaces/interface[name=eth0]/state",
+		out: New("interfaces", "interface",
+			map[string]interface{}{"name": "eth0"}, "state"),
+	}, {
+		in: "interfaces/interface[name=eth0][index=1]/state",
+		out: New("interfaces", "interface",
+			map[string]interface{}{"name": "eth0", "index": "1"}, "state"),
+	}, {
+		in: "/a[name=x/y]/b[k=v=w]",
+		out: New("a", map[string]interface{}{"name": "x/y"},
+			"b", map[string]interface{}{"k": "v=w"}),
+	}, {
+		in: `/a[name=x\]y\\]/b\/c`,
+		out: New("a", map[string]interface{}{"name": `x]y\`},
+			"b/c"),
+	}, {
+		in:  "/a[name=x",
+		out: New("a[name=x"),
+	}, {
+		in:  "/a/[name=x]",
+		out: New("a", "[name=x]"),
+	}}
+	for i, tcase := range tcases {
+		if out := FromStringGNMI(tcase.in); !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: %#v != %#v", i, out, tcase.out)
+		}
+	}
+}
+
+func TestParseGNMIElement(t *testing.T) {
+	name, keys, err := ParseGNMIElement("interface[name=eth0][index=1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := key.New(map[string]interface{}{"name": "eth0", "index": "1"})
+	if name != "interface" || !keys.Equal(expected) {
+		t.Errorf("expected interface, %#v, got %s, %#v", expected, name, keys)
+	}
+	if name, keys, err := ParseGNMIElement("state"); err != nil || name != "state" ||
+		keys != nil {
+		t.Errorf("expected state, nil, nil, got %s, %#v, %v", name, keys, err)
+	}
+
+	for in, expectedErr := range map[string]string{
+		"[name=eth0]":        `missing element name in "[name=eth0]"`,
+		"a[name]":            `missing '=' in "a[name]"`,
+		"a[=eth0]":           `missing key name in "a[=eth0]"`,
+		"a[name=eth0":        `missing ']' in "a[name=eth0"`,
+		"a[name=x][name=y]":  `duplicate key "name" in "a[name=x][name=y]"`,
+		"a[name=x]b[name=y]": `unexpected "b[name=y]" after key "name" in "a[name=x]b[name=y]"`,
+	} {
+		if _, _, err := ParseGNMIElement(in); err == nil || err.Error() != expectedErr {
+			t.Errorf("expected error %q for %q, got %v", expectedErr, in, err)
+		}
+	}
+}
@@ -18,6 +18,9 @@ import (
 type Map struct {
 	val      interface{}
 	ok       bool
+	meta     interface{}
+	metaOk   bool
+	count    int // number of values registered in the subtree
 	wildcard *Map
 	children *key.Map
 }
@@ -45,6 +48,44 @@ func (m *Map) Visit(p key.Path, fn VisitorFunc) error {
 	return m.visit(match, p, fn)
 }
 
+// Notify calls deliver with update and value for every registered path
+// that matches update, as with Visit, such that an update can be
+// dispatched to all the subscriptions it matches, whether they hold
+// wildcards or not. The path passed to deliver is the registered path,
+// so with subscriptions registered for /a/b and /a/*, an update of
+// /a/b is delivered once with /a/b and once with /a/*. As there is no
+// wildcard matching several elements, only registered paths of the
+// same length as update match it. Notify calls deliver as matches are
+// found rather than collecting them, and the registered path passed to
+// deliver shares memory with the other calls, so it must be cloned to
+// be retained after deliver returns.
+func (m *Map) Notify(update key.Path, value interface{},
+	deliver func(sub key.Path, value interface{})) {
+	m.notify(update, make(key.Path, len(update)), 0, value, deliver)
+}
+
+// notify delivers value to the paths matching update that are
+// registered in the subtree of m, which is reached by following the
+// first depth elements of sub.
+func (m *Map) notify(update, sub key.Path, depth int, value interface{},
+	deliver func(sub key.Path, value interface{})) {
+	for ; depth < len(update); depth++ {
+		if m.wildcard != nil {
+			sub[depth] = Wildcard
+			m.wildcard.notify(update, sub, depth+1, value, deliver)
+		}
+		next, ok := m.children.Get(update[depth])
+		if !ok {
+			return
+		}
+		sub[depth] = update[depth]
+		m = next.(*Map)
+	}
+	if m.ok {
+		deliver(sub, value)
+	}
+}
+
 // VisitPrefixes calls a function fn for every value in the
 // Map that is registered with a prefix of a path p.
 func (m *Map) VisitPrefixes(p key.Path, fn VisitorFunc) error {
@@ -59,6 +100,44 @@ func (m *Map) VisitPrefixed(p key.Path, fn VisitorFunc) error {
 	return m.visit(suffix, p, fn)
 }
 
+// AggregatePrefix folds all the values in the Map that are registered
+// with a path prefixed by prefix into a single value, starting from
+// init and calling combine with the value accumulated so far and each
+// value in turn. Wildcards in prefix match any element of registered
+// paths, and wildcards in registered paths match any element of
+// prefix, so AggregatePrefix can for instance sum counters registered
+// under "/interfaces/*/state/counters". Values are folded in no
+// particular order, so combine should be commutative.
+func (m *Map) AggregatePrefix(prefix key.Path,
+	combine func(acc, v interface{}) interface{}, init interface{}) interface{} {
+	acc := init
+	m.aggregatePrefix(prefix, func(v interface{}) error {
+		acc = combine(acc, v)
+		return nil
+	})
+	return acc
+}
+
+func (m *Map) aggregatePrefix(p key.Path, fn VisitorFunc) {
+	if len(p) == 0 {
+		_ = m.visitSubtree(fn)
+		return
+	}
+	if m.wildcard != nil {
+		m.wildcard.aggregatePrefix(p[1:], fn)
+	}
+	if p[0].Equal(Wildcard) {
+		_ = m.children.Iter(func(_, next interface{}) error {
+			next.(*Map).aggregatePrefix(p[1:], fn)
+			return nil
+		})
+		return
+	}
+	if next, ok := m.children.Get(p[0]); ok {
+		next.(*Map).aggregatePrefix(p[1:], fn)
+	}
+}
+
 func (m *Map) visit(typ visitType, p key.Path, fn VisitorFunc) error {
 	for i, element := range p {
 		if m.ok && typ == prefix {
@@ -102,9 +181,10 @@ func (m *Map) visitSubtree(fn VisitorFunc) error {
 	})
 }
 
-// IsEmpty returns true if no paths have been registered, false otherwise.
+// IsEmpty returns true if no paths have been registered, with a value
+// or with node metadata, false otherwise.
 func (m *Map) IsEmpty() bool {
-	return m.wildcard == nil && m.children.Len() == 0 && !m.ok
+	return m.wildcard == nil && m.children.Len() == 0 && !m.ok && !m.metaOk
 }
 
 // Get returns the value registered with an exact match of a
@@ -112,51 +192,260 @@ func (m *Map) IsEmpty() bool {
 // and false. If p has an exact match and it is set to true,
 // Get returns nil and true.
 func (m *Map) Get(p key.Path) (interface{}, bool) {
+	m = m.lookup(p)
+	if m == nil {
+		return nil, false
+	}
+	return m.val, m.ok
+}
+
+// lookup returns the node of the Map reached by following the
+// elements of p, where wildcards only match wildcards, or nil if
+// there is no such node.
+func (m *Map) lookup(p key.Path) *Map {
 	for _, element := range p {
 		if element.Equal(Wildcard) {
 			if m.wildcard == nil {
-				return nil, false
+				return nil
 			}
 			m = m.wildcard
 			continue
 		}
 		next, ok := m.children.Get(element)
 		if !ok {
-			return nil, false
+			return nil
 		}
 		m = next.(*Map)
 	}
-	return m.val, m.ok
+	return m
+}
+
+// LongestPrefix returns the longest registered path that is a
+// prefix of p, along with its value. Registered paths may contain
+// wildcards, which match any element of p. If two registered paths
+// of the same length are prefixes of p, the one with a concrete
+// element at the first position where they differ is returned. If
+// no registered path is a prefix of p, LongestPrefix returns nil,
+// nil and false. In the general case, time complexity is linear
+// with respect to the length of p.
+func (m *Map) LongestPrefix(p key.Path) (key.Path, interface{}, bool) {
+	var lp longestPrefix
+	m.longestPrefix(p, make(key.Path, len(p)), 0, &lp)
+	return lp.path, lp.val, lp.ok
+}
+
+type longestPrefix struct {
+	path key.Path
+	val  interface{}
+	ok   bool
+}
+
+func (m *Map) longestPrefix(p, matched key.Path, depth int, lp *longestPrefix) {
+	if m.ok && (!lp.ok || depth > len(lp.path)) {
+		lp.path, lp.val, lp.ok = Clone(matched[:depth]), m.val, true
+	}
+	if depth == len(p) {
+		return
+	}
+	if next, ok := m.children.Get(p[depth]); ok {
+		matched[depth] = p[depth]
+		next.(*Map).longestPrefix(p, matched, depth+1, lp)
+	}
+	if m.wildcard != nil {
+		matched[depth] = Wildcard
+		m.wildcard.longestPrefix(p, matched, depth+1, lp)
+	}
 }
 
 // Set registers a path p with a value. If the path was already
 // registered with a value it returns false and true otherwise.
 func (m *Map) Set(p key.Path, v interface{}) bool {
+	n := m.create(p)
+	set := !n.ok
+	n.val, n.ok = v, true
+	if set {
+		m.addCount(p, 1)
+	}
+	return set
+}
+
+// SetAll registers the paths of pairs with their values, as with
+// successive calls to Set, and returns the number of paths that
+// weren't registered yet. If several pairs have equal paths, the value
+// of the last one is registered. The nodes of the prefix a path shares
+// with the path of the previous pair are reused rather than traversed
+// again from the root, such that SetAll is faster than successive
+// calls to Set when consecutive pairs share long prefixes, as they do
+// when the pairs are sorted with Compare. The pairs aren't sorted by
+// SetAll, since sorting them usually costs more than the traversals
+// it saves.
+func (m *Map) SetAll(pairs []PathValue) int {
+	var added int
+	var prev key.Path
+	// nodes[i] is the node reached by following the first i elements
+	// of prev.
+	nodes := []*Map{m}
+	for _, pv := range pairs {
+		common, _, rest := PathDiff(prev, pv.Path)
+		nodes = nodes[:common+1]
+		for _, element := range rest {
+			nodes = append(nodes, nodes[len(nodes)-1].createChild(element))
+		}
+		n := nodes[len(nodes)-1]
+		if !n.ok {
+			added++
+			for _, node := range nodes {
+				node.count++
+			}
+		}
+		n.val, n.ok = pv.Value, true
+		prev = pv.Path
+	}
+	return added
+}
+
+// addCount adds delta to the count of the nodes along the path p,
+// which must all exist.
+func (m *Map) addCount(p key.Path, delta int) {
+	m.count += delta
+	for _, element := range p {
+		if element.Equal(Wildcard) {
+			m = m.wildcard
+		} else {
+			next, _ := m.children.Get(element)
+			m = next.(*Map)
+		}
+		m.count += delta
+	}
+}
+
+// CountPrefix returns the number of values registered with the path
+// prefix and with every path it prefixes. As with DeletePrefix,
+// wildcards in prefix only match wildcards of registered paths. The
+// Map maintains the number of values registered under each of its
+// nodes, so CountPrefix is linear with respect to the length of
+// prefix, whatever the number of paths it prefixes.
+func (m *Map) CountPrefix(prefix key.Path) int {
+	m = m.lookup(prefix)
+	if m == nil {
+		return 0
+	}
+	return m.count
+}
+
+// create returns the node of the Map reached by following the
+// elements of p, creating the missing nodes along the way.
+func (m *Map) create(p key.Path) *Map {
 	for _, element := range p {
+		m = m.createChild(element)
+	}
+	return m
+}
+
+// createChild returns the child of the node of the Map reached by
+// following element, creating it if it's missing.
+func (m *Map) createChild(element key.Key) *Map {
+	if element.Equal(Wildcard) {
+		if m.wildcard == nil {
+			m.wildcard = &Map{}
+		}
+		return m.wildcard
+	}
+	if m.children == nil {
+		m.children = key.NewMap()
+	}
+	next, ok := m.children.Get(element)
+	if !ok {
+		next = &Map{}
+		m.children.Set(element, next)
+	}
+	return next.(*Map)
+}
+
+// Children returns the elements of the paths of the Map that follow
+// path prefix immediately, whether these paths are registered with a
+// value or only prefix other registered paths, such that a tree can
+// be browsed one level at a time. The elements are sorted with
+// key.Compare, which sorts Wildcard after other elements. As with Get,
+// wildcards in prefix only match wildcards of registered paths.
+// Children returns an empty slice if no registered path follows
+// prefix, and nil if prefix isn't a node of the Map.
+func (m *Map) Children(prefix key.Path) []key.Key {
+	m = m.lookup(prefix)
+	if m == nil {
+		return nil
+	}
+	children := make([]key.Key, 0, m.children.Len()+1)
+	_ = m.children.Iter(func(k, _ interface{}) error {
+		children = append(children, k.(key.Key))
+		return nil
+	})
+	if m.wildcard != nil {
+		children = append(children, Wildcard)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return key.Compare(children[i], children[j]) < 0
+	})
+	return children
+}
+
+// SetNode attaches metadata to the node of the Map at path prefix,
+// replacing the metadata already attached to it, if any. Metadata is
+// distinct from the value registered with prefix, if any, so it can
+// annotate interior nodes, which have descendants registered with
+// values, without registering prefix itself: metadata isn't visited
+// by the Visit methods nor returned by Get. As with Set, wildcards in
+// prefix designate the wildcard nodes of the Map. SetNode returns true
+// if no metadata was attached to the node before, and false otherwise.
+func (m *Map) SetNode(prefix key.Path, meta interface{}) bool {
+	m = m.create(prefix)
+	set := !m.metaOk
+	m.meta, m.metaOk = meta, true
+	return set
+}
+
+// GetNode returns the metadata attached with SetNode to the node at
+// path prefix, and true. If no metadata is attached to that node,
+// GetNode returns nil and false.
+func (m *Map) GetNode(prefix key.Path) (interface{}, bool) {
+	m = m.lookup(prefix)
+	if m == nil {
+		return nil, false
+	}
+	return m.meta, m.metaOk
+}
+
+// DeleteNode detaches the metadata attached to the node at path
+// prefix, leaving the values registered with prefix and its
+// descendants untouched. It returns true if metadata was detached and
+// false otherwise.
+func (m *Map) DeleteNode(prefix key.Path) bool {
+	maps := make([]*Map, len(prefix)+1)
+	for i, element := range prefix {
+		maps[i] = m
 		if element.Equal(Wildcard) {
 			if m.wildcard == nil {
-				m.wildcard = &Map{}
+				return false
 			}
 			m = m.wildcard
 			continue
 		}
-		if m.children == nil {
-			m.children = key.NewMap()
-		}
 		next, ok := m.children.Get(element)
 		if !ok {
-			next = &Map{}
-			m.children.Set(element, next)
+			return false
 		}
 		m = next.(*Map)
 	}
-	set := !m.ok
-	m.val, m.ok = v, true
-	return set
+	deleted := m.metaOk
+	m.meta, m.metaOk = nil, false
+	maps[len(prefix)] = m
+	prune(maps, prefix)
+	return deleted
 }
 
 // Delete unregisters the value registered with a path. It
-// returns true if a value was deleted and false otherwise.
+// returns true if a value was deleted and false otherwise. The
+// metadata attached to the node of the path, if any, is kept.
 func (m *Map) Delete(p key.Path) bool {
 	maps := make([]*Map, len(p)+1)
 	for i, element := range p {
@@ -177,11 +466,196 @@ func (m *Map) Delete(p key.Path) bool {
 	deleted := m.ok
 	m.val, m.ok = nil, false
 	maps[len(p)] = m
+	if deleted {
+		for _, m := range maps {
+			m.count--
+		}
+	}
+	prune(maps, p)
+	return deleted
+}
+
+// DeletePrefix unregisters the values registered with the path
+// prefix and with every path it prefixes, and returns the number
+// of values unregistered. As with Delete, wildcards in prefix
+// only match wildcards of registered paths. The whole subtree of
+// the Map registered under prefix is removed at once, without
+// visiting the paths it holds. The metadata attached with SetNode
+// to the nodes of the subtree, including the node at prefix, is
+// removed as well.
+func (m *Map) DeletePrefix(prefix key.Path) int {
+	maps := make([]*Map, len(prefix)+1)
+	for i, element := range prefix {
+		maps[i] = m
+		if element.Equal(Wildcard) {
+			if m.wildcard == nil {
+				return 0
+			}
+			m = m.wildcard
+			continue
+		}
+		next, ok := m.children.Get(element)
+		if !ok {
+			return 0
+		}
+		m = next.(*Map)
+	}
+	deleted := m.count
+	*m = Map{}
+	for _, m := range maps[:len(prefix)] {
+		m.count -= deleted
+	}
+	maps[len(prefix)] = m
+	prune(maps, prefix)
+	return deleted
+}
+
+// Move moves the values registered with the path oldPrefix and with
+// every path it prefixes under the path newPrefix, such that the value
+// registered with oldPrefix+suffix becomes registered with
+// newPrefix+suffix, and returns the number of values moved. The
+// metadata attached with SetNode to the nodes of the subtree is moved
+// along. Values already registered under newPrefix are kept, unless
+// they are registered with the same path as a moved value, which then
+// replaces them, and the same goes for metadata, such that the moved
+// subtree is merged into the subtree at newPrefix. newPrefix may be a
+// descendant or an ancestor of oldPrefix. As with DeletePrefix,
+// wildcards in either prefix only match wildcards of registered paths.
+// The subtree is moved as a whole, without visiting the paths it holds
+// unless they need to be merged with existing paths.
+func (m *Map) Move(oldPrefix, newPrefix key.Path) int {
+	maps := make([]*Map, len(oldPrefix)+1)
+	n := m
+	for i, element := range oldPrefix {
+		maps[i] = n
+		if element.Equal(Wildcard) {
+			if n.wildcard == nil {
+				return 0
+			}
+			n = n.wildcard
+			continue
+		}
+		next, ok := n.children.Get(element)
+		if !ok {
+			return 0
+		}
+		n = next.(*Map)
+	}
+	moved := n.count
+	if Equal(oldPrefix, newPrefix) {
+		return moved
+	}
+	subtree := &Map{}
+	*subtree = *n
+	*n = Map{}
+	for _, n := range maps[:len(oldPrefix)] {
+		n.count -= moved
+	}
+	maps[len(oldPrefix)] = n
+	prune(maps, oldPrefix)
+
+	if added := merge(m.create(newPrefix), subtree); len(newPrefix) > 0 {
+		m.addCount(newPrefix[:len(newPrefix)-1], added)
+	}
+	return moved
+}
+
+// merge moves the values and metadata of the subtree src into the
+// subtree dst, replacing those registered with the same paths, and
+// returns the number of values added to dst.
+func merge(dst, src *Map) int {
+	if dst.IsEmpty() {
+		*dst = *src
+		return src.count
+	}
+	added := 0
+	if src.ok {
+		if !dst.ok {
+			added++
+		}
+		dst.val, dst.ok = src.val, true
+	}
+	if src.metaOk {
+		dst.meta, dst.metaOk = src.meta, true
+	}
+	if src.wildcard != nil {
+		if dst.wildcard == nil {
+			dst.wildcard = &Map{}
+		}
+		added += merge(dst.wildcard, src.wildcard)
+	}
+	_ = src.children.Iter(func(k, v interface{}) error {
+		if dst.children == nil {
+			dst.children = key.NewMap()
+		}
+		next, ok := dst.children.Get(k)
+		if !ok {
+			next = &Map{}
+			dst.children.Set(k, next)
+		}
+		added += merge(next.(*Map), v.(*Map))
+		return nil
+	})
+	dst.count += added
+	return added
+}
+
+// Coalesce removes the redundant paths of the Map, along with their
+// values, and returns them sorted with Compare. A registered path is
+// redundant if another registered path covers it, where path a covers
+// path b if Match(a, b) is true: a and b have the same length and each
+// element of a is either a wildcard or equal to the element of b at
+// the same position. For instance, /a/b/* covers /a/b/c and /a/b/d,
+// and /a/*/* covers /a/b/*, but /a/b covers neither, since it's
+// shorter. As there is no wildcard matching several elements, paths of
+// different lengths never cover each other. Since coverage is
+// transitive, the paths left after Coalesce still cover all the paths
+// that were registered, and none of them covers another. Coalesce
+// returns nil if no path is redundant.
+func (m *Map) Coalesce() []key.Path {
+	var redundant []key.Path
+	m.walk(nil, func(p key.Path, _ interface{}) {
+		// Every registered path matches itself.
+		matches := 0
+		_ = m.Visit(p, func(interface{}) error {
+			matches++
+			return nil
+		})
+		if matches > 1 {
+			redundant = append(redundant, Clone(p))
+		}
+	})
+	for _, p := range redundant {
+		m.Delete(p)
+	}
+	sort.Slice(redundant, func(i, j int) bool {
+		return Compare(redundant[i], redundant[j]) < 0
+	})
+	return redundant
+}
+
+// walk calls fn with each path registered in the subtree of the Map,
+// prefixed by p, and its value. The path passed to fn is only valid
+// during the call.
+func (m *Map) walk(p key.Path, fn func(p key.Path, v interface{})) {
+	if m.ok {
+		fn(p, m.val)
+	}
+	if m.wildcard != nil {
+		m.wildcard.walk(append(p, Wildcard), fn)
+	}
+	_ = m.children.Iter(func(k, next interface{}) error {
+		next.(*Map).walk(append(p, k.(key.Key)), fn)
+		return nil
+	})
+}
 
-	// Remove any empty maps.
+// prune removes the empty maps along the path p, where maps[i] is
+// the map reached by following the first i elements of p.
+func prune(maps []*Map, p key.Path) {
 	for i := len(p); i > 0; i-- {
-		m = maps[i]
-		if m.ok || m.wildcard != nil || m.children.Len() > 0 {
+		m := maps[i]
+		if m.ok || m.metaOk || m.wildcard != nil || m.children.Len() > 0 {
 			break
 		}
 		parent := maps[i-1]
@@ -192,7 +666,56 @@ func (m *Map) Delete(p key.Path) bool {
 			parent.children.Del(element)
 		}
 	}
-	return deleted
+}
+
+// ToNested returns the contents of the Map as nested Go maps, where
+// each element of the registered paths is a level of nesting, keyed
+// by the string representation of the element. The value registered
+// with a path is placed under its last element, unless other paths
+// are registered under that path, in which case the value is placed
+// under the empty string key of the nested map of its last element.
+// The value registered with the empty path, if any, is thus placed
+// under the empty string key of the returned map. Conflicts are
+// resolved as follows:
+//   - the value registered with a path takes precedence over a child
+//     of that path whose element is the empty string,
+//   - children whose elements have the same string representation,
+//     such as int64(1) and "1", are placed in order of their elements
+//     as sorted by key.Compare, after Wildcard, and the last one wins.
+//
+// The returned maps are never nil and don't share memory with the
+// Map, but the values are not copied. Metadata attached with SetNode
+// isn't included.
+func (m *Map) ToNested() map[string]interface{} {
+	nested := make(map[string]interface{})
+	if m.wildcard != nil {
+		nested[Wildcard.String()] = m.wildcard.nestedValue()
+	}
+	children := make([]key.Key, 0, m.children.Len())
+	_ = m.children.Iter(func(k, _ interface{}) error {
+		children = append(children, k.(key.Key))
+		return nil
+	})
+	sort.Slice(children, func(i, j int) bool {
+		return key.Compare(children[i], children[j]) < 0
+	})
+	for _, k := range children {
+		child, _ := m.children.Get(k)
+		nested[k.String()] = child.(*Map).nestedValue()
+	}
+	if m.ok {
+		nested[""] = m.val
+	}
+	return nested
+}
+
+// nestedValue returns the value of the Map for ToNested, which is the
+// registered value of a leaf and the nested maps of any other node.
+func (m *Map) nestedValue() interface{} {
+	if m.ok && m.wildcard == nil && m.children.Len() == 0 {
+		return m.val
+	}
+	return m.ToNested()
 }
 
 func (m *Map) String() string {
@@ -207,6 +730,11 @@ func (m *Map) write(b *strings.Builder, indent string) {
 		fmt.Fprintf(b, "Val: %v", m.val)
 		b.WriteString("\n")
 	}
+	if m.metaOk {
+		b.WriteString(indent)
+		fmt.Fprintf(b, "Meta: %v", m.meta)
+		b.WriteString("\n")
+	}
 	if m.wildcard != nil {
 		b.WriteString(indent)
 		fmt.Fprintf(b, "Child %q:\n", Wildcard)
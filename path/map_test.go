@@ -7,6 +7,8 @@ package path
 import (
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"github.com/aristanetworks/goarista/key"
@@ -313,6 +315,427 @@ func TestMapDelete(t *testing.T) {
 	}
 }
 
+func TestMapDeletePrefix(t *testing.T) {
+	m := Map{}
+	paths := []key.Path{
+		New("interfaces"),
+		New("interfaces", "Ethernet1"),
+		New("interfaces", "Ethernet1", "state", "counters"),
+		New("interfaces", "Ethernet1", "state", "oper-status"),
+		New("interfaces", "Ethernet1", Wildcard),
+		New("interfaces", "Ethernet2", "state", "counters"),
+		New("interfaces", Wildcard, "state"),
+		New("system", "state"),
+	}
+	for i, p := range paths {
+		m.Set(p, i)
+	}
+
+	testCases := []struct {
+		prefix  key.Path
+		deleted int
+		left    []int // indices in paths of the paths left after deletion
+		count   int   // count of nodes left after deletion
+	}{{
+		prefix:  New("zap"),
+		deleted: 0,
+		left:    []int{0, 1, 2, 3, 4, 5, 6, 7},
+		count:   14,
+	}, {
+		prefix:  New("interfaces", "Ethernet1", "config"),
+		deleted: 0,
+		left:    []int{0, 1, 2, 3, 4, 5, 6, 7},
+		count:   14,
+	}, {
+		prefix:  New("interfaces", "Ethernet1", "state"),
+		deleted: 2,
+		left:    []int{0, 1, 4, 5, 6, 7},
+		count:   11,
+	}, {
+		prefix:  New("interfaces", "Ethernet1"),
+		deleted: 2,
+		left:    []int{0, 5, 6, 7},
+		count:   9,
+	}, {
+		prefix:  New("interfaces", Wildcard),
+		deleted: 1,
+		left:    []int{0, 5, 7},
+		count:   7,
+	}, {
+		prefix:  New("interfaces", "Ethernet2", "state", "counters"),
+		deleted: 1,
+		left:    []int{0, 7},
+		count:   4,
+	}, {
+		prefix:  key.Path{},
+		deleted: 2,
+		left:    []int{},
+		count:   1, // Root node can't be deleted
+	}}
+
+	for i, tc := range testCases {
+		if deleted := m.DeletePrefix(tc.prefix); deleted != tc.deleted {
+			t.Errorf("Test case %d (%v): expected %d deletions, got %d",
+				i, tc.prefix, tc.deleted, deleted)
+		}
+		left := map[int]bool{}
+		for _, j := range tc.left {
+			left[j] = true
+		}
+		for j, p := range paths {
+			if v, ok := m.Get(p); ok != left[j] || ok && v != j {
+				t.Errorf("Test case %d (%v): unexpected value for %v: %v (%t)",
+					i, tc.prefix, p, v, ok)
+			}
+		}
+		if count := countNodes(&m); count != tc.count {
+			t.Errorf("Test case %d (%v): expected %d nodes, got %d\n%s",
+				i, tc.prefix, tc.count, count, m.String())
+		}
+	}
+	if !m.IsEmpty() {
+		t.Errorf("expected empty map, got:\n%s", m.String())
+	}
+}
+
+func TestMapCountPrefix(t *testing.T) {
+	m := Map{}
+	type prefixCount struct {
+		prefix key.Path
+		count  int
+	}
+	check := func(step string, expected []prefixCount) {
+		t.Helper()
+		for _, c := range expected {
+			p, count := c.prefix, c.count
+			if n := m.CountPrefix(p); n != count {
+				t.Errorf("%s: CountPrefix(%s): expected %d, got %d", step, p, count, n)
+			}
+			// The count must match the number of values visited.
+			visited := 0
+			if m.lookup(p) != nil {
+				_ = m.lookup(p).visitSubtree(func(interface{}) error {
+					visited++
+					return nil
+				})
+			}
+			if visited != count {
+				t.Errorf("%s: expected %d values visited under %s, got %d",
+					step, count, p, visited)
+			}
+		}
+	}
+
+	check("empty", []prefixCount{{New(), 0}, {New("interfaces"), 0}})
+	m.Set(New("interfaces", "Ethernet1", "state", "counters"), 1)
+	m.Set(New("interfaces", "Ethernet1", "state", "oper-status"), 2)
+	m.Set(New("interfaces", "Ethernet2", "state", "counters"), 3)
+	m.Set(New("interfaces", Wildcard, "state"), 4)
+	m.Set(New("interfaces"), 5)
+	m.Set(New("system", "state"), 6)
+	m.SetNode(New("system", "config"), "container")
+	// Replacing a value doesn't change the counts.
+	m.Set(New("interfaces", "Ethernet1", "state", "counters"), 7)
+	check("after inserts", []prefixCount{
+		{New(), 6},
+		{New("interfaces"), 5},
+		{New("interfaces", "Ethernet1"), 2},
+		{New("interfaces", "Ethernet1", "state"), 2},
+		{New("interfaces", "Ethernet2"), 1},
+		{New("interfaces", Wildcard), 1},
+		{New("system"), 1},
+		{New("system", "config"), 0},
+		{New("zap"), 0},
+	})
+
+	m.Delete(New("interfaces", "Ethernet1", "state", "counters"))
+	m.Delete(New("interfaces", "Ethernet1", "state", "counters"))
+	m.Delete(New("interfaces", "Ethernet1"))
+	m.Delete(New("interfaces"))
+	check("after deletes", []prefixCount{
+		{New(), 4},
+		{New("interfaces"), 3},
+		{New("interfaces", "Ethernet1"), 1},
+		{New("interfaces", "Ethernet2"), 1},
+		{New("system"), 1},
+	})
+
+	if n := m.DeletePrefix(New("interfaces", "Ethernet1")); n != 1 {
+		t.Errorf("expected DeletePrefix to delete 1 value, got %d", n)
+	}
+	check("after DeletePrefix", []prefixCount{
+		{New(), 3},
+		{New("interfaces"), 2},
+		{New("interfaces", "Ethernet1"), 0},
+		{New("system"), 1},
+	})
+	if n := m.DeletePrefix(New()); n != 3 {
+		t.Errorf("expected DeletePrefix to delete 3 values, got %d", n)
+	}
+	check("after DeletePrefix of the root", []prefixCount{{New(), 0}, {New("system"), 0}})
+}
+
+func TestMapMove(t *testing.T) {
+	newMap := func() *Map {
+		m := &Map{}
+		m.Set(New("system", "a"), 1)
+		m.Set(New("system", "a", "b"), 2)
+		m.Set(New("system", Wildcard, "c"), 3)
+		m.Set(New("system"), 4)
+		m.SetNode(New("system", "a"), "meta")
+		m.Set(New("sys", "a"), 5)
+		m.Set(New("sys", "d"), 6)
+		m.SetNode(New("sys", "d"), "sys-meta")
+		m.Set(New("other"), 7)
+		return m
+	}
+	type entry struct {
+		path key.Path
+		val  interface{}
+	}
+	tcases := []struct {
+		oldPrefix, newPrefix key.Path
+		moved                int
+		entries              []entry
+		meta                 []entry
+	}{{
+		oldPrefix: New("zap"),
+		newPrefix: New("sys"),
+		moved:     0,
+		entries: []entry{
+			{New("system", "a"), 1}, {New("system", "a", "b"), 2},
+			{New("system", Wildcard, "c"), 3}, {New("system"), 4},
+			{New("sys", "a"), 5}, {New("sys", "d"), 6}, {New("other"), 7},
+		},
+	}, {
+		// The moved subtree is merged into the subtree at newPrefix.
+		oldPrefix: New("system"),
+		newPrefix: New("sys"),
+		moved:     4,
+		entries: []entry{
+			{New("sys", "a"), 1}, {New("sys", "a", "b"), 2},
+			{New("sys", Wildcard, "c"), 3}, {New("sys"), 4},
+			{New("sys", "d"), 6}, {New("other"), 7},
+		},
+		meta: []entry{{New("sys", "a"), "meta"}, {New("sys", "d"), "sys-meta"}},
+	}, {
+		oldPrefix: New("system"),
+		newPrefix: New("new", "system"),
+		moved:     4,
+		entries: []entry{
+			{New("new", "system", "a"), 1}, {New("new", "system", "a", "b"), 2},
+			{New("new", "system", Wildcard, "c"), 3}, {New("new", "system"), 4},
+			{New("sys", "a"), 5}, {New("sys", "d"), 6}, {New("other"), 7},
+		},
+		meta: []entry{{New("new", "system", "a"), "meta"}, {New("sys", "d"), "sys-meta"}},
+	}, {
+		// Move a subtree under itself.
+		oldPrefix: New("system"),
+		newPrefix: New("system", "a"),
+		moved:     4,
+		entries: []entry{
+			{New("system", "a", "a"), 1}, {New("system", "a", "a", "b"), 2},
+			{New("system", "a", Wildcard, "c"), 3}, {New("system", "a"), 4},
+			{New("sys", "a"), 5}, {New("sys", "d"), 6}, {New("other"), 7},
+		},
+		meta: []entry{{New("system", "a", "a"), "meta"}},
+	}, {
+		// Move a subtree to its parent.
+		oldPrefix: New("system", "a"),
+		newPrefix: New("system"),
+		moved:     2,
+		entries: []entry{
+			{New("system"), 1}, {New("system", "b"), 2},
+			{New("system", Wildcard, "c"), 3},
+			{New("sys", "a"), 5}, {New("sys", "d"), 6}, {New("other"), 7},
+		},
+		meta: []entry{{New("system"), "meta"}},
+	}, {
+		oldPrefix: New(),
+		newPrefix: New("root"),
+		moved:     7,
+		entries: []entry{
+			{New("root", "system", "a"), 1}, {New("root", "system", "a", "b"), 2},
+			{New("root", "system", Wildcard, "c"), 3}, {New("root", "system"), 4},
+			{New("root", "sys", "a"), 5}, {New("root", "sys", "d"), 6},
+			{New("root", "other"), 7},
+		},
+		meta: []entry{{New("root", "system", "a"), "meta"}},
+	}}
+	for i, tcase := range tcases {
+		m := newMap()
+		if moved := m.Move(tcase.oldPrefix, tcase.newPrefix); moved != tcase.moved {
+			t.Errorf("Test %d failed: expected %d values moved, got %d", i, tcase.moved, moved)
+		}
+		for _, e := range tcase.entries {
+			if v, ok := m.Get(e.path); !ok || v != e.val {
+				t.Errorf("Test %d failed: Get(%s): expected %v, got %v (%t)",
+					i, e.path, e.val, v, ok)
+			}
+		}
+		if n := m.CountPrefix(New()); n != len(tcase.entries) {
+			t.Errorf("Test %d failed: expected %d values, got %d:\n%s",
+				i, len(tcase.entries), n, m)
+		}
+		for _, e := range tcase.meta {
+			if meta, ok := m.GetNode(e.path); !ok || meta != e.val {
+				t.Errorf("Test %d failed: GetNode(%s): expected %v, got %v (%t)",
+					i, e.path, e.val, meta, ok)
+			}
+		}
+		if tcase.moved > 0 && !HasPrefix(tcase.newPrefix, tcase.oldPrefix) {
+			if n := m.CountPrefix(tcase.oldPrefix); n != 0 {
+				t.Errorf("Test %d failed: expected %s to be empty, got %d values",
+					i, tcase.oldPrefix, n)
+			}
+		}
+		// Counts must stay consistent with the values registered.
+		visited := 0
+		_ = m.visitSubtree(func(interface{}) error {
+			visited++
+			return nil
+		})
+		if visited != len(tcase.entries) {
+			t.Errorf("Test %d failed: expected %d values visited, got %d",
+				i, len(tcase.entries), visited)
+		}
+	}
+}
+
+func TestMapCoalesce(t *testing.T) {
+	m := Map{}
+	paths := []key.Path{
+		New("interfaces", "eth0", "state", "counters"),
+		New("interfaces", "eth1", "state", "counters"),
+		New("interfaces", Wildcard, "state", "counters"),
+		New("interfaces", "eth0", "state", "mtu"),
+		New("interfaces", "eth0", Wildcard, "mtu"),
+		New("interfaces", "eth0", Wildcard, Wildcard),
+		New("interfaces", "eth0"),
+		New("interfaces", Wildcard, "config"),
+		New("system", "state"),
+		New("system", Wildcard),
+		New("system"),
+	}
+	for _, p := range paths {
+		m.Set(p, p)
+	}
+	expected := []key.Path{
+		New("interfaces", "eth0", "state", "counters"),
+		New("interfaces", "eth0", "state", "mtu"),
+		New("interfaces", "eth0", Wildcard, "mtu"),
+		New("interfaces", "eth1", "state", "counters"),
+		New("system", "state"),
+	}
+	redundant := m.Coalesce()
+	if len(redundant) != len(expected) {
+		t.Fatalf("expected %v to be redundant, got %v", expected, redundant)
+	}
+	for i := range expected {
+		if !Equal(redundant[i], expected[i]) {
+			t.Errorf("expected %v to be redundant, got %v", expected, redundant)
+			break
+		}
+	}
+	left := []key.Path{
+		New("interfaces", Wildcard, "state", "counters"),
+		New("interfaces", "eth0", Wildcard, Wildcard),
+		New("interfaces", "eth0"),
+		New("interfaces", Wildcard, "config"),
+		New("system", Wildcard),
+		New("system"),
+	}
+	for _, p := range expected {
+		if v, ok := m.Get(p); ok {
+			t.Errorf("expected %s to be removed, got %v", p, v)
+		}
+	}
+	for _, p := range left {
+		if v, ok := m.Get(p); !ok || !Equal(v.(key.Path), p) {
+			t.Errorf("expected %s to be left, got %v (%t)", p, v, ok)
+		}
+	}
+	if n := m.CountPrefix(New()); n != len(left) {
+		t.Errorf("expected %d paths left, got %d", len(left), n)
+	}
+	if redundant := m.Coalesce(); redundant != nil {
+		t.Errorf("expected no redundant paths left, got %v", redundant)
+	}
+}
+
+func TestMapNotify(t *testing.T) {
+	m := Map{}
+	subs := []key.Path{
+		New("interfaces", "eth0", "counters"),
+		New("interfaces", Wildcard, "counters"),
+		New(Wildcard, "eth0", "counters"),
+		New(Wildcard, Wildcard, Wildcard),
+		New("interfaces", "eth1", "counters"),
+		New("interfaces", "eth0"),
+		New("interfaces", "eth0", "counters", "in"),
+		New("interfaces", Wildcard),
+	}
+	for _, sub := range subs {
+		m.Set(sub, nil)
+	}
+	tcases := []struct {
+		update   key.Path
+		expected []key.Path
+	}{{
+		update:   New("interfaces", "eth0", "counters"),
+		expected: []key.Path{subs[0], subs[1], subs[2], subs[3]},
+	}, {
+		update:   New("interfaces", "eth2", "counters"),
+		expected: []key.Path{subs[1], subs[3]},
+	}, {
+		update:   New("system", "eth0", "counters"),
+		expected: []key.Path{subs[2], subs[3]},
+	}, {
+		update:   New("interfaces", "eth0"),
+		expected: []key.Path{subs[5], subs[7]},
+	}, {
+		update:   New("interfaces"),
+		expected: nil,
+	}, {
+		update:   New(),
+		expected: nil,
+	}}
+	for i, tcase := range tcases {
+		var delivered []key.Path
+		m.Notify(tcase.update, i, func(sub key.Path, value interface{}) {
+			if value != i {
+				t.Errorf("Test %d failed: expected value %d, got %v", i, i, value)
+			}
+			delivered = append(delivered, Clone(sub))
+		})
+		sort.Slice(delivered, func(a, b int) bool {
+			return Compare(delivered[a], delivered[b]) < 0
+		})
+		sort.Slice(tcase.expected, func(a, b int) bool {
+			return Compare(tcase.expected[a], tcase.expected[b]) < 0
+		})
+		if len(delivered) != len(tcase.expected) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, tcase.expected, delivered)
+			continue
+		}
+		for j := range delivered {
+			if !Equal(delivered[j], tcase.expected[j]) {
+				t.Errorf("Test %d failed: expected %v, got %v", i, tcase.expected, delivered)
+				break
+			}
+		}
+	}
+
+	update := New("interfaces", "eth0", "counters")
+	var n int
+	deliver := func(key.Path, interface{}) { n++ }
+	if allocs := testing.AllocsPerRun(10, func() {
+		m.Notify(update, nil, deliver)
+	}); allocs > 1 {
+		t.Errorf("expected at most 1 allocation, got %g", allocs)
+	}
+}
+
 func TestMapVisitPrefixes(t *testing.T) {
 	m := Map{}
 	m.Set(key.Path{}, 0)
@@ -403,6 +826,132 @@ func TestMapVisitPrefixed(t *testing.T) {
 	}
 }
 
+func TestMapAggregatePrefix(t *testing.T) {
+	m := Map{}
+	m.Set(New("interfaces", "eth0", "state", "counters", "in"), 1)
+	m.Set(New("interfaces", "eth0", "state", "counters", "out"), 2)
+	m.Set(New("interfaces", "eth1", "state", "counters", "in"), 10)
+	m.Set(New("interfaces", "eth1", "state", "counters", "out"), 20)
+	m.Set(New("interfaces", "eth1", "state", "mtu"), 1500)
+	m.Set(New("interfaces", "eth2", "config", "counters", "in"), 1000)
+	m.Set(New("interfaces", Wildcard, "state", "counters", "drops"), 100)
+	m.Set(New("system", "counters"), 10000)
+
+	sum := func(acc, v interface{}) interface{} { return acc.(int) + v.(int) }
+	tcases := []struct {
+		prefix key.Path
+		sum    int
+	}{{
+		prefix: New("interfaces", "eth0", "state", "counters"),
+		sum:    103,
+	}, {
+		prefix: New("interfaces", "eth1"),
+		sum:    1630,
+	}, {
+		prefix: New("interfaces", Wildcard, "state", "counters"),
+		sum:    133,
+	}, {
+		prefix: New("interfaces", Wildcard, Wildcard, "counters", "in"),
+		sum:    1011,
+	}, {
+		prefix: New("interfaces", "eth3", "state"),
+		sum:    100,
+	}, {
+		prefix: New("interfaces"),
+		sum:    2633,
+	}, {
+		prefix: New(),
+		sum:    12633,
+	}, {
+		prefix: New("foo"),
+		sum:    0,
+	}}
+	for i, tcase := range tcases {
+		if s := m.AggregatePrefix(tcase.prefix, sum, 0); s != tcase.sum {
+			t.Errorf("Test %d failed: expected sum %d under %s, got %v",
+				i, tcase.sum, tcase.prefix, s)
+		}
+	}
+
+	max := m.AggregatePrefix(New("interfaces"), func(acc, v interface{}) interface{} {
+		if v.(int) > acc.(int) {
+			return v
+		}
+		return acc
+	}, 0)
+	if max != 1500 {
+		t.Errorf("expected a max of 1500, got %v", max)
+	}
+}
+
+func TestMapLongestPrefix(t *testing.T) {
+	m := Map{}
+	if p, v, ok := m.LongestPrefix(key.Path{key.New("foo")}); ok {
+		t.Errorf("Expected no match in empty map, Got (path: %v, v: %v)", p, v)
+	}
+
+	m.Set(key.Path{}, 0)
+	m.Set(key.Path{key.New("foo")}, 1)
+	m.Set(key.Path{key.New("foo"), key.New("bar"), key.New("baz")}, 2)
+	m.Set(key.Path{key.New("foo"), Wildcard}, 3)
+	m.Set(key.Path{Wildcard, key.New("qux")}, 4)
+	m.Set(key.Path{Wildcard, key.New("bar"), key.New("baz"), key.New("quux")}, 5)
+
+	testCases := []struct {
+		path     key.Path
+		expected key.Path
+		v        interface{}
+	}{{
+		path:     key.Path{},
+		expected: key.Path{},
+		v:        0,
+	}, {
+		path:     key.Path{key.New("zap")},
+		expected: key.Path{},
+		v:        0,
+	}, {
+		path:     key.Path{key.New("foo")},
+		expected: key.Path{key.New("foo")},
+		v:        1,
+	}, {
+		path:     key.Path{key.New("foo"), key.New("bar")},
+		expected: key.Path{key.New("foo"), Wildcard},
+		v:        3,
+	}, {
+		path:     key.Path{key.New("foo"), key.New("bar"), key.New("baz"), key.New("qux")},
+		expected: key.Path{key.New("foo"), key.New("bar"), key.New("baz")},
+		v:        2,
+	}, {
+		// Wildcard matches deeper than any concrete path.
+		path: key.Path{key.New("foo"), key.New("bar"), key.New("baz"), key.New("quux")},
+		expected: key.Path{Wildcard, key.New("bar"), key.New("baz"),
+			key.New("quux")},
+		v: 5,
+	}, {
+		// Concrete element wins over a wildcard at the same length.
+		path:     key.Path{key.New("foo"), key.New("qux")},
+		expected: key.Path{key.New("foo"), Wildcard},
+		v:        3,
+	}, {
+		path:     key.Path{key.New("zap"), key.New("qux"), key.New("zip")},
+		expected: key.Path{Wildcard, key.New("qux")},
+		v:        4,
+	}}
+
+	for _, tc := range testCases {
+		p, v, ok := m.LongestPrefix(tc.path)
+		if !ok || !Equal(p, tc.expected) || v != tc.v {
+			t.Errorf("Test case %v: Expected (path: %v, v: %v), Got (path: %v, v: %v, ok: %t)",
+				tc.path, tc.expected, tc.v, p, v, ok)
+		}
+	}
+
+	m.Delete(key.Path{})
+	if p, v, ok := m.LongestPrefix(key.Path{key.New("zap")}); ok {
+		t.Errorf("Expected no match, Got (path: %v, v: %v)", p, v)
+	}
+}
+
 func TestMapString(t *testing.T) {
 	m := Map{}
 	m.Set(key.Path{}, 0)
@@ -426,6 +975,205 @@ Child "foo":
 	}
 }
 
+func TestMapToNested(t *testing.T) {
+	m := Map{}
+	if nested := m.ToNested(); nested == nil || len(nested) != 0 {
+		t.Errorf("expected an empty map, got %#v", nested)
+	}
+	m.Set(New("interfaces", "eth0", "mtu"), int64(1500))
+	m.Set(New("interfaces", "eth0", "up"), true)
+	m.Set(New("interfaces", "eth1", "mtu"), int64(9000))
+	m.Set(New("interfaces", Wildcard, "counters"), "any")
+	m.Set(New("interfaces", "eth1"), "interior")
+	m.Set(New("system", ""), "empty element")
+	m.Set(New("system"), "system")
+	m.Set(New(int64(1)), "int")
+	m.Set(New("1"), "string")
+	m.Set(New(), "root")
+
+	expected := map[string]interface{}{
+		"": "root",
+		"interfaces": map[string]interface{}{
+			"*": map[string]interface{}{
+				"counters": "any",
+			},
+			"eth0": map[string]interface{}{
+				"mtu": int64(1500),
+				"up":  true,
+			},
+			"eth1": map[string]interface{}{
+				"":    "interior",
+				"mtu": int64(9000),
+			},
+		},
+		"system": map[string]interface{}{
+			"": "system",
+		},
+		"1": "string",
+	}
+	if nested := m.ToNested(); !test.DeepEqual(expected, nested) {
+		t.Errorf("expected %#v, got %#v", expected, nested)
+	}
+}
+
+func TestMapChildren(t *testing.T) {
+	m := Map{}
+	if children := m.Children(New()); children == nil || len(children) != 0 {
+		t.Errorf("expected no children, got %v", children)
+	}
+	m.Set(New("interfaces", "eth1", "mtu"), 1)
+	m.Set(New("interfaces", "eth0", "mtu"), 2)
+	m.Set(New("interfaces", "eth0", "up"), 3)
+	m.Set(New("interfaces", Wildcard, "name"), 4)
+	m.Set(New("interfaces", int64(10)), 5)
+	m.Set(New("system"), 6)
+	m.Set(New(), 7)
+
+	tcases := []struct {
+		prefix   key.Path
+		children key.Path
+	}{
+		{prefix: New(), children: New("interfaces", "system")},
+		{prefix: New("interfaces"), children: New(int64(10), "eth0", "eth1", Wildcard)},
+		{prefix: New("interfaces", "eth0"), children: New("mtu", "up")},
+		{prefix: New("interfaces", Wildcard), children: New("name")},
+		{prefix: New("interfaces", "eth0", "mtu"), children: key.Path{}},
+		{prefix: New("system"), children: key.Path{}},
+		{prefix: New("interfaces", "eth2"), children: nil},
+	}
+	for i, tcase := range tcases {
+		children := m.Children(tcase.prefix)
+		if (children == nil) != (tcase.children == nil) ||
+			!Equal(key.Path(children), tcase.children) {
+			t.Errorf("Test %d failed: Children(%s): expected %v, got %v",
+				i, tcase.prefix, tcase.children, children)
+		}
+	}
+}
+
+func TestMapNode(t *testing.T) {
+	m := Map{}
+	m.Set(New("interfaces", "eth0", "mtu"), int64(1500))
+	m.Set(New("interfaces", "eth1", "mtu"), int64(9000))
+	m.Set(New("interfaces", Wildcard, "name"), "any")
+
+	if !m.SetNode(New("interfaces"), "list") || m.SetNode(New("interfaces"), "container") {
+		t.Error("unexpected result of SetNode")
+	}
+	if !m.SetNode(New("interfaces", Wildcard), "entry") {
+		t.Error("unexpected result of SetNode")
+	}
+	if !m.SetNode(New("system", "config"), "container") {
+		t.Error("unexpected result of SetNode")
+	}
+	m.Set(New("interfaces", "eth0"), "eth0")
+
+	tcases := []struct {
+		prefix key.Path
+		meta   interface{}
+		ok     bool
+	}{
+		{prefix: New("interfaces"), meta: "container", ok: true},
+		{prefix: New("interfaces", Wildcard), meta: "entry", ok: true},
+		{prefix: New("system", "config"), meta: "container", ok: true},
+		{prefix: New("interfaces", "eth0")},
+		{prefix: New("interfaces", "eth0", "mtu")},
+		{prefix: New("system")},
+		{prefix: New("unknown")},
+		{prefix: New()},
+	}
+	for i, tcase := range tcases {
+		meta, ok := m.GetNode(tcase.prefix)
+		if meta != tcase.meta || ok != tcase.ok {
+			t.Errorf("Test %d failed: GetNode(%s): expected %v (%t), got %v (%t)",
+				i, tcase.prefix, tcase.meta, tcase.ok, meta, ok)
+		}
+	}
+
+	// Metadata is distinct from values.
+	if v, ok := m.Get(New("interfaces")); ok {
+		t.Errorf("expected no value for /interfaces, got %v", v)
+	}
+	if v, ok := m.Get(New("interfaces", "eth0")); !ok || v != "eth0" {
+		t.Errorf("expected eth0 for /interfaces/eth0, got %v (%t)", v, ok)
+	}
+	var visited []interface{}
+	_ = m.VisitPrefixed(New(), func(v interface{}) error {
+		visited = append(visited, v)
+		return nil
+	})
+	if len(visited) != 4 {
+		t.Errorf("expected only the 4 values to be visited, got %v", visited)
+	}
+
+	// Deleting values keeps metadata, and the other way around.
+	if !m.Delete(New("interfaces", "eth0")) {
+		t.Error("expected /interfaces/eth0 to be deleted")
+	}
+	if !m.DeleteNode(New("system", "config")) || m.DeleteNode(New("system", "config")) {
+		t.Error("unexpected result of DeleteNode")
+	}
+	if _, ok := m.GetNode(New("system", "config")); ok {
+		t.Error("expected no metadata for /system/config")
+	}
+	m.Delete(New("interfaces", "eth0", "mtu"))
+	m.Delete(New("interfaces", "eth1", "mtu"))
+	m.Delete(New("interfaces", Wildcard, "name"))
+	if meta, ok := m.GetNode(New("interfaces", Wildcard)); !ok || meta != "entry" {
+		t.Errorf("expected the metadata of /interfaces/* to be kept, got %v (%t)", meta, ok)
+	}
+	if m.IsEmpty() {
+		t.Error("expected a Map holding metadata not to be empty")
+	}
+	if n := m.DeletePrefix(New("interfaces")); n != 0 {
+		t.Errorf("expected no values to be deleted, got %d", n)
+	}
+	if !m.IsEmpty() {
+		t.Errorf("expected the Map to be empty, got:\n%s", &m)
+	}
+}
+
+func TestMapSetAll(t *testing.T) {
+	pairs := []PathValue{
+		NewPathValue(1, "a", "b", "c"),
+		NewPathValue(2, "a"),
+		NewPathValue(3, "a", Wildcard, "c"),
+		NewPathValue(4),
+		NewPathValue(5, "a", "b"),
+		NewPathValue(6, "x", "y"),
+		NewPathValue(7, "a", "b", "c"),
+		NewPathValue(8, "a", "b", "d"),
+	}
+	m := Map{}
+	m.Set(New("x", "y"), 0)
+	m.Set(New("x", "z"), 0)
+	if added := m.SetAll(pairs); added != 6 {
+		t.Errorf("expected 6 paths to be added, got %d", added)
+	}
+	expected := Map{}
+	expected.Set(New("x", "z"), 0)
+	for _, pv := range pairs {
+		expected.Set(pv.Path, pv.Value)
+	}
+	if !test.DeepEqual(expected.ToNested(), m.ToNested()) {
+		t.Errorf("expected %s, got %s", expected.String(), m.String())
+	}
+	if v, _ := m.Get(New("a", "b", "c")); v != 7 {
+		t.Errorf("expected the last value of /a/b/c to be registered, got %v", v)
+	}
+	for _, prefix := range []key.Path{New(), New("a"), New("a", "b"), New("x")} {
+		if a, b := m.CountPrefix(prefix), expected.CountPrefix(prefix); a != b {
+			t.Errorf("expected %d paths under %s, got %d", b, prefix, a)
+		}
+	}
+	if !Equal(pairs[0].Path, New("a", "b", "c")) || pairs[0].Value != 1 {
+		t.Errorf("pairs were modified: %v", pairs)
+	}
+	if added := m.SetAll(nil); added != 0 {
+		t.Errorf("expected no path to be added, got %d", added)
+	}
+}
+
 func genWords(count, wordLength int) key.Path {
 	chars := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 	if count+wordLength > len(chars) {
@@ -460,3 +1208,55 @@ func benchmarkPathMap(pathLength, pathDepth int, b *testing.B) {
 func BenchmarkPathMap1x25(b *testing.B)  { benchmarkPathMap(1, 25, b) }
 func BenchmarkPathMap10x50(b *testing.B) { benchmarkPathMap(10, 25, b) }
 func BenchmarkPathMap20x50(b *testing.B) { benchmarkPathMap(20, 25, b) }
+
+// genPathValues returns the pairs of the n^depth paths of length depth
+// made of n different elements, sorted with Compare.
+func genPathValues(n, depth int) []PathValue {
+	words := genWords(n, 10)
+	total := 1
+	for i := 0; i < depth; i++ {
+		total *= n
+	}
+	pairs := make([]PathValue, total)
+	for i := range pairs {
+		p := make(key.Path, depth)
+		for j, r := depth-1, i; j >= 0; j-- {
+			p[j] = words[r%n]
+			r /= n
+		}
+		pairs[i] = WithValue(p, i)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return Compare(pairs[i].Path, pairs[j].Path) < 0 })
+	return pairs
+}
+
+func BenchmarkMapSetAll(b *testing.B) {
+	sorted := genPathValues(10, 4)
+	shuffled := make([]PathValue, len(sorted))
+	copy(shuffled, sorted)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	for _, bench := range []struct {
+		name  string
+		pairs []PathValue
+	}{{"Sorted", sorted}, {"Shuffled", shuffled}} {
+		pairs := bench.pairs
+		b.Run(bench.name+"/Set", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m := Map{}
+				for _, pv := range pairs {
+					m.Set(pv.Path, pv.Value)
+				}
+			}
+		})
+		b.Run(bench.name+"/SetAll", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m := Map{}
+				m.SetAll(pairs)
+			}
+		})
+	}
+}
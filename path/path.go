@@ -3,9 +3,19 @@
 // that can be found in the COPYING file.
 
 // Package path contains methods for dealing with key.Paths.
+//
+// A nil key.Path and an empty key.Path{} both represent the root path,
+// and all the functions of this package treat them the same way: they
+// are equal, they prefix every path and they are both accepted as the
+// root of a Map. Functions returning an empty path may return either,
+// so callers should check for the root path with len(path) == 0.
 package path
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/aristanetworks/goarista/key"
@@ -21,9 +31,13 @@ func New(elements ...interface{}) key.Path {
 // Append appends a variable number of elements to a path.
 // Each element may either be a key.Key or a value that can
 // be wrapped by a key.Key. Note that calling Append on a
-// single path returns that same path, whereas in all other
-// cases a new path is returned.
+// single path returns that same path, unless aliasing checks are
+// enabled with SetAliasingChecks, whereas in all other cases a new
+// path is returned.
 func Append(path key.Path, elements ...interface{}) key.Path {
+	if len(elements) == 0 {
+		return unaliased(path)
+	}
 	return appendElements(path, elements...)
 }
 
@@ -46,14 +60,34 @@ func Join(paths ...key.Path) key.Path {
 }
 
 // Parent returns all but the last element of the path. If
-// the path is empty, Parent returns nil.
+// the path is empty, Parent returns nil. The returned path shares
+// backing with the provided path, unless aliasing checks are
+// enabled with SetAliasingChecks.
 func Parent(path key.Path) key.Path {
 	if len(path) > 0 {
-		return path[:len(path)-1]
+		return unaliased(path[:len(path)-1])
 	}
 	return nil
 }
 
+// Ancestors returns all proper prefixes of the path, from the
+// empty path up to and including Parent(path). The returned
+// paths share backing with the provided path, though their
+// capacity is limited so that appending to one of them doesn't
+// overwrite elements of the others. They are copies if aliasing
+// checks are enabled with SetAliasingChecks. If the path is empty,
+// Ancestors returns nil.
+func Ancestors(path key.Path) []key.Path {
+	if len(path) == 0 {
+		return nil
+	}
+	ancestors := make([]key.Path, len(path))
+	for i := range ancestors {
+		ancestors[i] = unaliased(path[:i:i])
+	}
+	return ancestors
+}
+
 // Base returns the last element of the path. If the path is
 // empty, Base returns nil.
 func Base(path key.Path) key.Key {
@@ -63,6 +97,19 @@ func Base(path key.Path) key.Key {
 	return nil
 }
 
+// At returns the element of the path at index i. A negative i
+// counts from the end of the path, so that At(path, -1) is the last
+// element, like Base(path). If i is out of range, At returns nil.
+func At(path key.Path, i int) key.Key {
+	if i < 0 {
+		i += len(path)
+	}
+	if i < 0 || i >= len(path) {
+		return nil
+	}
+	return path[i]
+}
+
 // Clone returns a new path with the same elements as in the
 // provided path.
 func Clone(path key.Path) key.Path {
@@ -71,6 +118,69 @@ func Clone(path key.Path) key.Path {
 	return result
 }
 
+// CloneCap returns a new path with the same elements as in the
+// provided path, like Clone, but with room for extra more elements:
+// the returned path has a length of len(path) and a capacity of
+// len(path)+extra, so that up to extra elements can be appended to it
+// with the built-in append without reallocating it. Note that Append
+// always returns a new path. A negative extra is treated as 0.
+func CloneCap(path key.Path, extra int) key.Path {
+	if extra < 0 {
+		extra = 0
+	}
+	result := make(key.Path, len(path), len(path)+extra)
+	copy(result, path)
+	return result
+}
+
+// MapElements returns a new path of the same length as the provided
+// path, where each element is the result of calling f on the element
+// of the provided path at the same index.
+func MapElements(path key.Path, f func(key.Key) key.Key) key.Path {
+	result := make(key.Path, len(path))
+	for i, element := range path {
+		result[i] = f(element)
+	}
+	return result
+}
+
+// CommonSuffix returns a new path holding the longest sequence of
+// trailing elements shared by all the provided paths. Calling
+// CommonSuffix with no paths returns nil, and with a single path
+// returns a clone of that path.
+func CommonSuffix(paths ...key.Path) key.Path {
+	if len(paths) == 0 {
+		return nil
+	}
+	first := paths[0]
+	n := len(first)
+	for _, path := range paths[1:] {
+		i := 0
+		for i < n && i < len(path) &&
+			first[len(first)-1-i].Equal(path[len(path)-1-i]) {
+			i++
+		}
+		n = i
+	}
+	return Clone(first[len(first)-n:])
+}
+
+// PathDiff compares path a and path b element by element, and returns
+// the number of leading elements they share along with the remaining
+// elements of each, which start with the first element where the paths
+// diverge. The remaining elements share backing with a and b, unless
+// aliasing checks are enabled with SetAliasingChecks, so they should be
+// cloned before being modified. For instance, PathDiff(/a/b/c, /a/x)
+// returns 1, /b/c and /x, and PathDiff of two equal paths returns their
+// length and two empty paths.
+func PathDiff(a, b key.Path) (commonPrefixLen int, aRest, bRest key.Path) {
+	n := 0
+	for n < len(a) && n < len(b) && a[n].Equal(b[n]) {
+		n++
+	}
+	return n, unaliased(a[n:]), unaliased(b[n:])
+}
+
 // Equal returns whether path a and path b are the same
 // length and whether each element in b corresponds to the
 // same element in a.
@@ -78,6 +188,49 @@ func Equal(a, b key.Path) bool {
 	return len(a) == len(b) && hasPrefix(a, b)
 }
 
+// EqualAt returns whether path a and path b both have an element
+// at index i and whether these elements are the same. If i is out of
+// range of either path, EqualAt returns false.
+func EqualAt(a, b key.Path, i int) bool {
+	return i >= 0 && i < len(a) && i < len(b) && a[i].Equal(b[i])
+}
+
+// Compare returns an integer comparing two paths element by
+// element, using key.Compare to compare elements. The result is 0
+// if a and b are equal, -1 if a sorts before b and +1 if a sorts
+// after b. A path sorts right before all the paths it prefixes,
+// such that in a slice of paths sorted by Compare, all the paths
+// sharing a prefix are contiguous.
+func Compare(a, b key.Path) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := key.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// PrefixRange returns the range [start, end) of indices of the
+// paths in sorted that are prefixed by prefix, using a binary
+// search. The paths in sorted must be sorted according to Compare.
+// If no path is prefixed by prefix, start and end are equal and
+// indicate where such paths would be inserted.
+func PrefixRange(sorted []key.Path, prefix key.Path) (start, end int) {
+	start = sort.Search(len(sorted), func(i int) bool {
+		return Compare(sorted[i], prefix) >= 0
+	})
+	end = start + sort.Search(len(sorted)-start, func(i int) bool {
+		return !HasPrefix(sorted[start+i], prefix)
+	})
+	return start, end
+}
+
 // HasElement returns whether element b exists in path a.
 func HasElement(a key.Path, b key.Key) bool {
 	for _, element := range a {
@@ -96,6 +249,49 @@ func HasPrefix(a, b key.Path) bool {
 	return len(a) >= len(b) && hasPrefix(a, b)
 }
 
+// IsAncestor returns whether path ancestor is an ancestor of path
+// descendant, that is whether it's a prefix of descendant, as
+// determined by HasPrefix(descendant, ancestor). A path is an ancestor
+// of itself; use IsProperAncestor to exclude equal paths.
+func IsAncestor(ancestor, descendant key.Path) bool {
+	return HasPrefix(descendant, ancestor)
+}
+
+// IsDescendant returns whether path descendant is a descendant of
+// path ancestor, that is whether ancestor is a prefix of descendant,
+// as determined by HasPrefix(descendant, ancestor). A path is a
+// descendant of itself; use IsProperDescendant to exclude equal paths.
+func IsDescendant(descendant, ancestor key.Path) bool {
+	return HasPrefix(descendant, ancestor)
+}
+
+// IsProperAncestor is like IsAncestor, but returns false if the paths
+// are equal, so that a proper ancestor is always shorter than its
+// descendants.
+func IsProperAncestor(ancestor, descendant key.Path) bool {
+	return len(ancestor) < len(descendant) && hasPrefix(descendant, ancestor)
+}
+
+// IsProperDescendant is like IsDescendant, but returns false if the
+// paths are equal, so that a proper descendant is always longer than
+// its ancestors.
+func IsProperDescendant(descendant, ancestor key.Path) bool {
+	return IsProperAncestor(ancestor, descendant)
+}
+
+// HasAnyPrefix returns the index of the first of the provided
+// prefixes that is a prefix of path a, as determined by HasPrefix,
+// and true. If none of the prefixes is a prefix of a, HasAnyPrefix
+// returns -1 and false.
+func HasAnyPrefix(a key.Path, prefixes ...key.Path) (int, bool) {
+	for i, prefix := range prefixes {
+		if HasPrefix(a, prefix) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // Match returns whether path a and path b are the same
 // length and whether each element in b corresponds to the
 // same element or a wildcard in a.
@@ -103,6 +299,65 @@ func Match(a, b key.Path) bool {
 	return len(a) == len(b) && matchPrefix(a, b)
 }
 
+// CaptureMatch returns whether candidate matches template, as
+// Match(template, candidate) does, and if so the elements of candidate
+// found at the positions of the wildcards of template, indexed by the
+// position of each wildcard in template. If candidate does not match
+// template, CaptureMatch returns nil and false.
+func CaptureMatch(template, candidate key.Path) (map[int]key.Key, bool) {
+	if !Match(template, candidate) {
+		return nil, false
+	}
+	captures := make(map[int]key.Key)
+	for i, element := range template {
+		if element.Equal(Wildcard) {
+			captures[i] = candidate[i]
+		}
+	}
+	return captures, true
+}
+
+// MatchMasked is like Match(template, candidate), except that the
+// elements of template at the positions set in mask are treated as
+// wildcards, whatever their value: the element at index i is a
+// wildcard if the bit 1<<i of mask is set. This allows paths with
+// wildcards to be stored compactly as a concrete path and a mask.
+// Since mask only has 64 bits, the elements of template from index 64
+// can't be masked, and are compared as with Match; MatchMaskedBools
+// supports masks of any length.
+func MatchMasked(template key.Path, mask uint64, candidate key.Path) bool {
+	if len(template) != len(candidate) {
+		return false
+	}
+	for i := range template {
+		if i < 64 && mask&(1<<uint(i)) != 0 {
+			continue
+		}
+		if !template[i].Equal(Wildcard) && !candidate[i].Equal(template[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchMaskedBools is like MatchMasked, but the element of template at
+// index i is treated as a wildcard if mask[i] is true. The elements of
+// template past the end of mask are compared as with Match.
+func MatchMaskedBools(template key.Path, mask []bool, candidate key.Path) bool {
+	if len(template) != len(candidate) {
+		return false
+	}
+	for i := range template {
+		if i < len(mask) && mask[i] {
+			continue
+		}
+		if !template[i].Equal(Wildcard) && !candidate[i].Equal(template[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // MatchPrefix returns whether path b is a prefix of path a
 // where path a may contain wildcards.
 // It checks that b is at most the length of path a and
@@ -112,6 +367,136 @@ func MatchPrefix(a, b key.Path) bool {
 	return len(a) >= len(b) && matchPrefix(a, b)
 }
 
+// MatchPrefixCoerce is like MatchPrefix, but compares elements with
+// key.CoerceEqual, such that elements wrapping numbers of different
+// types, like float64(1) and int64(1), match if they have the same
+// numerical value.
+func MatchPrefixCoerce(a, b key.Path) bool {
+	if len(a) < len(b) {
+		return false
+	}
+	for i := range b {
+		if !a[i].Equal(Wildcard) && !key.CoerceEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualIgnoringWildcards returns whether path a and path b are the
+// same length and whether each element in a corresponds to the same
+// element in b, or to a wildcard, or is itself a wildcard. Unlike
+// Match, it allows wildcards in both paths and is symmetric, such
+// that it can tell whether two paths with wildcards overlap.
+func EqualIgnoringWildcards(a, b key.Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(Wildcard) && !b[i].Equal(Wildcard) && !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualIgnoringTrailingWildcard returns whether path a and path b are
+// equal once their trailing wildcards are ignored, such that a path
+// ending with a wildcard, which designates the children of a
+// container, equals the path of the container. All the trailing
+// wildcards of both paths are ignored, so /a/b/*/* equals /a/b and
+// /a/b/*, while wildcards elsewhere must match exactly: /a/*/c doesn't
+// equal /a/b/c.
+func EqualIgnoringTrailingWildcard(a, b key.Path) bool {
+	return Equal(trimTrailingWildcards(a), trimTrailingWildcards(b))
+}
+
+// trimTrailingWildcards returns path without its trailing wildcards.
+func trimTrailingWildcards(path key.Path) key.Path {
+	n := len(path)
+	for n > 0 && path[n-1].Equal(Wildcard) {
+		n--
+	}
+	return path[:n]
+}
+
+// TrimWildcardPrefix returns the provided path without its leading
+// wildcards, starting at its first element that isn't a wildcard. The
+// returned path shares backing with the provided path, unless aliasing
+// checks are enabled with SetAliasingChecks, so it should be cloned
+// before being modified. A path holding only wildcards yields an empty
+// path.
+func TrimWildcardPrefix(path key.Path) key.Path {
+	i := 0
+	for i < len(path) && path[i].Equal(Wildcard) {
+		i++
+	}
+	return unaliased(path[i:])
+}
+
+// StripWildcards returns a new path holding the elements of the
+// provided path that aren't wildcards, in the same order. The
+// returned path is never nil, even when all the elements of the
+// provided path are wildcards.
+func StripWildcards(path key.Path) key.Path {
+	result := make(key.Path, 0, len(path))
+	for _, element := range path {
+		if !element.Equal(Wildcard) {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// GroupByPrefix groups paths by their first depth elements. Paths
+// shorter than depth are grouped under all their elements, and a
+// negative depth is treated as 0. The groups are indexed by the
+// canonical string representation of their prefix, as returned by
+// key.KeyString for a key wrapping the prefix, such that prefixes with
+// elements of different types, like int64(1) and "1", never share a
+// group. Paths keep their relative order within a group.
+func GroupByPrefix(paths []key.Path, depth int) map[string][]key.Path {
+	if depth < 0 {
+		depth = 0
+	}
+	groups := make(map[string][]key.Path)
+	for _, path := range paths {
+		prefix := path
+		if len(prefix) > depth {
+			prefix = prefix[:depth]
+		}
+		k := key.KeyString(key.New(prefix))
+		groups[k] = append(groups[k], path)
+	}
+	return groups
+}
+
+// MatchAnyPrefix is like HasAnyPrefix, but uses MatchPrefix
+// instead of HasPrefix, such that path a may contain wildcards.
+func MatchAnyPrefix(a key.Path, prefixes ...key.Path) (int, bool) {
+	for i, prefix := range prefixes {
+		if MatchPrefix(a, prefix) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// MatchPrefixDepth is like MatchPrefix, but only the first
+// maxDepth elements of path b are considered. If maxDepth is
+// greater than the length of b, it behaves exactly like
+// MatchPrefix. A negative maxDepth is treated as zero, in
+// which case every path a matches.
+func MatchPrefixDepth(a, b key.Path, maxDepth int) bool {
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	if maxDepth < len(b) {
+		b = b[:maxDepth]
+	}
+	return MatchPrefix(a, b)
+}
+
 // FromString constructs a path from the elements resulting
 // from a split of the input string by "/". Strings that do
 // not lead with a '/' are accepted but not reconstructable
@@ -131,6 +516,221 @@ func FromString(str string) key.Path {
 	return result
 }
 
+// FromStringStrict is like FromString, but returns an error if str
+// is not an absolute path string, such as those returned by
+// key.Path.String. str must lead with a '/', and it must not hold
+// empty elements, as it would with consecutive '/' or a trailing '/'.
+// Only "/" is treated as a key.Path{}.
+func FromStringStrict(str string) (key.Path, error) {
+	if str == "" {
+		return nil, errors.New("empty path string")
+	} else if str[0] != '/' {
+		return nil, fmt.Errorf("path %q does not start with '/'", str)
+	} else if str == "/" {
+		return key.Path{}, nil
+	}
+	elements := strings.Split(str[1:], "/")
+	result := make(key.Path, len(elements))
+	for i, element := range elements {
+		if element == "" {
+			return nil, fmt.Errorf("path %q has an empty element at index %d", str, i)
+		}
+		result[i] = key.New(element)
+	}
+	return result, nil
+}
+
+// FromQuotedString constructs a path from a string produced by
+// key.Path.QuotedString. Elements are separated by "/", and elements
+// starting with a '"' are unquoted as Go string literals, so they may
+// contain any character, including '/'. As with FromString, elements
+// are always parsed as strings, strings that do not lead with a '/'
+// are accepted and both "" and "/" are treated as a key.Path{}. An
+// error is returned if a quoted element is malformed or followed by
+// anything other than a '/'.
+func FromQuotedString(str string) (key.Path, error) {
+	tokens, err := Tokenize(str)
+	if err != nil {
+		return nil, err
+	}
+	result := make(key.Path, len(tokens))
+	for i, token := range tokens {
+		result[i] = key.New(token.Element)
+	}
+	return result, nil
+}
+
+// Token is an element of a path string, as returned by Tokenize.
+type Token struct {
+	// Element is the element, unquoted if it was quoted.
+	Element string
+	// Start and End are the byte offsets of the element in the
+	// path string, such that str[Start:End] is the element as it
+	// appears in str, including its quotes if it was quoted.
+	Start, End int
+}
+
+// Tokenize splits a path string into its elements, along with their
+// positions in str, following the syntax parsed by FromQuotedString.
+// This allows reporting which element of str is at fault when the
+// path it represents turns out to be invalid. Both "" and "/" yield
+// no tokens. The error returned for a malformed quoted element gives
+// its offset in str.
+func Tokenize(str string) ([]Token, error) {
+	if str == "" || str == "/" {
+		return []Token{}, nil
+	}
+	var tokens []Token
+	i := 0
+	if str[0] == '/' {
+		i = 1
+	}
+	for {
+		token := Token{Start: i}
+		if i < len(str) && str[i] == '"' {
+			end := i + 1
+			for end < len(str) && str[end] != '"' {
+				if str[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(str) {
+				return nil, fmt.Errorf("unterminated quoted element at offset %d in %q", i, str)
+			}
+			var err error
+			if token.Element, err = strconv.Unquote(str[i : end+1]); err != nil {
+				return nil, fmt.Errorf("invalid quoted element %s at offset %d in %q: %s",
+					str[i:end+1], i, str, err)
+			}
+			i = end + 1
+			if i < len(str) && str[i] != '/' {
+				return nil, fmt.Errorf("unexpected character %q at offset %d in %q",
+					str[i], i, str)
+			}
+		} else {
+			end := strings.IndexByte(str[i:], '/')
+			if end < 0 {
+				end = len(str) - i
+			}
+			token.Element = str[i : i+end]
+			i += end
+		}
+		token.End = i
+		tokens = append(tokens, token)
+		if i == len(str) {
+			return tokens, nil
+		}
+		i++
+	}
+}
+
+// ToJSONPointer returns the path as a JSON Pointer, as defined by
+// RFC 6901, where each element is a reference token. Any '~' within an
+// element is escaped as "~0" and any '/' as "~1". The empty path is
+// returned as "", which refers to a whole JSON document.
+func ToJSONPointer(path key.Path) string {
+	var b strings.Builder
+	for _, element := range path {
+		b.WriteByte('/')
+		s, err := key.StringifyInterface(element.Key())
+		if err != nil {
+			panic(fmt.Errorf("unable to stringify %#v: %s", element, err))
+		}
+		b.WriteString(jsonPointerEscaper.Replace(s))
+	}
+	return b.String()
+}
+
+// FromJSONPointer constructs a path from a JSON Pointer, as defined
+// by RFC 6901, unescaping "~1" as '/' and "~0" as '~' in each of its
+// reference tokens. As with FromString, pointers that do not lead with
+// a '/' are accepted. Unlike with FromString, "" is treated as a
+// key.Path{} but "/" is a path holding one empty element.
+func FromJSONPointer(pointer string) key.Path {
+	if pointer == "" {
+		return key.Path{}
+	} else if pointer[0] == '/' {
+		pointer = pointer[1:]
+	}
+	tokens := strings.Split(pointer, "/")
+	result := make(key.Path, len(tokens))
+	for i, token := range tokens {
+		result[i] = key.New(jsonPointerUnescaper.Replace(token))
+	}
+	return result
+}
+
+var (
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// StringSep returns the path as an absolute path string using sep
+// as the separator between elements, instead of the "/" used by
+// key.Path.String. Any byte within an element that is either the
+// escape character '\' or the first byte of sep is escaped with a
+// preceding '\', such that FromStringSep reconstructs the original
+// elements. StringSep panics if sep is empty or contains '\'.
+func StringSep(path key.Path, sep string) string {
+	checkSep(sep)
+	if len(path) == 0 {
+		return sep
+	}
+	var b strings.Builder
+	for _, element := range path {
+		b.WriteString(sep)
+		s, err := key.StringifyInterface(element.Key())
+		if err != nil {
+			panic(fmt.Errorf("unable to stringify %#v: %s", element, err))
+		}
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' || s[i] == sep[0] {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// FromStringSep is like FromString, but splits str on unescaped
+// occurrences of sep rather than on "/", and unescapes elements
+// escaped by StringSep. Strings that do not lead with sep are
+// accepted, and both "" and sep are treated as a key.Path{}.
+// FromStringSep panics if sep is empty or contains '\'.
+func FromStringSep(str, sep string) key.Path {
+	checkSep(sep)
+	if str == "" || str == sep {
+		return key.Path{}
+	}
+	str = strings.TrimPrefix(str, sep)
+	var (
+		result key.Path
+		b      strings.Builder
+	)
+	for i := 0; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && i+1 < len(str):
+			i++
+			b.WriteByte(str[i])
+		case strings.HasPrefix(str[i:], sep):
+			result = append(result, key.New(b.String()))
+			b.Reset()
+			i += len(sep) - 1
+		default:
+			b.WriteByte(str[i])
+		}
+	}
+	return append(result, key.New(b.String()))
+}
+
+func checkSep(sep string) {
+	if sep == "" || strings.ContainsRune(sep, '\\') {
+		panic(fmt.Sprintf("invalid path separator: %q", sep))
+	}
+}
+
 // appendElements makes a copy of dest when elements is non-empty and
 // then appends elements to the copy and returns it.
 func appendElements(dest key.Path, elements ...interface{}) key.Path {
@@ -163,7 +763,26 @@ func appendElements(dest key.Path, elements ...interface{}) key.Path {
 	return dest
 }
 
+// hasPrefix returns whether a, which must be at least as long as b,
+// starts with the elements of b. Paths of up to four elements, the
+// most common ones, are compared without a loop, starting from their
+// last elements, where paths sharing a prefix usually differ.
 func hasPrefix(a, b key.Path) bool {
+	switch len(b) {
+	case 0:
+		return true
+	case 1:
+		return b[0].Equal(a[0])
+	case 2:
+		a = a[:2]
+		return b[1].Equal(a[1]) && b[0].Equal(a[0])
+	case 3:
+		a = a[:3]
+		return b[2].Equal(a[2]) && b[1].Equal(a[1]) && b[0].Equal(a[0])
+	case 4:
+		a = a[:4]
+		return b[3].Equal(a[3]) && b[2].Equal(a[2]) && b[1].Equal(a[1]) && b[0].Equal(a[0])
+	}
 	for i := range b {
 		if !b[i].Equal(a[i]) {
 			return false
@@ -172,11 +791,40 @@ func hasPrefix(a, b key.Path) bool {
 	return true
 }
 
+// matchPrefix returns whether the elements of a, which must be at
+// least as long as b, match those of b. Elements are compared before
+// checking for a wildcard, since most elements are equal when paths
+// match, and paths of up to four elements are compared without a loop.
 func matchPrefix(a, b key.Path) bool {
+	switch len(b) {
+	case 0:
+		return true
+	case 1:
+		return matchElement(a[0], b[0])
+	case 2:
+		a = a[:2]
+		return matchElement(a[0], b[0]) && matchElement(a[1], b[1])
+	case 3:
+		a = a[:3]
+		return matchElement(a[0], b[0]) && matchElement(a[1], b[1]) &&
+			matchElement(a[2], b[2])
+	case 4:
+		a = a[:4]
+		return matchElement(a[0], b[0]) && matchElement(a[1], b[1]) &&
+			matchElement(a[2], b[2]) && matchElement(a[3], b[3])
+	}
 	for i := range b {
-		if !a[i].Equal(Wildcard) && !b[i].Equal(a[i]) {
+		if !matchElement(a[i], b[i]) {
 			return false
 		}
 	}
 	return true
 }
+
+// matchElement returns whether element b is matched by element a,
+// that is whether they're equal or a is a wildcard. Comparing a to
+// Wildcard with == first is cheaper than calling Equal, and can't
+// panic since Wildcard is of a comparable type.
+func matchElement(a, b key.Key) bool {
+	return a == Wildcard || b.Equal(a) || a.Equal(Wildcard)
+}
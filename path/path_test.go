@@ -6,6 +6,7 @@ package path
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/aristanetworks/goarista/key"
@@ -79,6 +80,33 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestCloneCap(t *testing.T) {
+	a := key.Path{key.New("foo"), key.New("bar")}
+	for _, extra := range []int{-1, 0, 3} {
+		b := CloneCap(a, extra)
+		expectedCap := len(a)
+		if extra > 0 {
+			expectedCap += extra
+		}
+		if !Equal(a, b) || len(b) != len(a) || cap(b) != expectedCap {
+			t.Errorf("CloneCap(%v, %d) returned %v with capacity %d", a, extra, b, cap(b))
+		}
+		b[1] = key.New("baz")
+		if !Equal(a, key.Path{key.New("foo"), key.New("bar")}) {
+			t.Errorf("CloneCap(%v, %d) is not making a copied path", a, extra)
+		}
+	}
+	b := CloneCap(a, 2)
+	c := append(b, key.New("baz"))
+	d := append(c, key.New("qux"))
+	if &b[0] != &c[0] || &c[0] != &d[0] {
+		t.Error("expected appending to a path returned by CloneCap not to reallocate it")
+	}
+	if len(CloneCap(nil, 2)) != 0 || cap(CloneCap(nil, 2)) != 2 {
+		t.Error("expected CloneCap(nil, 2) to return an empty path with a capacity of 2")
+	}
+}
+
 func TestAppend(t *testing.T) {
 	tcases := []struct {
 		a      key.Path
@@ -184,6 +212,49 @@ func TestParent(t *testing.T) {
 	}
 }
 
+func TestAncestors(t *testing.T) {
+	if Ancestors(key.Path{}) != nil {
+		t.Fatal("Ancestors of empty key.Path should be nil")
+	}
+	tcases := []struct {
+		in  key.Path
+		out []key.Path
+	}{
+		{
+			in:  key.Path{key.New("foo")},
+			out: []key.Path{key.Path{}},
+		}, {
+			in: key.Path{key.New("foo"), key.New("bar"), key.New("baz")},
+			out: []key.Path{
+				key.Path{},
+				key.Path{key.New("foo")},
+				key.Path{key.New("foo"), key.New("bar")},
+			},
+		},
+	}
+	for _, tcase := range tcases {
+		ancestors := Ancestors(tcase.in)
+		if len(ancestors) != len(tcase.out) {
+			t.Fatalf("Ancestors of %#v: expected %d paths, got %d",
+				tcase.in, len(tcase.out), len(ancestors))
+		}
+		for i, ancestor := range ancestors {
+			if !Equal(ancestor, tcase.out[i]) {
+				t.Fatalf("Ancestors of %#v: %#v != %#v", tcase.in, ancestor, tcase.out[i])
+			}
+			if len(ancestor) > 0 && &ancestor[0] != &tcase.in[0] && !aliasingChecksEnabled() {
+				t.Fatalf("Ancestors of %#v: %#v does not share backing", tcase.in, ancestor)
+			}
+		}
+	}
+
+	p := key.Path{key.New("foo"), key.New("bar")}
+	_ = append(Ancestors(p)[1], key.New("baz"))
+	if !Equal(p, key.Path{key.New("foo"), key.New("bar")}) {
+		t.Fatalf("appending to an ancestor modified the original path: %#v", p)
+	}
+}
+
 func TestBase(t *testing.T) {
 	if Base(key.Path{}) != nil {
 		t.Fatal("Base of empty key.Path should be nil")
@@ -207,6 +278,259 @@ func TestBase(t *testing.T) {
 	}
 }
 
+func TestAt(t *testing.T) {
+	p := New("foo", "bar", "baz")
+	tcases := []struct {
+		i   int
+		out key.Key
+	}{
+		{i: 0, out: key.New("foo")},
+		{i: 1, out: key.New("bar")},
+		{i: 2, out: key.New("baz")},
+		{i: 3, out: nil},
+		{i: -1, out: key.New("baz")},
+		{i: -2, out: key.New("bar")},
+		{i: -3, out: key.New("foo")},
+		{i: -4, out: nil},
+	}
+	for _, tcase := range tcases {
+		out := At(p, tcase.i)
+		if tcase.out == nil {
+			if out != nil {
+				t.Errorf("At(%s, %d): expected nil, got %#v", p, tcase.i, out)
+			}
+		} else if out == nil || !out.Equal(tcase.out) {
+			t.Errorf("At(%s, %d): expected %#v, got %#v", p, tcase.i, tcase.out, out)
+		}
+	}
+	if !At(p, -1).Equal(Base(p)) {
+		t.Errorf("expected At(%s, -1) to equal Base(%s)", p, p)
+	}
+	for _, i := range []int{0, -1} {
+		if out := At(nil, i); out != nil {
+			t.Errorf("At(nil, %d): expected nil, got %#v", i, out)
+		}
+	}
+}
+
+func TestMapElements(t *testing.T) {
+	lower := func(k key.Key) key.Key {
+		if s, ok := k.Key().(string); ok {
+			return key.New(strings.ToLower(s))
+		}
+		return k
+	}
+	tcases := []struct {
+		in  key.Path
+		out key.Path
+	}{
+		{
+			in:  key.Path{},
+			out: key.Path{},
+		}, {
+			in:  New("Interfaces", "ETHERNET1", "state"),
+			out: New("interfaces", "ethernet1", "state"),
+		}, {
+			in:  New("Foo", int32(1), Wildcard, "BAR"),
+			out: New("foo", int32(1), Wildcard, "bar"),
+		},
+	}
+	for i, tcase := range tcases {
+		if out := MapElements(tcase.in, lower); !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: %#v != %#v", i, out, tcase.out)
+		}
+	}
+
+	in := New("Foo", "Bar")
+	out := MapElements(in, func(k key.Key) key.Key { return k })
+	out[0] = key.New("baz")
+	if !Equal(in, New("Foo", "Bar")) {
+		t.Error("MapElements is not returning a new path")
+	}
+}
+
+func TestNilAndEmptyPath(t *testing.T) {
+	empty := key.Path{}
+	foo := New("foo")
+	paths := []key.Path{nil, empty}
+	for _, a := range paths {
+		for _, b := range paths {
+			if !Equal(a, b) || !HasPrefix(a, b) || !Match(a, b) || !MatchPrefix(a, b) {
+				t.Errorf("expected %#v and %#v to be equal", a, b)
+			}
+			if Compare(a, b) != 0 || !EqualIgnoringWildcards(a, b) {
+				t.Errorf("expected %#v and %#v to compare equal", a, b)
+			}
+			if !a.Equal(b) || !key.New(a).Equal(key.New(b)) {
+				t.Errorf("expected %#v and %#v to be equal", a, b)
+			}
+			if len(Join(a, b)) != 0 || len(CommonSuffix(a, b)) != 0 {
+				t.Errorf("expected joining %#v and %#v to yield an empty path", a, b)
+			}
+			if len(Append(a)) != 0 || !Equal(Append(a, "foo"), foo) {
+				t.Errorf("unexpected result of appending to %#v", a)
+			}
+		}
+		if !HasPrefix(foo, a) || !MatchPrefix(foo, a) || HasPrefix(a, foo) {
+			t.Errorf("expected %#v to prefix %#v", a, foo)
+		}
+		if Equal(a, foo) || Compare(a, foo) != -1 || Compare(foo, a) != 1 {
+			t.Errorf("expected %#v to sort before %#v", a, foo)
+		}
+		if !Equal(Join(a, foo, a), foo) {
+			t.Errorf("expected joining %#v to %#v to yield %#v", a, foo, foo)
+		}
+		if Parent(a) != nil || Base(a) != nil || Ancestors(a) != nil {
+			t.Errorf("unexpected parent, base or ancestors of %#v", a)
+		}
+		if len(Clone(a)) != 0 || len(MapElements(a, nil)) != 0 {
+			t.Errorf("expected copies of %#v to be empty", a)
+		}
+		if s := a.String(); s != "/" {
+			t.Errorf("expected %#v to be %q, got %q", a, "/", s)
+		}
+		if s := StringSep(a, "."); s != "." {
+			t.Errorf("expected %#v to be %q, got %q", a, ".", s)
+		}
+		if !Equal(FromString(a.String()), a) {
+			t.Errorf("round trip of %#v via FromString failed", a)
+		}
+		if err := Validate(a); err != nil {
+			t.Errorf("unexpected error validating %#v: %s", a, err)
+		}
+		if i, ok := HasAnyPrefix(foo, a); i != 0 || !ok {
+			t.Errorf("expected %#v to be a prefix of %#v", a, foo)
+		}
+	}
+
+	m := key.NewMap(key.New(key.Path(nil)), 1)
+	if v, ok := m.Get(key.New(empty)); !ok || v != 1 {
+		t.Errorf("expected to find the root path in key.Map, got %v, %t", v, ok)
+	}
+	var pm Map
+	pm.Set(nil, 1)
+	if v, ok := pm.Get(empty); !ok || v != 1 {
+		t.Errorf("expected to find the root path in Map, got %v, %t", v, ok)
+	}
+	if pm.Set(empty, 2) {
+		t.Error("expected nil and empty paths to be registered as the same path")
+	}
+	if n := pm.DeletePrefix(nil); n != 1 || !pm.IsEmpty() {
+		t.Errorf("expected 1 deletion leaving an empty Map, got %d:\n%s", n, pm.String())
+	}
+}
+
+func TestPathDiff(t *testing.T) {
+	tcases := []struct {
+		a, b         key.Path
+		common       int
+		aRest, bRest key.Path
+	}{{
+		a:      nil,
+		b:      nil,
+		common: 0,
+	}, {
+		a:      New("foo", "bar"),
+		b:      New("foo", "bar"),
+		common: 2,
+	}, {
+		a:      New("foo"),
+		b:      New("foo", "bar", "baz"),
+		common: 1,
+		bRest:  New("bar", "baz"),
+	}, {
+		a:      New("foo", "bar", "baz"),
+		b:      New("foo"),
+		common: 1,
+		aRest:  New("bar", "baz"),
+	}, {
+		a:      New("foo", "bar", "baz"),
+		b:      New("foo", "qux", "baz"),
+		common: 1,
+		aRest:  New("bar", "baz"),
+		bRest:  New("qux", "baz"),
+	}, {
+		a:      New("foo", "bar"),
+		b:      New("qux", "bar"),
+		common: 0,
+		aRest:  New("foo", "bar"),
+		bRest:  New("qux", "bar"),
+	}, {
+		a:      New(int64(1)),
+		b:      New("1"),
+		common: 0,
+		aRest:  New(int64(1)),
+		bRest:  New("1"),
+	}, {
+		a:      New(Wildcard, "foo"),
+		b:      New("bar", "foo"),
+		common: 0,
+		aRest:  New(Wildcard, "foo"),
+		bRest:  New("bar", "foo"),
+	}}
+	for i, tcase := range tcases {
+		common, aRest, bRest := PathDiff(tcase.a, tcase.b)
+		if common != tcase.common || !Equal(aRest, tcase.aRest) ||
+			!Equal(bRest, tcase.bRest) {
+			t.Errorf("Test %d failed: expected %d, %s, %s, got %d, %s, %s", i,
+				tcase.common, tcase.aRest, tcase.bRest, common, aRest, bRest)
+		}
+	}
+}
+
+func TestCommonSuffix(t *testing.T) {
+	if CommonSuffix() != nil {
+		t.Fatal("CommonSuffix of no paths should be nil")
+	}
+	tcases := []struct {
+		in  []key.Path
+		out key.Path
+	}{
+		{
+			in:  []key.Path{New("foo", "bar")},
+			out: New("foo", "bar"),
+		}, {
+			in:  []key.Path{New("foo", "bar"), New("foo", "bar")},
+			out: New("foo", "bar"),
+		}, {
+			in:  []key.Path{New("foo", "bar"), New("bar", "foo")},
+			out: key.Path{},
+		}, {
+			in:  []key.Path{New("foo", "bar"), New()},
+			out: key.Path{},
+		}, {
+			in: []key.Path{
+				New("interfaces", "Ethernet1", "state", "counters", "in-octets"),
+				New("interfaces", "Ethernet2", "state", "counters", "in-octets"),
+				New("subinterfaces", "state", "counters", "in-octets"),
+			},
+			out: New("state", "counters", "in-octets"),
+		}, {
+			in: []key.Path{
+				New("a", "b", "c"),
+				New("b", "c"),
+				New("x", "a", "b", "c"),
+			},
+			out: New("b", "c"),
+		}, {
+			in:  []key.Path{New("a", Wildcard), New("b", Wildcard), New("c", "d")},
+			out: key.Path{},
+		},
+	}
+	for i, tcase := range tcases {
+		if p := CommonSuffix(tcase.in...); !Equal(p, tcase.out) {
+			t.Fatalf("Test %d failed: %#v != %#v", i, p, tcase.out)
+		}
+	}
+
+	a := New("foo", "bar")
+	b := CommonSuffix(a)
+	b[1] = key.New("baz")
+	if !Equal(a, New("foo", "bar")) {
+		t.Error("CommonSuffix is not returning a copied path")
+	}
+}
+
 type customKey struct {
 	i *int
 }
@@ -373,6 +697,153 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestEqualAt(t *testing.T) {
+	tcases := []struct {
+		a      key.Path
+		b      key.Path
+		i      int
+		result bool
+	}{
+		{a: nil, b: nil, i: 0, result: false},
+		{a: New("foo"), b: New("foo"), i: 0, result: true},
+		{a: New("foo"), b: New("foo"), i: 1, result: false},
+		{a: New("foo"), b: New("foo"), i: -1, result: false},
+		{a: New("foo", "bar"), b: New("baz", "bar"), i: 0, result: false},
+		{a: New("foo", "bar"), b: New("baz", "bar"), i: 1, result: true},
+		{a: New("foo", "bar"), b: New("foo"), i: 1, result: false},
+		{a: New("foo"), b: New("foo", "bar"), i: 1, result: false},
+		{a: New("foo", int32(1)), b: New("foo", int64(1)), i: 1, result: false},
+		{a: New(Wildcard), b: New("foo"), i: 0, result: false},
+		{a: New(Wildcard), b: New(Wildcard), i: 0, result: true},
+		{
+			a:      New(map[string]interface{}{"a": 1}),
+			b:      New(map[string]interface{}{"a": 1}),
+			i:      0,
+			result: true,
+		},
+	}
+	for i, tcase := range tcases {
+		if result := EqualAt(tcase.a, tcase.b, tcase.i); result != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, i: %d, result: %t, expected: %t",
+				i, tcase.a, tcase.b, tcase.i, result, tcase.result)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Each path must sort strictly before the ones that follow it.
+	ordered := []key.Path{
+		key.Path{},
+		key.Path{key.New(false)},
+		key.Path{key.New(int64(-1))},
+		key.Path{key.New(uint8(1))},
+		key.Path{key.New("")},
+		key.Path{key.New("foo")},
+		key.Path{key.New("foo"), key.New(int8(1))},
+		key.Path{key.New("foo"), key.New("bar")},
+		key.Path{key.New("foo"), key.New("bar"), key.New("baz")},
+		key.Path{key.New("foo"), key.New("baz")},
+		key.Path{key.New("foo"), Wildcard},
+		key.Path{key.New("foobar")},
+		key.Path{key.New(map[string]interface{}{"a": "b"})},
+		key.Path{Wildcard},
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			var expected int
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if c := Compare(a, b); c != expected {
+				t.Errorf("Compare(%v, %v): expected %d, got %d", a, b, expected, c)
+			}
+		}
+	}
+	if c := Compare(nil, key.Path{}); c != 0 {
+		t.Errorf("Compare(nil, key.Path{}): expected 0, got %d", c)
+	}
+}
+
+func TestPrefixRange(t *testing.T) {
+	sorted := []key.Path{
+		New("a"),
+		New("a", "b"),
+		New("a", "b", "c"),
+		New("a", "b", "d"),
+		New("a", "c"),
+		New("b"),
+		New("b", "a"),
+		New("c", "a"),
+	}
+	tcases := []struct {
+		sorted []key.Path
+		prefix key.Path
+		start  int
+		end    int
+	}{
+		{
+			sorted: nil,
+			prefix: New("a"),
+			start:  0,
+			end:    0,
+		}, {
+			sorted: sorted,
+			prefix: New(),
+			start:  0,
+			end:    8,
+		}, {
+			sorted: sorted,
+			prefix: New("a"),
+			start:  0,
+			end:    5,
+		}, {
+			sorted: sorted,
+			prefix: New("a", "b"),
+			start:  1,
+			end:    4,
+		}, {
+			sorted: sorted,
+			prefix: New("a", "b", "d"),
+			start:  3,
+			end:    4,
+		}, {
+			sorted: sorted,
+			prefix: New("b"),
+			start:  5,
+			end:    7,
+		}, {
+			sorted: sorted,
+			prefix: New("c"),
+			start:  7,
+			end:    8,
+		}, {
+			sorted: sorted,
+			prefix: New("a", "a"),
+			start:  1,
+			end:    1,
+		}, {
+			sorted: sorted,
+			prefix: New("a", "b", "c", "d"),
+			start:  3,
+			end:    3,
+		}, {
+			sorted: sorted,
+			prefix: New("d"),
+			start:  8,
+			end:    8,
+		},
+	}
+	for i, tcase := range tcases {
+		start, end := PrefixRange(tcase.sorted, tcase.prefix)
+		if start != tcase.start || end != tcase.end {
+			t.Errorf("Test %d failed: expected [%d, %d), got [%d, %d)",
+				i, tcase.start, tcase.end, start, end)
+		}
+	}
+}
+
 func TestHasElement(t *testing.T) {
 	tcases := []struct {
 		a      key.Path
@@ -489,6 +960,444 @@ func TestHasPrefix(t *testing.T) {
 	}
 }
 
+func TestCaptureMatch(t *testing.T) {
+	tcases := []struct {
+		template  key.Path
+		candidate key.Path
+		captures  map[int]key.Key
+		ok        bool
+	}{
+		{
+			template:  key.Path{},
+			candidate: key.Path{},
+			captures:  map[int]key.Key{},
+			ok:        true,
+		}, {
+			template:  New("foo", "bar"),
+			candidate: New("foo", "bar"),
+			captures:  map[int]key.Key{},
+			ok:        true,
+		}, {
+			template:  New("interfaces", Wildcard, "state"),
+			candidate: New("interfaces", "Ethernet1", "state"),
+			captures:  map[int]key.Key{1: key.New("Ethernet1")},
+			ok:        true,
+		}, {
+			template:  New(Wildcard, "b", Wildcard, Wildcard),
+			candidate: New("a", "b", int32(1), map[string]interface{}{"k": "v"}),
+			captures: map[int]key.Key{
+				0: key.New("a"),
+				2: key.New(int32(1)),
+				3: key.New(map[string]interface{}{"k": "v"}),
+			},
+			ok: true,
+		}, {
+			template:  New("interfaces", Wildcard, "state"),
+			candidate: New("interfaces", "Ethernet1", "config"),
+		}, {
+			template:  New("interfaces", Wildcard, "state"),
+			candidate: New("interfaces", "Ethernet1"),
+		}, {
+			template:  New("interfaces", Wildcard),
+			candidate: New("interfaces", "Ethernet1", "state"),
+		}, {
+			template:  New("interfaces", "Ethernet1"),
+			candidate: New("interfaces", Wildcard),
+		},
+	}
+	for i, tcase := range tcases {
+		captures, ok := CaptureMatch(tcase.template, tcase.candidate)
+		if ok != tcase.ok {
+			t.Errorf("Test %d failed: expected %t, got %t", i, tcase.ok, ok)
+		}
+		if !tcase.ok && captures != nil {
+			t.Errorf("Test %d failed: expected nil captures, got %v", i, captures)
+		}
+		if len(captures) != len(tcase.captures) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, tcase.captures, captures)
+		}
+		for j, element := range tcase.captures {
+			if !element.Equal(captures[j]) {
+				t.Errorf("Test %d failed: expected %v at %d, got %v", i, element, j,
+					captures[j])
+			}
+		}
+	}
+}
+
+func TestEqualIgnoringTrailingWildcard(t *testing.T) {
+	tcases := []struct {
+		a      key.Path
+		b      key.Path
+		result bool
+	}{
+		{a: nil, b: nil, result: true},
+		{a: New(Wildcard), b: nil, result: true},
+		{a: New("a", "b"), b: New("a", "b"), result: true},
+		{a: New("a", "b", Wildcard), b: New("a", "b"), result: true},
+		{a: New("a", "b"), b: New("a", "b", Wildcard), result: true},
+		{a: New("a", "b", Wildcard, Wildcard), b: New("a", "b"), result: true},
+		{a: New("a", "b", Wildcard, Wildcard), b: New("a", "b", Wildcard), result: true},
+		{a: New("a", "b", Wildcard), b: New("a", "c"), result: false},
+		{a: New("a", "b", Wildcard), b: New("a", "b", "c"), result: false},
+		{a: New("a", Wildcard, "c"), b: New("a", "b", "c"), result: false},
+		{a: New("a", Wildcard, "c", Wildcard), b: New("a", Wildcard, "c"), result: true},
+		{a: New("a", "b", Wildcard), b: New("a"), result: false},
+	}
+	for i, tcase := range tcases {
+		if r := EqualIgnoringTrailingWildcard(tcase.a, tcase.b); r != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, result: %t",
+				i, tcase.a, tcase.b, tcase.result)
+		}
+	}
+}
+
+func TestTrimWildcardPrefix(t *testing.T) {
+	tcases := []struct {
+		in  key.Path
+		out key.Path
+	}{{
+		in:  nil,
+		out: key.Path{},
+	}, {
+		in:  New("foo", Wildcard, "bar"),
+		out: New("foo", Wildcard, "bar"),
+	}, {
+		in:  New(Wildcard, "foo", "bar"),
+		out: New("foo", "bar"),
+	}, {
+		in:  New(Wildcard, Wildcard, Wildcard, "foo", Wildcard),
+		out: New("foo", Wildcard),
+	}, {
+		in:  New(Wildcard, Wildcard),
+		out: key.Path{},
+	}}
+	for i, tcase := range tcases {
+		if out := TrimWildcardPrefix(tcase.in); !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: expected %#v, got %#v", i, tcase.out, out)
+		}
+	}
+	in := New(Wildcard, "foo", "bar")
+	out := TrimWildcardPrefix(in)
+	out[0] = key.New("baz")
+	if !Equal(in, New(Wildcard, "baz", "bar")) && !aliasingChecksEnabled() {
+		t.Error("TrimWildcardPrefix is not sharing memory with its input")
+	}
+}
+
+func TestMatchMasked(t *testing.T) {
+	long := make(key.Path, 66)
+	for i := range long {
+		long[i] = key.New(fmt.Sprint(i))
+	}
+	longCandidate := Append(Clone(long[:65]), "x")
+	tcases := []struct {
+		template  key.Path
+		mask      uint64
+		candidate key.Path
+		result    bool
+	}{
+		{template: nil, mask: 0, candidate: nil, result: true},
+		{template: New("a", "b"), mask: 0, candidate: New("a", "b"), result: true},
+		{template: New("a", "b"), mask: 0, candidate: New("a", "c"), result: false},
+		{template: New("a", "b"), mask: 2, candidate: New("a", "c"), result: true},
+		{template: New("a", "b"), mask: 1, candidate: New("a", "c"), result: false},
+		{template: New("a", "b"), mask: 1, candidate: New("x", "b"), result: true},
+		{template: New("a", "b"), mask: 3, candidate: New("x", "y"), result: true},
+		{template: New("a", "b"), mask: 3, candidate: New("x"), result: false},
+		{template: New("a", "b"), mask: 4, candidate: New("a", "b"), result: true},
+		{template: New("a", Wildcard), mask: 1, candidate: New("x", "y"), result: true},
+		{template: New("a", "b", "c"), mask: 5, candidate: New("x", "b", "z"),
+			result: true},
+		{template: New("a", "b", "c"), mask: 5, candidate: New("x", "y", "z"),
+			result: false},
+		// Elements from index 64 can't be masked.
+		{template: long, mask: 1 << 63, candidate: longCandidate, result: false},
+		{template: long, mask: ^uint64(0), candidate: long, result: true},
+	}
+	for i, tcase := range tcases {
+		if r := MatchMasked(tcase.template, tcase.mask, tcase.candidate); r != tcase.result {
+			t.Errorf("Test %d failed: MatchMasked(%s, %b, %s): expected %t",
+				i, tcase.template, tcase.mask, tcase.candidate, tcase.result)
+		}
+		bools := make([]bool, 64)
+		for j := range bools {
+			bools[j] = tcase.mask&(1<<uint(j)) != 0
+		}
+		if r := MatchMaskedBools(tcase.template, bools, tcase.candidate); r != tcase.result {
+			t.Errorf("Test %d failed: MatchMaskedBools(%s, %v, %s): expected %t",
+				i, tcase.template, bools, tcase.candidate, tcase.result)
+		}
+	}
+	mask := make([]bool, 66)
+	mask[65] = true
+	if !MatchMaskedBools(long, mask, longCandidate) {
+		t.Errorf("expected %s to match %s with the last element masked", long, longCandidate)
+	}
+	if MatchMaskedBools(long, mask[:65], longCandidate) {
+		t.Errorf("expected %s not to match %s with a short mask", long, longCandidate)
+	}
+}
+
+func TestStripWildcards(t *testing.T) {
+	tcases := []struct {
+		in  key.Path
+		out key.Path
+	}{{
+		in:  nil,
+		out: key.Path{},
+	}, {
+		in:  New(Wildcard, Wildcard),
+		out: key.Path{},
+	}, {
+		in:  New("foo", "bar"),
+		out: New("foo", "bar"),
+	}, {
+		in:  New(Wildcard, "foo", Wildcard, Wildcard, "bar", "baz", Wildcard),
+		out: New("foo", "bar", "baz"),
+	}}
+	for i, tcase := range tcases {
+		out := StripWildcards(tcase.in)
+		if out == nil || !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: expected %#v, got %#v", i, tcase.out, out)
+		}
+	}
+	in := New("foo", "bar")
+	out := StripWildcards(in)
+	out[0] = key.New("baz")
+	if !Equal(in, New("foo", "bar")) {
+		t.Error("StripWildcards is not making a copied path")
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	paths := []key.Path{
+		New("a", "b", "c"),
+		New("a"),
+		New("a", "b"),
+		New("a", "c", "d"),
+		New(int64(1), "b"),
+		New("1", "b"),
+		New(),
+		New("a", "b", "d"),
+	}
+	tcases := []struct {
+		depth  int
+		groups map[string][]key.Path
+	}{{
+		depth: 0,
+		groups: map[string][]key.Path{
+			key.KeyString(key.New(key.Path{})): paths,
+		},
+	}, {
+		depth: 1,
+		groups: map[string][]key.Path{
+			key.KeyString(key.New(New("a"))): {paths[0], paths[1], paths[2], paths[3],
+				paths[7]},
+			key.KeyString(key.New(New(int64(1)))): {paths[4]},
+			key.KeyString(key.New(New("1"))):      {paths[5]},
+			key.KeyString(key.New(New())):         {paths[6]},
+		},
+	}, {
+		depth: 2,
+		groups: map[string][]key.Path{
+			key.KeyString(key.New(New("a", "b"))):      {paths[0], paths[2], paths[7]},
+			key.KeyString(key.New(New("a"))):           {paths[1]},
+			key.KeyString(key.New(New("a", "c"))):      {paths[3]},
+			key.KeyString(key.New(New(int64(1), "b"))): {paths[4]},
+			key.KeyString(key.New(New("1", "b"))):      {paths[5]},
+			key.KeyString(key.New(New())):              {paths[6]},
+		},
+	}, {
+		depth: -1,
+		groups: map[string][]key.Path{
+			key.KeyString(key.New(New())): paths,
+		},
+	}}
+	for i, tcase := range tcases {
+		groups := GroupByPrefix(paths, tcase.depth)
+		if len(groups) != len(tcase.groups) {
+			t.Errorf("Test %d failed: expected %d groups, got %d: %v",
+				i, len(tcase.groups), len(groups), groups)
+			continue
+		}
+		for k, expected := range tcase.groups {
+			group := groups[k]
+			if len(group) != len(expected) {
+				t.Errorf("Test %d failed: group %s: expected %v, got %v",
+					i, k, expected, group)
+				continue
+			}
+			for j := range group {
+				if !Equal(group[j], expected[j]) {
+					t.Errorf("Test %d failed: group %s: expected %v, got %v",
+						i, k, expected, group)
+					break
+				}
+			}
+		}
+	}
+	if groups := GroupByPrefix(nil, 1); len(groups) != 0 {
+		t.Errorf("expected no groups, got %v", groups)
+	}
+}
+
+func TestEqualIgnoringWildcards(t *testing.T) {
+	tcases := []struct {
+		a      key.Path
+		b      key.Path
+		result bool
+	}{
+		{a: nil, b: nil, result: true},
+		{a: key.Path{}, b: nil, result: true},
+		{a: New("foo"), b: New("foo"), result: true},
+		{a: New("foo"), b: New("bar"), result: false},
+		{a: New("foo"), b: New("foo", "bar"), result: false},
+		{a: New(Wildcard), b: New("foo"), result: true},
+		{a: New("foo"), b: New(Wildcard), result: true},
+		{a: New(Wildcard), b: New(Wildcard), result: true},
+		{a: New(Wildcard), b: New("foo", "bar"), result: false},
+		{a: New("foo", Wildcard, "baz"), b: New("foo", "bar", Wildcard), result: true},
+		{a: New("foo", Wildcard, "baz"), b: New("foo", "bar", "qux"), result: false},
+		{a: New("foo", Wildcard, "baz"), b: New("qux", Wildcard, "baz"), result: false},
+		{a: New(Wildcard, Wildcard), b: New(int32(1), map[string]interface{}{"a": 1}),
+			result: true},
+	}
+	for i, tcase := range tcases {
+		if result := EqualIgnoringWildcards(tcase.a, tcase.b); result != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, result: %t, expected: %t",
+				i, tcase.a, tcase.b, result, tcase.result)
+		}
+		if result := EqualIgnoringWildcards(tcase.b, tcase.a); result != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, result: %t, expected: %t",
+				i, tcase.b, tcase.a, result, tcase.result)
+		}
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	tcases := []struct {
+		ancestor   key.Path
+		descendant key.Path
+		result     bool
+		proper     bool
+	}{{
+		ancestor:   nil,
+		descendant: nil,
+		result:     true,
+		proper:     false,
+	}, {
+		ancestor:   nil,
+		descendant: New("foo"),
+		result:     true,
+		proper:     true,
+	}, {
+		ancestor:   New("foo", "bar"),
+		descendant: New("foo", "bar"),
+		result:     true,
+		proper:     false,
+	}, {
+		ancestor:   New("foo"),
+		descendant: New("foo", "bar", "baz"),
+		result:     true,
+		proper:     true,
+	}, {
+		ancestor:   New("foo", "bar", "baz"),
+		descendant: New("foo"),
+		result:     false,
+		proper:     false,
+	}, {
+		ancestor:   New("foo", "qux"),
+		descendant: New("foo", "bar", "baz"),
+		result:     false,
+		proper:     false,
+	}, {
+		ancestor:   New("foo", Wildcard),
+		descendant: New("foo", "bar", "baz"),
+		result:     false,
+		proper:     false,
+	}}
+	for i, tcase := range tcases {
+		if r := IsAncestor(tcase.ancestor, tcase.descendant); r != tcase.result {
+			t.Errorf("Test %d failed: IsAncestor(%s, %s): expected %t, got %t",
+				i, tcase.ancestor, tcase.descendant, tcase.result, r)
+		}
+		if r := IsDescendant(tcase.descendant, tcase.ancestor); r != tcase.result {
+			t.Errorf("Test %d failed: IsDescendant(%s, %s): expected %t, got %t",
+				i, tcase.descendant, tcase.ancestor, tcase.result, r)
+		}
+		if r := IsProperAncestor(tcase.ancestor, tcase.descendant); r != tcase.proper {
+			t.Errorf("Test %d failed: IsProperAncestor(%s, %s): expected %t, got %t",
+				i, tcase.ancestor, tcase.descendant, tcase.proper, r)
+		}
+		if r := IsProperDescendant(tcase.descendant, tcase.ancestor); r != tcase.proper {
+			t.Errorf("Test %d failed: IsProperDescendant(%s, %s): expected %t, got %t",
+				i, tcase.descendant, tcase.ancestor, tcase.proper, r)
+		}
+	}
+}
+
+func TestHasAnyPrefix(t *testing.T) {
+	tcases := []struct {
+		a        key.Path
+		prefixes []key.Path
+		index    int
+		ok       bool
+		match    int // index returned by MatchAnyPrefix
+	}{
+		{
+			a:     New("foo"),
+			index: -1,
+			match: -1,
+		}, {
+			a:        New("foo"),
+			prefixes: []key.Path{New()},
+			index:    0,
+			ok:       true,
+		}, {
+			a:        New("foo", "bar"),
+			prefixes: []key.Path{New("bar"), New("foo", "baz")},
+			index:    -1,
+			match:    -1,
+		}, {
+			a:        New("foo", "bar", "baz"),
+			prefixes: []key.Path{New("bar"), New("foo", "bar"), New("foo")},
+			index:    1,
+			ok:       true,
+			match:    1,
+		}, {
+			a:        New("foo", "bar", "baz"),
+			prefixes: []key.Path{New("foo"), New("foo", "bar")},
+			index:    0,
+			ok:       true,
+		}, {
+			a:        New("foo"),
+			prefixes: []key.Path{New("foo", "bar"), New("bar"), New("foo")},
+			index:    2,
+			ok:       true,
+			match:    2,
+		}, {
+			a:        New("foo", Wildcard, "baz"),
+			prefixes: []key.Path{New("foo", "bar"), New("foo", Wildcard)},
+			index:    1,
+			ok:       true,
+			match:    0,
+		},
+	}
+	for i, tcase := range tcases {
+		index, ok := HasAnyPrefix(tcase.a, tcase.prefixes...)
+		if index != tcase.index || ok != tcase.ok {
+			t.Errorf("Test %d failed: HasAnyPrefix returned %d (%t), expected %d (%t)",
+				i, index, ok, tcase.index, tcase.ok)
+		}
+		index, ok = MatchAnyPrefix(tcase.a, tcase.prefixes...)
+		if index != tcase.match || ok != (tcase.match >= 0) {
+			t.Errorf("Test %d failed: MatchAnyPrefix returned %d (%t), expected %d",
+				i, index, ok, tcase.match)
+		}
+	}
+}
+
 func TestMatchPrefix(t *testing.T) {
 	tcases := []struct {
 		a      key.Path
@@ -549,6 +1458,107 @@ func TestMatchPrefix(t *testing.T) {
 	}
 }
 
+func TestMatchPrefixCoerce(t *testing.T) {
+	tcases := []struct {
+		a      key.Path
+		b      key.Path
+		result bool
+	}{{
+		a:      New("foo", float64(1), true),
+		b:      New("foo", int64(1)),
+		result: true,
+	}, {
+		a:      New("foo", Wildcard, uint8(2)),
+		b:      New("foo", "bar", float64(2)),
+		result: true,
+	}, {
+		a:      New("foo", float64(1.5)),
+		b:      New("foo", int64(1)),
+		result: false,
+	}, {
+		a:      New("foo", true),
+		b:      New("foo", int64(1)),
+		result: false,
+	}, {
+		a:      New("foo", "1"),
+		b:      New("foo", int64(1)),
+		result: false,
+	}, {
+		a:      New("foo"),
+		b:      New("foo", int64(1)),
+		result: false,
+	}}
+	for i, tcase := range tcases {
+		if MatchPrefixCoerce(tcase.a, tcase.b) != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, result: %t",
+				i, tcase.a, tcase.b, tcase.result)
+		}
+	}
+}
+
+func TestMatchPrefixDepth(t *testing.T) {
+	tcases := []struct {
+		a        key.Path
+		b        key.Path
+		maxDepth int
+		result   bool
+	}{
+		{
+			a:        nil,
+			b:        nil,
+			maxDepth: 0,
+			result:   true,
+		}, {
+			a:        key.Path{},
+			b:        key.Path{key.New("foo")},
+			maxDepth: -1,
+			result:   true,
+		}, {
+			a:        key.Path{},
+			b:        key.Path{key.New("foo")},
+			maxDepth: 0,
+			result:   true,
+		}, {
+			a:        key.Path{},
+			b:        key.Path{key.New("foo")},
+			maxDepth: 1,
+			result:   false,
+		}, {
+			a:        key.Path{Wildcard, key.New("bar")},
+			b:        key.Path{key.New("foo"), key.New("baz"), key.New("qux")},
+			maxDepth: 1,
+			result:   true,
+		}, {
+			a:        key.Path{Wildcard, key.New("bar")},
+			b:        key.Path{key.New("foo"), key.New("baz"), key.New("qux")},
+			maxDepth: 2,
+			result:   false,
+		}, {
+			a:        key.Path{Wildcard, key.New("bar")},
+			b:        key.Path{key.New("foo"), key.New("bar"), key.New("qux")},
+			maxDepth: 2,
+			result:   true,
+		}, {
+			a:        key.Path{Wildcard, key.New("bar")},
+			b:        key.Path{key.New("foo"), key.New("bar"), key.New("qux")},
+			maxDepth: 3,
+			result:   false,
+		}, {
+			a:        key.Path{Wildcard, key.New("bar")},
+			b:        key.Path{key.New("foo"), key.New("bar")},
+			maxDepth: 100,
+			result:   true,
+		},
+	}
+	for i, tcase := range tcases {
+		result := MatchPrefixDepth(tcase.a, tcase.b, tcase.maxDepth)
+		if result != tcase.result {
+			t.Fatalf("Test %d failed: a: %#v; b: %#v, maxDepth: %d, result: %t",
+				i, tcase.a, tcase.b, tcase.maxDepth, tcase.result)
+		}
+	}
+}
+
 func TestFromString(t *testing.T) {
 	tcases := []struct {
 		in  string
@@ -712,3 +1722,412 @@ func TestAppendShouldCopy(t *testing.T) {
 		t.Errorf("paths d and e should not be equal: %s", e)
 	}
 }
+
+func TestJSONPointer(t *testing.T) {
+	tcases := []struct {
+		path    key.Path
+		pointer string
+	}{
+		{
+			path:    key.Path{},
+			pointer: "",
+		}, {
+			path:    New(""),
+			pointer: "/",
+		}, {
+			path:    New("foo", "bar"),
+			pointer: "/foo/bar",
+		}, {
+			path:    New("a/b", "m~n"),
+			pointer: "/a~1b/m~0n",
+		}, {
+			path:    New("~1", "/~", "~/0"),
+			pointer: "/~01/~1~0/~0~10",
+		}, {
+			path:    New("", "foo", ""),
+			pointer: "//foo/",
+		}, {
+			path:    New("foo", "0", " "),
+			pointer: "/foo/0/ ",
+		},
+	}
+	for i, tcase := range tcases {
+		if pointer := ToJSONPointer(tcase.path); pointer != tcase.pointer {
+			t.Errorf("Test %d failed: expected %q, got %q", i, tcase.pointer, pointer)
+		}
+		if path := FromJSONPointer(tcase.pointer); !Equal(path, tcase.path) {
+			t.Errorf("Test %d failed: expected %#v, got %#v", i, tcase.path, path)
+		}
+	}
+
+	if pointer := ToJSONPointer(New("foo", int32(1))); pointer != "/foo/1" {
+		t.Errorf("expected \"/foo/1\", got %q", pointer)
+	}
+	if path := FromJSONPointer("foo/a~1b"); !Equal(path, New("foo", "a/b")) {
+		t.Errorf("expected /foo/a~1b, got %#v", path)
+	}
+}
+
+func TestStringSep(t *testing.T) {
+	tcases := []struct {
+		in  key.Path
+		sep string
+		out string
+	}{
+		{
+			in:  key.Path{},
+			sep: ".",
+			out: ".",
+		}, {
+			in:  key.Path{key.New("foo"), key.New("bar")},
+			sep: ".",
+			out: ".foo.bar",
+		}, {
+			in:  key.Path{key.New("foo"), key.New("bar")},
+			sep: "/",
+			out: "/foo/bar",
+		}, {
+			in:  key.Path{key.New("foo.bar"), key.New("baz")},
+			sep: ".",
+			out: `.foo\.bar.baz`,
+		}, {
+			in:  key.Path{key.New(`foo\bar`), key.New("baz")},
+			sep: ".",
+			out: `.foo\\bar.baz`,
+		}, {
+			in:  key.Path{key.New("a::b"), key.New(int64(1))},
+			sep: "::",
+			out: `::a\:\:b::1`,
+		},
+	}
+	for i, tcase := range tcases {
+		if s := StringSep(tcase.in, tcase.sep); s != tcase.out {
+			t.Fatalf("Test %d failed: %s != %s", i, s, tcase.out)
+		}
+	}
+}
+
+func TestStringSepRoundTrip(t *testing.T) {
+	paths := []key.Path{
+		key.Path{},
+		key.Path{key.New("foo")},
+		key.Path{key.New("foo"), key.New("bar")},
+		key.Path{key.New(""), key.New("foo"), key.New("")},
+		key.Path{key.New("foo.bar"), key.New(".baz.")},
+		key.Path{key.New(`foo\.bar`), key.New(`\`), key.New(`\\.`)},
+		key.Path{key.New("a::b"), key.New(":"), key.New("c:")},
+		key.Path{key.New("/foo/"), key.New("bar")},
+	}
+	for _, sep := range []string{".", "/", "::"} {
+		for _, p := range paths {
+			s := StringSep(p, sep)
+			if out := FromStringSep(s, sep); !Equal(out, p) {
+				t.Errorf("round trip of %#v with separator %q via %q failed: got %#v",
+					p, sep, s, out)
+			}
+		}
+	}
+}
+
+func TestFromStringStrict(t *testing.T) {
+	tcases := []struct {
+		in  string
+		out key.Path
+	}{
+		{
+			in:  "/",
+			out: key.Path{},
+		}, {
+			in:  "/foo",
+			out: key.Path{key.New("foo")},
+		}, {
+			in:  "/foo/bar",
+			out: key.Path{key.New("foo"), key.New("bar")},
+		}, {
+			in:  "/foo bar/*/baz=1",
+			out: key.Path{key.New("foo bar"), key.New("*"), key.New("baz=1")},
+		},
+	}
+	for i, tcase := range tcases {
+		out, err := FromStringStrict(tcase.in)
+		if err != nil {
+			t.Errorf("Test %d failed: unexpected error: %s", i, err)
+		} else if !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: %#v != %#v", i, out, tcase.out)
+		}
+	}
+
+	errCases := []struct {
+		in  string
+		err string
+	}{
+		{in: "", err: "empty path string"},
+		{in: "foo", err: `path "foo" does not start with '/'`},
+		{in: "foo/bar", err: `path "foo/bar" does not start with '/'`},
+		{in: "//", err: `path "//" has an empty element at index 0`},
+		{in: "//foo", err: `path "//foo" has an empty element at index 0`},
+		{in: "/foo//bar", err: `path "/foo//bar" has an empty element at index 1`},
+		{in: "/foo/", err: `path "/foo/" has an empty element at index 1`},
+	}
+	for i, tcase := range errCases {
+		out, err := FromStringStrict(tcase.in)
+		if err == nil {
+			t.Errorf("Test %d failed: expected error, got %#v", i, out)
+		} else if err.Error() != tcase.err {
+			t.Errorf("Test %d failed: expected error %q, got %q", i, tcase.err, err)
+		}
+		if out != nil {
+			t.Errorf("Test %d failed: expected nil path, got %#v", i, out)
+		}
+	}
+}
+
+func TestFromQuotedString(t *testing.T) {
+	tcases := []struct {
+		in  string
+		out key.Path
+	}{
+		{
+			in:  "",
+			out: key.Path{},
+		}, {
+			in:  "/",
+			out: key.Path{},
+		}, {
+			in:  `/""`,
+			out: key.Path{key.New("")},
+		}, {
+			in:  "foo/bar",
+			out: key.Path{key.New("foo"), key.New("bar")},
+		}, {
+			in:  "/foo/bar",
+			out: key.Path{key.New("foo"), key.New("bar")},
+		}, {
+			in:  `/foo/"bar baz"`,
+			out: key.Path{key.New("foo"), key.New("bar baz")},
+		}, {
+			in:  `/"a/b"/"c=d"/e`,
+			out: key.Path{key.New("a/b"), key.New("c=d"), key.New("e")},
+		}, {
+			in:  `/"say \"hi\""/"\\"`,
+			out: key.Path{key.New(`say "hi"`), key.New(`\`)},
+		}, {
+			in:  `/"\t"//`,
+			out: key.Path{key.New("\t"), key.New(""), key.New("")},
+		},
+	}
+	for i, tcase := range tcases {
+		out, err := FromQuotedString(tcase.in)
+		if err != nil {
+			t.Errorf("Test %d failed: unexpected error: %s", i, err)
+		} else if !Equal(out, tcase.out) {
+			t.Errorf("Test %d failed: %#v != %#v", i, out, tcase.out)
+		}
+	}
+
+	for _, in := range []string{`/"foo`, `/"foo\"`, `/"foo"bar`, `/"\q"`} {
+		if out, err := FromQuotedString(in); err == nil {
+			t.Errorf("expected error parsing %q, got %#v", in, out)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tcases := []struct {
+		in     string
+		tokens []Token
+	}{{
+		in:     "",
+		tokens: []Token{},
+	}, {
+		in:     "/",
+		tokens: []Token{},
+	}, {
+		in:     "/foo/bar",
+		tokens: []Token{{"foo", 1, 4}, {"bar", 5, 8}},
+	}, {
+		in:     "foo/bar",
+		tokens: []Token{{"foo", 0, 3}, {"bar", 4, 7}},
+	}, {
+		in:     `/foo/"a/b"/baz`,
+		tokens: []Token{{"foo", 1, 4}, {"a/b", 5, 10}, {"baz", 11, 14}},
+	}, {
+		in:     `/"say \"hi\""`,
+		tokens: []Token{{`say "hi"`, 1, 13}},
+	}, {
+		in:     "/foo//",
+		tokens: []Token{{"foo", 1, 4}, {"", 5, 5}, {"", 6, 6}},
+	}}
+	for i, tcase := range tcases {
+		tokens, err := Tokenize(tcase.in)
+		if err != nil {
+			t.Errorf("Test %d failed: unexpected error: %s", i, err)
+			continue
+		}
+		if len(tokens) != len(tcase.tokens) {
+			t.Errorf("Test %d failed: expected %v, got %v", i, tcase.tokens, tokens)
+			continue
+		}
+		for j, token := range tokens {
+			if token != tcase.tokens[j] {
+				t.Errorf("Test %d failed: expected %v, got %v", i, tcase.tokens, tokens)
+				break
+			}
+		}
+	}
+
+	errCases := []struct {
+		in  string
+		err string
+	}{{
+		in:  `/foo/"bar`,
+		err: `unterminated quoted element at offset 5 in "/foo/\"bar"`,
+	}, {
+		in:  `/foo/"bar"baz`,
+		err: `unexpected character 'b' at offset 10 in "/foo/\"bar\"baz"`,
+	}, {
+		in: `/"\q"`,
+		err: `invalid quoted element "\q" at offset 1 in "/\"\\q\"": ` +
+			`invalid syntax`,
+	}}
+	for i, tcase := range errCases {
+		if _, err := Tokenize(tcase.in); err == nil || err.Error() != tcase.err {
+			t.Errorf("Test %d failed: expected error %q, got %v", i, tcase.err, err)
+		}
+	}
+}
+
+func TestFromStringSep(t *testing.T) {
+	tcases := []struct {
+		in  string
+		sep string
+		out key.Path
+	}{
+		{
+			in:  "",
+			sep: ".",
+			out: key.Path{},
+		}, {
+			in:  ".",
+			sep: ".",
+			out: key.Path{},
+		}, {
+			in:  "foo.bar",
+			sep: ".",
+			out: key.Path{key.New("foo"), key.New("bar")},
+		}, {
+			in:  ".foo.bar",
+			sep: ".",
+			out: key.Path{key.New("foo"), key.New("bar")},
+		}, {
+			in:  "/foo.bar/baz",
+			sep: "/",
+			out: key.Path{key.New("foo.bar"), key.New("baz")},
+		}, {
+			in:  `.foo\`,
+			sep: ".",
+			out: key.Path{key.New(`foo\`)},
+		},
+	}
+	for i, tcase := range tcases {
+		if p := FromStringSep(tcase.in, tcase.sep); !Equal(p, tcase.out) {
+			t.Fatalf("Test %d failed: %#v != %#v", i, p, tcase.out)
+		}
+	}
+}
+
+func TestShortPathFastPaths(t *testing.T) {
+	// Compare the results of Equal, HasPrefix, Match and MatchPrefix
+	// to those of plain loops for paths of up to six elements, with
+	// mismatching elements and wildcards at every position.
+	loopMatchPrefix := func(a, b key.Path) bool {
+		for i := range b {
+			if !a[i].Equal(Wildcard) && !b[i].Equal(a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	loopHasPrefix := func(a, b key.Path) bool {
+		for i := range b {
+			if !b[i].Equal(a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	variants := func(n int) []key.Path {
+		base := make(key.Path, n)
+		for i := range base {
+			base[i] = key.New(fmt.Sprint("e", i))
+		}
+		paths := []key.Path{base}
+		for i := 0; i < n; i++ {
+			for _, element := range []key.Key{key.New("x"), Wildcard, key.New(int64(i))} {
+				p := Clone(base)
+				p[i] = element
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	for la := 0; la <= 6; la++ {
+		for lb := 0; lb <= 6; lb++ {
+			for _, a := range variants(la) {
+				for _, b := range variants(lb) {
+					sameLen, longer := len(a) == len(b), len(a) >= len(b)
+					equal := longer && loopHasPrefix(a, b)
+					match := longer && loopMatchPrefix(a, b)
+					if got := Equal(a, b); got != (sameLen && equal) {
+						t.Errorf("Equal(%s, %s): unexpected %t", a, b, got)
+					}
+					if got := HasPrefix(a, b); got != equal {
+						t.Errorf("HasPrefix(%s, %s): unexpected %t", a, b, got)
+					}
+					if got := Match(a, b); got != (sameLen && match) {
+						t.Errorf("Match(%s, %s): unexpected %t", a, b, got)
+					}
+					if got := MatchPrefix(a, b); got != match {
+						t.Errorf("MatchPrefix(%s, %s): unexpected %t", a, b, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// benchmarkPaths returns two equal paths of n string elements that
+// don't share memory, and a template matching them with a wildcard
+// at its second position.
+func benchmarkPaths(n int) (a, b, template key.Path) {
+	a, b, template = make(key.Path, n), make(key.Path, n), make(key.Path, n)
+	for i := 0; i < n; i++ {
+		a[i] = key.New(fmt.Sprintf("element%d", i))
+		b[i] = key.New(fmt.Sprintf("element%d", i))
+		template[i] = b[i]
+	}
+	template[1] = Wildcard
+	return a, b, template
+}
+
+func BenchmarkShortPaths(b *testing.B) {
+	for _, n := range []int{2, 3, 4, 8} {
+		p, other, template := benchmarkPaths(n)
+		prefix := other[:n-1]
+		b.Run(fmt.Sprintf("Equal/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Equal(p, other)
+			}
+		})
+		b.Run(fmt.Sprintf("HasPrefix/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				HasPrefix(p, prefix)
+			}
+		})
+		b.Run(fmt.Sprintf("Match/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Match(template, p)
+			}
+		})
+	}
+}
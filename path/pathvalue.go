@@ -0,0 +1,32 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import "github.com/aristanetworks/goarista/key"
+
+// PathValue pairs a path with a value associated with it.
+type PathValue struct {
+	Path  key.Path
+	Value interface{}
+}
+
+// WithValue returns a PathValue pairing path with value.
+func WithValue(path key.Path, value interface{}) PathValue {
+	return PathValue{Path: path, Value: value}
+}
+
+// NewPathValue returns a PathValue pairing the path constructed
+// from elements, as with New, with value.
+func NewPathValue(value interface{}, elements ...interface{}) PathValue {
+	return PathValue{Path: New(elements...), Value: value}
+}
+
+// Equal implements the key.Comparable interface. Two PathValues
+// are equal if their paths are equal, according to Equal, and
+// their values are equal, according to key.Equal.
+func (pv PathValue) Equal(other interface{}) bool {
+	o, ok := other.(PathValue)
+	return ok && Equal(pv.Path, o.Path) && key.Equal(pv.Value, o.Value)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestPathValueEqual(t *testing.T) {
+	tcases := []struct {
+		a      PathValue
+		b      interface{}
+		result bool
+	}{
+		{
+			a:      PathValue{},
+			b:      PathValue{},
+			result: true,
+		}, {
+			a:      WithValue(nil, nil),
+			b:      WithValue(key.Path{}, nil),
+			result: true,
+		}, {
+			a:      WithValue(New("foo", "bar"), 1),
+			b:      NewPathValue(1, "foo", "bar"),
+			result: true,
+		}, {
+			a:      WithValue(New("foo", "bar"), 1),
+			b:      WithValue(New("foo", "bar"), 2),
+			result: false,
+		}, {
+			a:      WithValue(New("foo", "bar"), 1),
+			b:      WithValue(New("foo", "bar"), "1"),
+			result: false,
+		}, {
+			a:      WithValue(New("foo", "bar"), 1),
+			b:      WithValue(New("foo", "baz"), 1),
+			result: false,
+		}, {
+			a:      WithValue(New("foo"), map[string]interface{}{"a": []interface{}{1}}),
+			b:      WithValue(New("foo"), map[string]interface{}{"a": []interface{}{1}}),
+			result: true,
+		}, {
+			a:      WithValue(New("foo"), map[string]interface{}{"a": []interface{}{1}}),
+			b:      WithValue(New("foo"), map[string]interface{}{"a": []interface{}{2}}),
+			result: false,
+		}, {
+			a:      WithValue(New("foo"), 1),
+			b:      New("foo"),
+			result: false,
+		},
+	}
+	for i, tcase := range tcases {
+		if result := tcase.a.Equal(tcase.b); result != tcase.result {
+			t.Errorf("Test %d failed: a: %#v; b: %#v, result: %t, expected: %t",
+				i, tcase.a, tcase.b, result, tcase.result)
+		}
+	}
+}
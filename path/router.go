@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"github.com/aristanetworks/goarista/key"
+)
+
+// Router dispatches paths to handlers registered with patterns, which
+// are paths that may contain wildcards. A path is routed to the
+// handler of the most specific pattern matching it, as Match defines
+// it. Patterns that match a path have the same length as the path, so
+// their specificity is defined element by element: of two patterns
+// matching a path, the most specific is the one with a concrete
+// element at the first position where they differ, the other having a
+// wildcard there. For instance, /a/b/* is more specific than /a/*/c,
+// which is more specific than /*/b/c. Since patterns are all distinct,
+// this ordering always designates a single most specific pattern. As
+// Wildcard only ever matches a single element, there is no wildcard
+// matching several elements to rank below it. The zero value is an
+// empty Router ready to use.
+type Router struct {
+	m Map
+}
+
+type route struct {
+	pattern key.Path
+	handler interface{}
+}
+
+// Add registers handler with pattern, replacing the handler already
+// registered with pattern, if any.
+func (r *Router) Add(pattern key.Path, handler interface{}) {
+	r.m.Set(pattern, route{pattern: Clone(pattern), handler: handler})
+}
+
+// Remove unregisters the handler registered with pattern, and returns
+// true if there was one.
+func (r *Router) Remove(pattern key.Path) bool {
+	return r.m.Delete(pattern)
+}
+
+// Route returns the handler registered with the most specific pattern
+// matching p, along with that pattern, and true. If no pattern matches
+// p, Route returns nil, nil and false. Route is linear with respect
+// to the length of p, unless it has to backtrack from concrete
+// elements to wildcards because they don't lead to a match.
+func (r *Router) Route(p key.Path) (handler interface{}, matched key.Path, ok bool) {
+	rt, ok := r.m.route(p)
+	if !ok {
+		return nil, nil, false
+	}
+	return rt.handler, rt.pattern, true
+}
+
+// route looks for a match of p in the Map, trying concrete elements
+// before wildcards at each position.
+func (m *Map) route(p key.Path) (route, bool) {
+	if len(p) == 0 {
+		if !m.ok {
+			return route{}, false
+		}
+		return m.val.(route), true
+	}
+	if next, ok := m.children.Get(p[0]); ok {
+		if rt, ok := next.(*Map).route(p[1:]); ok {
+			return rt, true
+		}
+	}
+	if m.wildcard != nil {
+		return m.wildcard.route(p[1:])
+	}
+	return route{}, false
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestRouter(t *testing.T) {
+	var r Router
+	patterns := []key.Path{
+		New("a", "b", "c"),
+		New("a", "b", Wildcard),
+		New("a", Wildcard, "c"),
+		New(Wildcard, "b", "c"),
+		New(Wildcard, Wildcard, Wildcard),
+		New("a", Wildcard, "d", "e"),
+		New("a", "b", "d", Wildcard),
+		New(),
+	}
+	for i, pattern := range patterns {
+		r.Add(pattern, i)
+	}
+
+	tcases := []struct {
+		path    key.Path
+		handler interface{}
+	}{
+		{path: New("a", "b", "c"), handler: 0},
+		{path: New("a", "b", "x"), handler: 1},
+		{path: New("a", "x", "c"), handler: 2},
+		{path: New("x", "b", "c"), handler: 3},
+		{path: New("x", "y", "z"), handler: 4},
+		{path: New("x", "y", "c"), handler: 4},
+		// The concrete a/b/d/* doesn't match, so routing backtracks
+		// to a/*/d/e.
+		{path: New("a", "b", "d", "e"), handler: 6},
+		{path: New("a", "x", "d", "e"), handler: 5},
+		{path: New(), handler: 7},
+		{path: New("a", "b"), handler: nil},
+		{path: New("a", "x", "d", "x"), handler: nil},
+	}
+	for i, tcase := range tcases {
+		handler, matched, ok := r.Route(tcase.path)
+		if tcase.handler == nil {
+			if ok || handler != nil || matched != nil {
+				t.Errorf("Test %d failed: expected no route for %s, got %v, %s, %t",
+					i, tcase.path, handler, matched, ok)
+			}
+			continue
+		}
+		if !ok || handler != tcase.handler {
+			t.Errorf("Test %d failed: expected handler %v for %s, got %v (%t)",
+				i, tcase.handler, tcase.path, handler, ok)
+			continue
+		}
+		if expected := patterns[tcase.handler.(int)]; !Equal(matched, expected) {
+			t.Errorf("Test %d failed: expected pattern %s for %s, got %s",
+				i, expected, tcase.path, matched)
+		}
+	}
+
+	r.Add(New("a", "b", Wildcard), "replaced")
+	if handler, _, _ := r.Route(New("a", "b", "x")); handler != "replaced" {
+		t.Errorf("expected the handler to be replaced, got %v", handler)
+	}
+	if !r.Remove(New("a", "b", "c")) || r.Remove(New("a", "b", "c")) {
+		t.Error("unexpected result of Remove")
+	}
+	if handler, matched, _ := r.Route(New("a", "b", "c")); handler != "replaced" ||
+		!Equal(matched, New("a", "b", Wildcard)) {
+		t.Errorf("expected a/b/* to be routed to after a/b/c was removed, got %v, %s",
+			handler, matched)
+	}
+}
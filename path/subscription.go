@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"sort"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// SubscriptionSet is a set of subscription paths, which may contain
+// wildcards, that can be efficiently matched against the paths of
+// updates. The zero value is an empty SubscriptionSet ready to use.
+type SubscriptionSet struct {
+	m      Map
+	length int
+}
+
+// Add adds the subscription path p to the set. It returns true if
+// p was added and false if it was already in the set.
+func (s *SubscriptionSet) Add(p key.Path) bool {
+	if !s.m.Set(p, Clone(p)) {
+		return false
+	}
+	s.length++
+	return true
+}
+
+// Remove removes the subscription path p from the set. Wildcards
+// in p only match wildcards of the paths in the set. It returns
+// true if p was removed and false if it wasn't in the set.
+func (s *SubscriptionSet) Remove(p key.Path) bool {
+	if !s.m.Delete(p) {
+		return false
+	}
+	s.length--
+	return true
+}
+
+// Contains returns true if the subscription path p is in the set.
+func (s *SubscriptionSet) Contains(p key.Path) bool {
+	_, ok := s.m.Get(p)
+	return ok
+}
+
+// Len returns the number of subscription paths in the set.
+func (s *SubscriptionSet) Len() int {
+	return s.length
+}
+
+// Match returns the subscription paths of the set that match the
+// path of an update, sorted with Compare. A subscription path
+// matches update if they have the same length and each of its
+// elements is either a wildcard or equal to the element of update
+// at the same position. Match returns nil if no subscription path
+// matches update.
+func (s *SubscriptionSet) Match(update key.Path) []key.Path {
+	var matches []key.Path
+	_ = s.m.Visit(update, func(v interface{}) error {
+		matches = append(matches, v.(key.Path))
+		return nil
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		return Compare(matches[i], matches[j]) < 0
+	})
+	return matches
+}
+
+// Paths returns all the subscription paths of the set, sorted with
+// Compare.
+func (s *SubscriptionSet) Paths() []key.Path {
+	paths := make([]key.Path, 0, s.length)
+	_ = s.m.VisitPrefixed(nil, func(v interface{}) error {
+		paths = append(paths, v.(key.Path))
+		return nil
+	})
+	sort.Slice(paths, func(i, j int) bool {
+		return Compare(paths[i], paths[j]) < 0
+	})
+	return paths
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestSubscriptionSet(t *testing.T) {
+	var s SubscriptionSet
+	subscriptions := []key.Path{
+		New("foo", "bar", "baz"),
+		New("foo", Wildcard, "baz"),
+		New("foo", "bar", Wildcard),
+		New(Wildcard, Wildcard, Wildcard),
+		New("foo", "bar"),
+		New("foo", Wildcard),
+		New(),
+	}
+	for _, p := range subscriptions {
+		if !s.Add(p) {
+			t.Errorf("expected %s to be added", p)
+		}
+	}
+	if s.Add(New("foo", Wildcard, "baz")) {
+		t.Error("expected a duplicate subscription not to be added")
+	}
+	if s.Len() != len(subscriptions) {
+		t.Errorf("expected %d subscriptions, got %d", len(subscriptions), s.Len())
+	}
+
+	tcases := []struct {
+		update   key.Path
+		expected []key.Path
+	}{{
+		update: New("foo", "bar", "baz"),
+		expected: []key.Path{
+			New("foo", "bar", "baz"),
+			New("foo", "bar", Wildcard),
+			New("foo", Wildcard, "baz"),
+			New(Wildcard, Wildcard, Wildcard),
+		},
+	}, {
+		update: New("foo", "qux", "baz"),
+		expected: []key.Path{
+			New("foo", Wildcard, "baz"),
+			New(Wildcard, Wildcard, Wildcard),
+		},
+	}, {
+		update:   New("qux", "bar", "baz"),
+		expected: []key.Path{New(Wildcard, Wildcard, Wildcard)},
+	}, {
+		update:   New("foo", "bar"),
+		expected: []key.Path{New("foo", "bar"), New("foo", Wildcard)},
+	}, {
+		update:   New("foo", "qux"),
+		expected: []key.Path{New("foo", Wildcard)},
+	}, {
+		update:   New(),
+		expected: []key.Path{New()},
+	}, {
+		update: New("foo"),
+	}, {
+		update: New("foo", "bar", "baz", "qux"),
+	}}
+	checkMatches := func(i int, update key.Path, expected []key.Path) {
+		t.Helper()
+		matches := s.Match(update)
+		if len(matches) != len(expected) {
+			t.Errorf("Test %d failed: expected matches %v for %s, got %v",
+				i, expected, update, matches)
+			return
+		}
+		for j := range matches {
+			if !Equal(matches[j], expected[j]) {
+				t.Errorf("Test %d failed: expected matches %v for %s, got %v",
+					i, expected, update, matches)
+				return
+			}
+		}
+	}
+	for i, tc := range tcases {
+		checkMatches(i, tc.update, tc.expected)
+	}
+
+	if s.Remove(New("foo", "qux", "baz")) {
+		t.Error("expected a concrete path matching a wildcard subscription not to be removed")
+	}
+	if !s.Remove(New(Wildcard, Wildcard, Wildcard)) || !s.Remove(New("foo", "bar", "baz")) {
+		t.Error("expected subscriptions to be removed")
+	}
+	if s.Remove(New("foo", "bar", "baz")) {
+		t.Error("expected a subscription not to be removed twice")
+	}
+	if s.Contains(New("foo", "bar", "baz")) || !s.Contains(New("foo", Wildcard, "baz")) {
+		t.Error("unexpected subscriptions after removal")
+	}
+	checkMatches(len(tcases), New("foo", "bar", "baz"),
+		[]key.Path{New("foo", "bar", Wildcard), New("foo", Wildcard, "baz")})
+	checkMatches(len(tcases)+1, New("qux", "bar", "baz"), nil)
+
+	paths := s.Paths()
+	expected := []key.Path{
+		New(),
+		New("foo", "bar"),
+		New("foo", "bar", Wildcard),
+		New("foo", Wildcard),
+		New("foo", Wildcard, "baz"),
+	}
+	if s.Len() != len(expected) || len(paths) != len(expected) {
+		t.Fatalf("expected paths %v, got %v (length %d)", expected, paths, s.Len())
+	}
+	for i := range paths {
+		if !Equal(paths[i], expected[i]) {
+			t.Fatalf("expected paths %v, got %v", expected, paths)
+		}
+	}
+}
+
+func TestSubscriptionSetCopiesPaths(t *testing.T) {
+	var s SubscriptionSet
+	p := New("foo", "bar")
+	s.Add(p)
+	p[1] = key.New("baz")
+	if matches := s.Match(New("foo", "bar")); len(matches) != 1 ||
+		!Equal(matches[0], New("foo", "bar")) {
+		t.Errorf("expected the subscription to be unaffected by changes to the added path, "+
+			"got %v", matches)
+	}
+}
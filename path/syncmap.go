@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"sync"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// SyncMap is a Map that is safe for concurrent use by multiple
+// goroutines. Its methods behave like those of Map, but are guarded
+// by a sync.RWMutex, so that methods reading the Map may run
+// concurrently with each other, but not with methods modifying it.
+// The zero value is an empty SyncMap ready to use. A SyncMap must not
+// be copied after first use.
+type SyncMap struct {
+	mu sync.RWMutex
+	m  Map
+}
+
+// Set registers a path p with a value, as Map.Set does.
+func (s *SyncMap) Set(p key.Path, v interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Set(p, v)
+}
+
+// Get returns the value registered with an exact match of a path p,
+// as Map.Get does.
+func (s *SyncMap) Get(p key.Path) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Get(p)
+}
+
+// Delete unregisters the value registered with a path, as Map.Delete
+// does.
+func (s *SyncMap) Delete(p key.Path) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Delete(p)
+}
+
+// IsEmpty returns true if no paths have been registered, false
+// otherwise.
+func (s *SyncMap) IsEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.IsEmpty()
+}
+
+// Visit calls fn for every value registered with a match of a path
+// p, as Map.Visit does. The read lock is held for the duration of the
+// visit, so fn sees a consistent Map, but it must not call any method
+// of the SyncMap: methods modifying it would deadlock, and methods
+// reading it would acquire the read lock recursively, which deadlocks
+// as soon as a writer is waiting for the lock.
+func (s *SyncMap) Visit(p key.Path, fn VisitorFunc) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Visit(p, fn)
+}
+
+// VisitPrefixes calls fn for every value registered with a prefix of
+// a path p, as Map.VisitPrefixes does. As with Visit, the read lock
+// is held for the duration of the visit, so fn must not call any
+// method of the SyncMap.
+func (s *SyncMap) VisitPrefixes(p key.Path, fn VisitorFunc) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.VisitPrefixes(p, fn)
+}
+
+// VisitPrefixed calls fn for every value registered with a path
+// prefixed by p, as Map.VisitPrefixed does. As with Visit, the read
+// lock is held for the duration of the visit, so fn must not call any
+// method of the SyncMap.
+func (s *SyncMap) VisitPrefixed(p key.Path, fn VisitorFunc) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.VisitPrefixed(p, fn)
+}
+
+func (s *SyncMap) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.String()
+}
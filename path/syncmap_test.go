@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSyncMap(t *testing.T) {
+	var s SyncMap
+	if !s.IsEmpty() {
+		t.Fatal("expected a zero SyncMap to be empty")
+	}
+	if !s.Set(New("foo", Wildcard), 1) || s.Set(New("foo", Wildcard), 2) {
+		t.Error("unexpected result of Set")
+	}
+	s.Set(New("foo"), 3)
+	s.Set(New("foo", "bar", "baz"), 4)
+	if v, ok := s.Get(New("foo", Wildcard)); !ok || v != 2 {
+		t.Errorf("expected 2, got %v (%t)", v, ok)
+	}
+	var visited []interface{}
+	accumulate := func(v interface{}) error {
+		visited = append(visited, v)
+		return nil
+	}
+	if err := s.Visit(New("foo", "bar"), accumulate); err != nil ||
+		len(visited) != 1 || visited[0] != 2 {
+		t.Errorf("Visit: unexpected values %v (%v)", visited, err)
+	}
+	visited = nil
+	if err := s.VisitPrefixes(New("foo", "bar", "baz"), accumulate); err != nil ||
+		len(visited) != 3 {
+		t.Errorf("VisitPrefixes: unexpected values %v (%v)", visited, err)
+	}
+	visited = nil
+	if err := s.VisitPrefixed(New("foo", "bar"), accumulate); err != nil ||
+		len(visited) != 2 || visited[0] != 2 || visited[1] != 4 {
+		t.Errorf("VisitPrefixed: unexpected values %v (%v)", visited, err)
+	}
+	if !s.Delete(New("foo", Wildcard)) || s.Delete(New("foo", Wildcard)) {
+		t.Error("unexpected result of Delete")
+	}
+	if _, ok := s.Get(New("foo", Wildcard)); ok {
+		t.Error("expected the deleted path to be gone")
+	}
+}
+
+// TestSyncMapConcurrency is meant to be run with the race detector.
+func TestSyncMapConcurrency(t *testing.T) {
+	var s SyncMap
+	const writers, readers, n = 4, 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				p := New("interfaces", fmt.Sprintf("eth%d", w), Wildcard, int64(i))
+				s.Set(p, i)
+				if i%2 == 0 {
+					s.Delete(p)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				update := New("interfaces", fmt.Sprintf("eth%d", r), "counters", int64(i))
+				err := s.Visit(update, func(v interface{}) error {
+					if v != i {
+						return fmt.Errorf("expected %d, got %v", i, v)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+				_ = s.VisitPrefixed(New("interfaces"), func(v interface{}) error {
+					return nil
+				})
+				_, _ = s.Get(update)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	count := 0
+	_ = s.VisitPrefixed(nil, func(v interface{}) error {
+		if v.(int)%2 == 0 {
+			t.Errorf("unexpected value %v", v)
+		}
+		count++
+		return nil
+	})
+	if count != writers*n/2 {
+		t.Errorf("expected %d values, got %d", writers*n/2, count)
+	}
+}
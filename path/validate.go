@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// ValidateOption changes the rules applied by Validate.
+type ValidateOption func(c *validateConfig)
+
+type validateConfig struct {
+	allowEmpty bool
+	allowOther bool
+}
+
+// AllowEmptyElements makes Validate accept elements wrapping an empty
+// string, an empty []byte or an empty net.IP.
+func AllowEmptyElements() ValidateOption {
+	return func(c *validateConfig) {
+		c.allowEmpty = true
+	}
+}
+
+// AllowOtherElements makes Validate accept elements of kind
+// OtherElement, such as elements wrapping a custom value.Value.
+func AllowOtherElements() ValidateOption {
+	return func(c *validateConfig) {
+		c.allowOther = true
+	}
+}
+
+// Validate checks that every element of path is suitable for use in
+// a path, and returns an error identifying the first element that
+// isn't. By default, the following elements are rejected:
+//   - nil elements and elements wrapping nil,
+//   - elements wrapping an empty string, an empty []byte or an empty
+//     net.IP,
+//   - elements of kind OtherElement, as returned by ElementType.
+//
+// The empty path is valid. The options change which elements are
+// accepted.
+func Validate(path key.Path, options ...ValidateOption) error {
+	var c validateConfig
+	for _, option := range options {
+		option(&c)
+	}
+	for i, element := range path {
+		switch kind := ElementType(element); kind {
+		case NilElement:
+			return fmt.Errorf("invalid path element %d: nil element", i)
+		case StringElement, BytesElement:
+			if !c.allowEmpty && element.Key() == "" {
+				return fmt.Errorf("invalid path element %d: empty %s", i, kind)
+			}
+		case IPElement:
+			if !c.allowEmpty && len(element.Key().(net.IP)) == 0 {
+				return fmt.Errorf("invalid path element %d: empty %s", i, kind)
+			}
+		case OtherElement:
+			if !c.allowOther {
+				return fmt.Errorf("invalid path element %d: unexpected type %T",
+					i, element.Key())
+			}
+		}
+	}
+	return nil
+}
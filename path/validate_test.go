@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package path
+
+import (
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+func TestValidate(t *testing.T) {
+	tcases := []struct {
+		in      key.Path
+		options []ValidateOption
+		err     string
+	}{
+		{in: nil},
+		{in: key.Path{}},
+		{in: New("foo", "bar")},
+		{in: New("foo", Wildcard, int32(1), uint64(2), float32(3), true, []byte("bar"))},
+		{in: New(map[string]interface{}{"name": "Ethernet1"}, []interface{}{"a"})},
+		{in: New(key.New(New("foo")), key.NewPointer(New("foo")))},
+		{in: New("neighbors", net.ParseIP("10.0.0.1"), complex128(1+2i))},
+		{
+			in:  key.Path{key.New("foo"), nil},
+			err: "invalid path element 1: nil element",
+		}, {
+			in:  New("foo", "bar", nil),
+			err: "invalid path element 2: nil element",
+		}, {
+			in:      New(nil),
+			options: []ValidateOption{AllowEmptyElements(), AllowOtherElements()},
+			err:     "invalid path element 0: nil element",
+		}, {
+			in:  New("foo", ""),
+			err: "invalid path element 1: empty string",
+		}, {
+			in:  New([]byte{}, "foo"),
+			err: "invalid path element 0: empty bytes",
+		}, {
+			in:  New("foo", net.IP(nil)),
+			err: "invalid path element 1: empty ip address",
+		}, {
+			in:      New("", "foo", []byte{}, net.IP(nil)),
+			options: []ValidateOption{AllowEmptyElements()},
+		}, {
+			in:  New("foo", customKey{i: &a}),
+			err: "invalid path element 1: unexpected type path.customKey",
+		}, {
+			in:      New("foo", customKey{i: &a}),
+			options: []ValidateOption{AllowOtherElements()},
+		}, {
+			in:      New("", customKey{i: &a}),
+			options: []ValidateOption{AllowOtherElements()},
+			err:     "invalid path element 0: empty string",
+		},
+	}
+	for i, tcase := range tcases {
+		err := Validate(tcase.in, tcase.options...)
+		if tcase.err == "" {
+			if err != nil {
+				t.Errorf("Test %d failed: unexpected error: %s", i, err)
+			}
+		} else if err == nil || err.Error() != tcase.err {
+			t.Errorf("Test %d failed: expected error %q, got %v", i, tcase.err, err)
+		}
+	}
+}
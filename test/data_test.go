@@ -460,11 +460,13 @@ func getDeepEqualTests(t *testing.T) []deepEqualTestCase {
 			"a": key.NewMap(key.New(map[string]interface{}{"k": 51}), true)}),
 		diff: `Comparable types are different: ` +
 			`key.compositeKey{sentinel:uintptr(18379810577513696751), m:map[string]interface {}` +
-			`{"a":*key.Map{normal:<max_depth>, custom:<max_depth>, length:<max_depth>}}, ` +
-			`s:[]interface {}{}} vs ` +
+			`{"a":*key.Map{normal:<max_depth>, custom:<max_depth>, length:<max_depth>, ` +
+			`shared:<max_depth>, hook:<max_depth>}}, ` +
+			`s:[]interface {}{}, hash:*key.hashCache{h:uint64(0), done:uint32(0)}} vs ` +
 			`key.compositeKey{sentinel:uintptr(18379810577513696751), m:map[string]interface {}` +
-			`{"a":*key.Map{normal:<max_depth>, custom:<max_depth>, length:<max_depth>}}, ` +
-			`s:[]interface {}{}}`,
+			`{"a":*key.Map{normal:<max_depth>, custom:<max_depth>, length:<max_depth>, ` +
+			`shared:<max_depth>, hook:<max_depth>}}, ` +
+			`s:[]interface {}{}, hash:*key.hashCache{h:uint64(0), done:uint32(0)}}`,
 	}, {
 		a: fmt.Errorf("This is a %d error", 42),
 		b: errors.New("This is a 42 error"),
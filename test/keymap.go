@@ -0,0 +1,35 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aristanetworks/goarista/key"
+)
+
+// MapEntries returns the entries of a key.Map sorted by key using
+// key.Compare. Unlike key.Map.Iter, the order of the entries doesn't
+// depend on how the Map stores them, so the result can be compared
+// against an expected list of entries or a golden file.
+func MapEntries(m *key.Map) []key.Entry {
+	entries := m.Entries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return key.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+	return entries
+}
+
+// DumpMap returns a human readable dump of a key.Map, with one
+// "key: value" line per entry, in the order returned by MapEntries.
+func DumpMap(m *key.Map) string {
+	var b strings.Builder
+	for _, entry := range MapEntries(m) {
+		fmt.Fprintf(&b, "%v: %v\n", entry.Key, entry.Value)
+	}
+	return b.String()
+}
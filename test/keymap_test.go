@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Arista Networks, Inc.
+// Use of this source code is governed by the Apache License 2.0
+// that can be found in the COPYING file.
+
+package test_test
+
+import (
+	"testing"
+
+	"github.com/aristanetworks/goarista/key"
+	"github.com/aristanetworks/goarista/path"
+	. "github.com/aristanetworks/goarista/test"
+)
+
+func TestMapEntries(t *testing.T) {
+	m := key.NewMap(
+		"b", 2,
+		"a", 1,
+		int64(-1), "neg",
+		uint8(3), "three",
+		true, "yes",
+		key.New(map[string]interface{}{"name": "Ethernet2"}), "et2",
+		key.New(map[string]interface{}{"name": "Ethernet1"}), "et1",
+		key.New(path.New("foo", "bar")), "path",
+	)
+	expected := []key.Entry{
+		{Key: true, Value: "yes"},
+		{Key: int64(-1), Value: "neg"},
+		{Key: uint8(3), Value: "three"},
+		{Key: "a", Value: 1},
+		{Key: "b", Value: 2},
+		{Key: key.New(map[string]interface{}{"name": "Ethernet1"}), Value: "et1"},
+		{Key: key.New(map[string]interface{}{"name": "Ethernet2"}), Value: "et2"},
+		{Key: key.New(path.New("foo", "bar")), Value: "path"},
+	}
+	for i := 0; i < 10; i++ {
+		if d := Diff(expected, MapEntries(m)); d != "" {
+			t.Fatalf("unexpected entries: %s", d)
+		}
+	}
+
+	if entries := MapEntries(nil); len(entries) != 0 {
+		t.Errorf("expected no entries for nil map, got %v", entries)
+	}
+}
+
+func TestDumpMap(t *testing.T) {
+	m := key.NewMap(
+		"b", key.NewMap("y", 2, "x", 1),
+		"a", []interface{}{1, "c"},
+		key.New(map[string]interface{}{"k": "v"}), nil,
+	)
+	expected := "a: [1 c]\n" +
+		"b: key.Map[x:1 y:2]\n" +
+		"v: <nil>\n"
+	if s := DumpMap(m); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if s := DumpMap(key.NewMap()); s != "" {
+		t.Errorf("expected empty dump, got %q", s)
+	}
+}